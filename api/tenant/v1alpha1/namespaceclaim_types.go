@@ -0,0 +1,96 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NamespaceClaimSpec defines the namespace requested by a team member
+type NamespaceClaimSpec struct {
+	// Namespace is the name of the namespace to create.
+	Namespace string `json:"namespace"`
+	// Team the namespace is created for.
+	Team string `json:"team"`
+	// QuotaPreset is the QuotaPreset of the namespace, defaults to the one of the team.
+	// +optional
+	QuotaPreset string `json:"quotaPreset,omitempty"`
+	// Description is set as the description annotation of the namespace.
+	// +optional
+	Description string `json:"description,omitempty"`
+}
+
+// NamespaceClaimPhase is the state of a NamespaceClaim
+type NamespaceClaimPhase string
+
+const (
+	// NamespaceClaimPending claims are waiting for their namespace to be created.
+	NamespaceClaimPending NamespaceClaimPhase = "Pending"
+	// NamespaceClaimBound claims created their namespace.
+	NamespaceClaimBound NamespaceClaimPhase = "Bound"
+	// NamespaceClaimRejected claims were refused, the reason is recorded in the status.
+	NamespaceClaimRejected NamespaceClaimPhase = "Rejected"
+)
+
+// NamespaceClaimStatus defines the observed state of NamespaceClaim
+type NamespaceClaimStatus struct {
+	// ObservedGeneration is the most recent generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Phase of the claim, Bound and Rejected are final.
+	// +optional
+	Phase NamespaceClaimPhase `json:"phase,omitempty"`
+	// Reason of the phase in CamelCase.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable description of the phase.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=nsc
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Namespace",type="string",JSONPath=".spec.namespace"
+// +kubebuilder:printcolumn:name="Team",type="string",JSONPath=".spec.team"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// NamespaceClaim is the Schema for the namespaceclaims API.
+// Team members submit a claim to have a namespace created for their team without cluster rights.
+// The admission webhook records the user who created the claim in the kubenebula.io/requester and
+// kubenebula.io/requester-groups annotations, which can not be changed afterwards.
+type NamespaceClaim struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NamespaceClaimSpec   `json:"spec,omitempty"`
+	Status NamespaceClaimStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespaceClaimList contains a list of NamespaceClaim
+type NamespaceClaimList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceClaim `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespaceClaim{}, &NamespaceClaimList{})
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NamespaceRoleTemplateSpec defines a Role created in every team namespace
+type NamespaceRoleTemplateSpec struct {
+	// DisplayName is set as the alias-name annotation of the Role.
+	// +optional
+	DisplayName string `json:"displayName,omitempty"`
+	// Description is set as the description annotation of the Role.
+	// +optional
+	Description string `json:"description,omitempty"`
+	// Rules of the Role.
+	Rules []rbacv1.PolicyRule `json:"rules"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=nrt
+// +kubebuilder:printcolumn:name="Display Name",type="string",JSONPath=".spec.displayName"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// NamespaceRoleTemplate is the Schema for the namespaceroletemplates API.
+// Every team namespace gets a Role named after each template, replacing the built-in
+// admin, developer and viewer Roles as soon as one template exists.
+type NamespaceRoleTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NamespaceRoleTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespaceRoleTemplateList contains a list of NamespaceRoleTemplate
+type NamespaceRoleTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceRoleTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespaceRoleTemplate{}, &NamespaceRoleTemplateList{})
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// QuotaPresetSpec defines the ResourceQuota and LimitRange created in the namespaces using the preset
+type QuotaPresetSpec struct {
+	// DisplayName is a human readable name of the preset.
+	// +optional
+	DisplayName string `json:"displayName,omitempty"`
+	// Description of the preset.
+	// +optional
+	Description string `json:"description,omitempty"`
+	// ResourceQuota is the spec of the ResourceQuota of the namespace, no ResourceQuota is created when it is empty.
+	// +optional
+	ResourceQuota *corev1.ResourceQuotaSpec `json:"resourceQuota,omitempty"`
+	// LimitRange is the spec of the LimitRange of the namespace, no LimitRange is created when it is empty.
+	// +optional
+	LimitRange *corev1.LimitRangeSpec `json:"limitRange,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=qp
+// +kubebuilder:printcolumn:name="Display Name",type="string",JSONPath=".spec.displayName"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// QuotaPreset is the Schema for the quotapresets API.
+// Team namespaces referencing a preset, such as small, medium or large, through their team or the
+// quota-preset annotation get a ResourceQuota and a LimitRange maintained from it.
+type QuotaPreset struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec QuotaPresetSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// QuotaPresetList contains a list of QuotaPreset
+type QuotaPresetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []QuotaPreset `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&QuotaPreset{}, &QuotaPresetList{})
+}
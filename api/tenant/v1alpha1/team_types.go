@@ -17,24 +17,222 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// TeamDeletionPolicy describes what happens to the namespaces of a team when the team is deleted.
+// +kubebuilder:validation:Enum=Cascade;Orphan;Block
+type TeamDeletionPolicy string
+
+const (
+	// CascadeDeletionPolicy deletes the namespaces of the team together with the team.
+	CascadeDeletionPolicy TeamDeletionPolicy = "Cascade"
+	// OrphanDeletionPolicy keeps the namespaces and removes their team owner reference, labels and annotation.
+	OrphanDeletionPolicy TeamDeletionPolicy = "Orphan"
+	// BlockDeletionPolicy keeps the team from being deleted while it still has namespaces.
+	BlockDeletionPolicy TeamDeletionPolicy = "Block"
+)
+
+// NetworkIsolation describes which traffic NetworkPolicies allow into the namespaces of a team.
+// +kubebuilder:validation:Enum=none;team;namespace
+type NetworkIsolation string
+
+const (
+	// NoNetworkIsolation creates no NetworkPolicy.
+	NoNetworkIsolation NetworkIsolation = "none"
+	// TeamNetworkIsolation only allows traffic from the namespaces of the same team and the system namespaces.
+	TeamNetworkIsolation NetworkIsolation = "team"
+	// NamespaceNetworkIsolation only allows traffic from the same namespace and the system namespaces.
+	NamespaceNetworkIsolation NetworkIsolation = "namespace"
+)
+
 // TeamSpec defines the desired state of Team
 type TeamSpec struct {
+	// Manager is the user who owns the team, always bound to the team admin role.
 	Manager string `json:"manager,omitempty"`
+	// Admins are bound to the team:<name>:admin ClusterRole.
+	// Subjects may be of kind User, Group or ServiceAccount.
+	// The role lists are defaulted to empty lists by the admission webhook.
+	// +optional
+	// +nullable
+	Admins []rbacv1.Subject `json:"admins"`
+	// Regulars are bound to the team:<name>:regular ClusterRole.
+	// +optional
+	// +nullable
+	Regulars []rbacv1.Subject `json:"regulars"`
+	// Viewers are bound to the team:<name>:viewer ClusterRole.
+	// +optional
+	// +nullable
+	Viewers []rbacv1.Subject `json:"viewers"`
+	// DeletionPolicy decides what happens to the namespaces of the team when it is deleted.
+	// Defaults to Orphan.
+	// +optional
+	DeletionPolicy TeamDeletionPolicy `json:"deletionPolicy,omitempty"`
+	// Quota limits the resources used by all namespaces of the team together.
+	// +optional
+	Quota *TeamQuota `json:"quota,omitempty"`
+	// QuotaPreset is the name of the QuotaPreset applied to each namespace of the team,
+	// a namespace can override it with the quota-preset annotation.
+	// +optional
+	QuotaPreset string `json:"quotaPreset,omitempty"`
+	// NetworkIsolation decides which traffic is allowed into the namespaces of the team.
+	// Defaults to none.
+	// +optional
+	NetworkIsolation NetworkIsolation `json:"networkIsolation,omitempty"`
+	// Parent is the name of the parent team. The admins and viewers of every ancestor hold the same role
+	// in the team and its namespaces. A team with children can not be deleted.
+	// +optional
+	Parent string `json:"parent,omitempty"`
+}
+
+// ResourceNamespaces is the number of namespaces of a team, counted by TeamQuota.
+const ResourceNamespaces corev1.ResourceName = "namespaces"
+
+// TeamQuota is a hard limit on the total usage of the namespaces of a team.
+type TeamQuota struct {
+	// Hard limits by resource name. Supported are namespaces, pods, services, persistentvolumeclaims,
+	// requests.storage and the cpu and memory requests and limits.
+	Hard corev1.ResourceList `json:"hard,omitempty"`
+}
+
+// GetNetworkIsolation returns the network isolation of the team, defaulting to none.
+func (t *Team) GetNetworkIsolation() NetworkIsolation {
+	if t.Spec.NetworkIsolation == "" {
+		return NoNetworkIsolation
+	}
+	return t.Spec.NetworkIsolation
+}
+
+// GetDeletionPolicy returns the deletion policy of the team, defaulting to Orphan.
+func (t *Team) GetDeletionPolicy() TeamDeletionPolicy {
+	if t.Spec.DeletionPolicy == "" {
+		return OrphanDeletionPolicy
+	}
+	return t.Spec.DeletionPolicy
+}
+
+// TeamConditionType is a valid value for TeamCondition.Type
+type TeamConditionType string
+
+const (
+	// TeamReady is true when every reconcile step of the team succeeded.
+	TeamReady TeamConditionType = "Ready"
+	// TeamRolesReady is true when the team:<name>:<role> ClusterRoles are up to date.
+	TeamRolesReady TeamConditionType = "RolesReady"
+	// TeamBindingsReady is true when the team ClusterRoleBindings match the team members.
+	TeamBindingsReady TeamConditionType = "BindingsReady"
+	// TeamNamespacesBound is true when every namespace labelled for the team is bound to it.
+	TeamNamespacesBound TeamConditionType = "NamespacesBound"
+	// TeamParentResolved is true when the ancestors of the team exist and contain no cycle.
+	TeamParentResolved TeamConditionType = "ParentResolved"
+)
+
+// TeamCondition describes the state of a team at a certain point.
+type TeamCondition struct {
+	// Type of team condition.
+	Type TeamConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+	// Last time the condition transitioned from one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// The reason for the condition's last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// A human readable message indicating details about the transition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// TeamMemberCount counts the subjects bound to each team role.
+type TeamMemberCount struct {
+	Admins   int32 `json:"admins"`
+	Regulars int32 `json:"regulars"`
+	Viewers  int32 `json:"viewers"`
 }
 
 // TeamStatus defines the observed state of Team
 type TeamStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the most recent generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions represent the latest available observations of the team's state.
+	// +optional
+	Conditions []TeamCondition `json:"conditions,omitempty"`
+	// Namespaces bound to the team.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceCount is the number of namespaces bound to the team.
+	// +optional
+	NamespaceCount int32 `json:"namespaceCount,omitempty"`
+	// Members counts the subjects bound to each team role, including the admins and viewers inherited from the parent teams.
+	// +optional
+	Members TeamMemberCount `json:"members,omitempty"`
+	// Quota is the enforced hard limit and the current usage summed across the team namespaces.
+	// +optional
+	Quota *TeamQuotaStatus `json:"quota,omitempty"`
+	// Ancestors of the team, from its parent up to the root team.
+	// +optional
+	Ancestors []string `json:"ancestors,omitempty"`
+}
+
+// TeamQuotaStatus reports the usage of the team quota.
+type TeamQuotaStatus struct {
+	// Hard is the enforced hard limit.
+	// +optional
+	Hard corev1.ResourceList `json:"hard,omitempty"`
+	// Used is the usage of the limited resources across the team namespaces.
+	// +optional
+	Used corev1.ResourceList `json:"used,omitempty"`
+}
+
+// GetCondition returns the condition with the given type, or nil if it is not set.
+func (s *TeamStatus) GetCondition(conditionType TeamConditionType) *TeamCondition {
+	return getCondition(s.Conditions, conditionType)
+}
+
+// SetCondition adds or updates the condition with the given type.
+// LastTransitionTime is only changed when the status changes.
+func (s *TeamStatus) SetCondition(conditionType TeamConditionType, status corev1.ConditionStatus, reason, message string) {
+	setCondition(&s.Conditions, conditionType, status, reason, message)
+}
+
+func getCondition(conditions []TeamCondition, conditionType TeamConditionType) *TeamCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+func setCondition(conditions *[]TeamCondition, conditionType TeamConditionType, status corev1.ConditionStatus, reason, message string) {
+	condition := getCondition(*conditions, conditionType)
+	if condition == nil {
+		*conditions = append(*conditions, TeamCondition{Type: conditionType})
+		condition = &(*conditions)[len(*conditions)-1]
+	}
+	if condition.Status != status {
+		condition.Status = status
+		condition.LastTransitionTime = metav1.Now()
+	}
+	condition.Reason = reason
+	condition.Message = message
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=tm
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Manager",type="string",JSONPath=".spec.manager"
+// +kubebuilder:printcolumn:name="Parent",type="string",JSONPath=".spec.parent"
+// +kubebuilder:printcolumn:name="Namespaces",type="integer",JSONPath=".status.namespaceCount"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Team is the Schema for the teams API
 type Team struct {
@@ -0,0 +1,103 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TeamRoleSpec defines the desired state of TeamRole
+type TeamRoleSpec struct {
+	// Team the role belongs to.
+	Team string `json:"team"`
+	// RoleName is the name of the Role created in the team namespaces and the <role> in the
+	// team:<team>:<role> ClusterRole. Defaults to the name of the TeamRole.
+	// +optional
+	RoleName string `json:"roleName,omitempty"`
+	// Rules of the Role created in every namespace of the team.
+	// +optional
+	Rules []rbacv1.PolicyRule `json:"rules,omitempty"`
+	// TeamRules, when set, are the rules of a team:<team>:<role> ClusterRole.
+	// {{team}} is replaced by the team name.
+	// +optional
+	TeamRules []rbacv1.PolicyRule `json:"teamRules,omitempty"`
+	// Members are bound to the Role in every team namespace and to the team ClusterRole.
+	// +optional
+	Members []rbacv1.Subject `json:"members,omitempty"`
+}
+
+// TeamRoleStatus defines the observed state of TeamRole
+type TeamRoleStatus struct {
+	// ObservedGeneration is the most recent generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions represent the latest available observations of the role's state.
+	// +optional
+	Conditions []TeamCondition `json:"conditions,omitempty"`
+}
+
+// GetCondition returns the condition with the given type, or nil if it is not set.
+func (s *TeamRoleStatus) GetCondition(conditionType TeamConditionType) *TeamCondition {
+	return getCondition(s.Conditions, conditionType)
+}
+
+// SetCondition adds or updates the condition with the given type.
+// LastTransitionTime is only changed when the status changes.
+func (s *TeamRoleStatus) SetCondition(conditionType TeamConditionType, status corev1.ConditionStatus, reason, message string) {
+	setCondition(&s.Conditions, conditionType, status, reason, message)
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=tr
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Team",type="string",JSONPath=".spec.team"
+// +kubebuilder:printcolumn:name="Role",type="string",JSONPath=".spec.roleName"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// TeamRole is the Schema for the teamroles API.
+// It adds a custom role to a team next to the built-in admin, regular and viewer roles.
+type TeamRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TeamRoleSpec   `json:"spec,omitempty"`
+	Status TeamRoleStatus `json:"status,omitempty"`
+}
+
+// GetRoleName returns the name of the role, defaulting to the name of the TeamRole.
+func (t *TeamRole) GetRoleName() string {
+	if t.Spec.RoleName == "" {
+		return t.Name
+	}
+	return t.Spec.RoleName
+}
+
+// +kubebuilder:object:root=true
+
+// TeamRoleList contains a list of TeamRole
+type TeamRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TeamRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TeamRole{}, &TeamRoleList{})
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Names of the TeamRoleTemplates rendered for every team, one per built-in team role.
+const (
+	TeamAdminTemplate   = "admin"
+	TeamRegularTemplate = "regular"
+	TeamViewerTemplate  = "viewer"
+)
+
+// TeamNamePlaceholder is replaced by the team name in the rules of a TeamRoleTemplate.
+const TeamNamePlaceholder = "{{team}}"
+
+// TeamRoleTemplateSpec defines the team ClusterRole rendered for every team
+type TeamRoleTemplateSpec struct {
+	// DisplayName is set as the alias-name annotation of the rendered ClusterRole.
+	// +optional
+	DisplayName string `json:"displayName,omitempty"`
+	// Description is set as the description annotation of the rendered ClusterRole.
+	// +optional
+	Description string `json:"description,omitempty"`
+	// Rules of the rendered ClusterRole. {{team}} is replaced by the team name,
+	// typically in resourceNames.
+	Rules []rbacv1.PolicyRule `json:"rules"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=trt
+// +kubebuilder:printcolumn:name="Display Name",type="string",JSONPath=".spec.displayName"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// TeamRoleTemplate is the Schema for the teamroletemplates API.
+// The template named admin, regular or viewer replaces the built-in rules of that team role.
+type TeamRoleTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TeamRoleTemplateSpec `json:"spec,omitempty"`
+}
+
+// RenderRules returns the rules of the template with the placeholder replaced by the team name.
+func (t *TeamRoleTemplate) RenderRules(teamName string) []rbacv1.PolicyRule {
+	return RenderRules(t.Spec.Rules, teamName)
+}
+
+// RenderRules returns a copy of rules with TeamNamePlaceholder replaced by the team name.
+func RenderRules(rules []rbacv1.PolicyRule, teamName string) []rbacv1.PolicyRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	render := func(values []string) []string {
+		if values == nil {
+			return nil
+		}
+		rendered := make([]string, len(values))
+		for i, value := range values {
+			rendered[i] = strings.Replace(value, TeamNamePlaceholder, teamName, -1)
+		}
+		return rendered
+	}
+	rendered := make([]rbacv1.PolicyRule, len(rules))
+	for i, rule := range rules {
+		rendered[i] = rbacv1.PolicyRule{
+			Verbs:           render(rule.Verbs),
+			APIGroups:       render(rule.APIGroups),
+			Resources:       render(rule.Resources),
+			ResourceNames:   render(rule.ResourceNames),
+			NonResourceURLs: render(rule.NonResourceURLs),
+		}
+	}
+	return rendered
+}
+
+// +kubebuilder:object:root=true
+
+// TeamRoleTemplateList contains a list of TeamRoleTemplate
+type TeamRoleTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TeamRoleTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TeamRoleTemplate{}, &TeamRoleTemplateList{})
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/tenant"
+	"kubenebula.io/kubenebula/utils/sliceutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// authenticationConfigMapName is the ConfigMap in kube-system publishing the front proxy configuration
+// of the kube-apiserver to aggregated API servers
+const authenticationConfigMapName = "extension-apiserver-authentication"
+
+// Keys of the front proxy configuration in the authentication ConfigMap
+const (
+	clientCAKey        = "requestheader-client-ca-file"
+	allowedNamesKey    = "requestheader-allowed-names"
+	usernameHeadersKey = "requestheader-username-headers"
+	groupHeadersKey    = "requestheader-group-headers"
+)
+
+// requestHeaderAuthenticator trusts the user name and group headers set by the kube-apiserver,
+// which proxies requests with a client certificate signed by the front proxy CA
+type requestHeaderAuthenticator struct {
+	clientCAs       *x509.CertPool
+	allowedNames    []string
+	usernameHeaders []string
+	groupHeaders    []string
+}
+
+// loadRequestHeaderAuthenticator reads the front proxy configuration from the authentication ConfigMap
+func loadRequestHeaderAuthenticator(ctx context.Context, reader client.Reader) (*requestHeaderAuthenticator, error) {
+	configMap := &corev1.ConfigMap{}
+	key := types.NamespacedName{Namespace: constants.KubeSystemNamespace, Name: authenticationConfigMapName}
+	if err := reader.Get(ctx, key, configMap); err != nil {
+		return nil, err
+	}
+	return newRequestHeaderAuthenticator(configMap.Data)
+}
+
+func newRequestHeaderAuthenticator(data map[string]string) (*requestHeaderAuthenticator, error) {
+	a := &requestHeaderAuthenticator{clientCAs: x509.NewCertPool()}
+	if !a.clientCAs.AppendCertsFromPEM([]byte(data[clientCAKey])) {
+		return nil, fmt.Errorf("no front proxy client CA in %s", clientCAKey)
+	}
+	for key, value := range map[string]*[]string{
+		allowedNamesKey:    &a.allowedNames,
+		usernameHeadersKey: &a.usernameHeaders,
+		groupHeadersKey:    &a.groupHeaders,
+	} {
+		if data[key] == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(data[key]), value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", key, err)
+		}
+	}
+	if len(a.usernameHeaders) == 0 {
+		a.usernameHeaders = []string{"X-Remote-User"}
+	}
+	if len(a.groupHeaders) == 0 {
+		a.groupHeaders = []string{"X-Remote-Group"}
+	}
+	return a, nil
+}
+
+// authenticate returns the user of a request proxied by the kube-apiserver. The TLS listener verifies
+// client certificates against the front proxy CA, unverified connections are not authenticated.
+func (a *requestHeaderAuthenticator) authenticate(r *http.Request) (tenant.User, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.PeerCertificates) == 0 {
+		return tenant.User{}, false
+	}
+	if len(a.allowedNames) > 0 && !sliceutil.HasString(a.allowedNames, r.TLS.PeerCertificates[0].Subject.CommonName) {
+		return tenant.User{}, false
+	}
+	var user tenant.User
+	for _, header := range a.usernameHeaders {
+		if user.Name = r.Header.Get(header); user.Name != "" {
+			break
+		}
+	}
+	if user.Name == "" {
+		return tenant.User{}, false
+	}
+	for _, header := range a.groupHeaders {
+		user.Groups = append(user.Groups, r.Header[http.CanonicalHeaderKey(header)]...)
+	}
+	return user, true
+}
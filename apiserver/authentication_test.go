@@ -0,0 +1,119 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"kubenebula.io/kubenebula/tenant"
+)
+
+// withClientCertificate returns the TLS state of a connection with a verified client certificate of commonName
+func withClientCertificate(commonName string) *tls.ConnectionState {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+}
+
+func testCAPEM(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "front-proxy-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestNewRequestHeaderAuthenticator(t *testing.T) {
+	if _, err := newRequestHeaderAuthenticator(map[string]string{}); err == nil {
+		t.Error("expected an error without client CA")
+	}
+	a, err := newRequestHeaderAuthenticator(map[string]string{
+		clientCAKey:     testCAPEM(t),
+		allowedNamesKey: `["front-proxy-client"]`,
+		groupHeadersKey: `["X-Remote-Group","X-Extra-Group"]`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a.allowedNames, []string{"front-proxy-client"}) ||
+		!reflect.DeepEqual(a.usernameHeaders, []string{"X-Remote-User"}) ||
+		!reflect.DeepEqual(a.groupHeaders, []string{"X-Remote-Group", "X-Extra-Group"}) {
+		t.Errorf("unexpected authenticator %+v", a)
+	}
+	if _, err := newRequestHeaderAuthenticator(map[string]string{clientCAKey: testCAPEM(t), allowedNamesKey: "front-proxy-client"}); err == nil {
+		t.Error("expected an error for allowed names that are not a JSON list")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	a := &requestHeaderAuthenticator{
+		allowedNames:    []string{"front-proxy-client"},
+		usernameHeaders: []string{"X-Remote-User"},
+		groupHeaders:    []string{"X-Remote-Group"},
+	}
+	tests := []struct {
+		name     string
+		tls      *tls.ConnectionState
+		username string
+		expected tenant.User
+		ok       bool
+	}{
+		{"proxied", withClientCertificate("front-proxy-client"), "alice", tenant.User{Name: "alice", Groups: []string{"devs", "system:authenticated"}}, true},
+		{"no client certificate", &tls.ConnectionState{}, "alice", tenant.User{}, false},
+		{"plain http", nil, "alice", tenant.User{}, false},
+		{"name not allowed", withClientCertificate("someone"), "alice", tenant.User{}, false},
+		{"no user", withClientCertificate("front-proxy-client"), "", tenant.User{}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/apis/"+GroupVersion.String(), nil)
+			r.TLS = test.tls
+			if test.username != "" {
+				r.Header.Set("X-Remote-User", test.username)
+			}
+			r.Header.Add("X-Remote-Group", "devs")
+			r.Header.Add("X-Remote-Group", "system:authenticated")
+			user, ok := a.authenticate(r)
+			if ok != test.ok || !reflect.DeepEqual(user, test.expected) {
+				t.Errorf("authenticate() = %v, %v, expected %v, %v", user, ok, test.expected, test.ok)
+			}
+		})
+	}
+}
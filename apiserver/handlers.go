@@ -0,0 +1,234 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"kubenebula.io/kubenebula/tenant"
+	"kubenebula.io/kubenebula/utils/httputil"
+)
+
+// apiResources are the resources listed by discovery
+var apiResources = []metav1.APIResource{
+	{Name: "memberships", Kind: "Membership", Verbs: metav1.Verbs{"list"}},
+	{Name: "teams/namespaces", Kind: "Namespace", Verbs: metav1.Verbs{"get", "create"}},
+	{Name: "teams/members", Kind: "TeamMember", Verbs: metav1.Verbs{"get", "create", "delete"}},
+}
+
+// verbs maps HTTP methods to the verbs authorized on the team subresources
+var verbs = map[string]string{
+	http.MethodGet:    "get",
+	http.MethodPost:   "create",
+	http.MethodDelete: "delete",
+}
+
+type handler struct {
+	service *tenant.Service
+	authn   *requestHeaderAuthenticator
+	authz   tenant.Authorizer
+}
+
+func newHandler(service *tenant.Service, authn *requestHeaderAuthenticator, authz tenant.Authorizer) http.Handler {
+	h := &handler{service: service, authn: authn, authz: authz}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/apis/"+GroupName, h.authenticated(h.serveGroup))
+	mux.HandleFunc("/apis/"+GroupVersion.String()+"/", h.authenticated(h.serveVersion))
+	mux.HandleFunc("/apis/"+GroupVersion.String(), h.authenticated(h.serveVersion))
+	return mux
+}
+
+// authenticated rejects requests that were not proxied by the kube-apiserver
+func (h *handler) authenticated(next func(http.ResponseWriter, *http.Request, tenant.User)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := h.authn.authenticate(r)
+		if !ok {
+			httputil.WriteError(w, errors.NewUnauthorized("the request was not authenticated by the kube-apiserver"))
+			return
+		}
+		next(w, r, user)
+	}
+}
+
+func (h *handler) serveGroup(w http.ResponseWriter, r *http.Request, _ tenant.User) {
+	version := metav1.GroupVersionForDiscovery{GroupVersion: GroupVersion.String(), Version: GroupVersion.Version}
+	httputil.WriteJSON(w, http.StatusOK, &metav1.APIGroup{
+		TypeMeta:         metav1.TypeMeta{APIVersion: "v1", Kind: "APIGroup"},
+		Name:             GroupName,
+		Versions:         []metav1.GroupVersionForDiscovery{version},
+		PreferredVersion: version,
+	})
+}
+
+func (h *handler) serveVersion(w http.ResponseWriter, r *http.Request, user tenant.User) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/apis/"+GroupVersion.String()), "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		httputil.WriteJSON(w, http.StatusOK, &metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{APIVersion: "v1", Kind: "APIResourceList"},
+			GroupVersion: GroupVersion.String(),
+			APIResources: apiResources,
+		})
+	case path == "memberships" && r.Method == http.MethodGet:
+		h.listMemberships(w, r, user)
+	case len(parts) == 3 && parts[0] == "teams" && (parts[2] == "namespaces" || parts[2] == "members"):
+		h.serveTeam(w, r, user, parts[1], parts[2])
+	default:
+		httputil.WriteError(w, errors.NewGenericServerResponse(http.StatusNotFound, r.Method, schema.GroupResource{Group: GroupName}, "", "", 0, false))
+	}
+}
+
+// serveTeam authorizes and serves a team subresource
+func (h *handler) serveTeam(w http.ResponseWriter, r *http.Request, user tenant.User, team, subresource string) {
+	verb, ok := verbs[r.Method]
+	if !ok || (subresource == "namespaces" && verb == "delete") {
+		httputil.WriteError(w, errors.NewMethodNotSupported(GroupVersion.WithResource("teams/"+subresource).GroupResource(), r.Method))
+		return
+	}
+	allowed, reason, err := h.authz.Authorize(r.Context(), user, tenant.TeamAttributes(verb, team, subresource))
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	if !allowed {
+		httputil.WriteError(w, errors.NewForbidden(GroupVersion.WithResource("teams/"+subresource).GroupResource(), team,
+			fmt.Errorf("user %q cannot %s teams/%s of team %q: %s", user.Name, verb, subresource, team, reason)))
+		return
+	}
+
+	switch subresource + "/" + verb {
+	case "namespaces/get":
+		h.listNamespaces(w, r, team)
+	case "namespaces/create":
+		h.createNamespace(w, r, user, team)
+	case "members/get":
+		h.listMembers(w, r, team)
+	case "members/create":
+		h.addMember(w, r, team)
+	case "members/delete":
+		h.removeMember(w, r, team)
+	}
+}
+
+func (h *handler) listNamespaces(w http.ResponseWriter, r *http.Request, team string) {
+	namespaces, err := h.service.ListNamespaces(r.Context(), team)
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, &corev1.NamespaceList{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "NamespaceList"},
+		Items:    namespaces,
+	})
+}
+
+func (h *handler) createNamespace(w http.ResponseWriter, r *http.Request, user tenant.User, team string) {
+	namespace := &corev1.Namespace{}
+	if err := httputil.ReadBody(r, namespace); err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	created, err := h.service.CreateNamespace(r.Context(), team, namespace, user.Name)
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	log.Info("Created team namespace", "team", team, "namespace", created.Name, "user", user.Name)
+	created.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"}
+	httputil.WriteJSON(w, http.StatusCreated, created)
+}
+
+func (h *handler) listMembers(w http.ResponseWriter, r *http.Request, team string) {
+	members, err := h.service.ListMembers(r.Context(), team)
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	list := &TeamMemberList{
+		TypeMeta: metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: "TeamMemberList"},
+		Items:    make([]TeamMember, 0, len(members)),
+	}
+	for _, member := range members {
+		list.Items = append(list.Items, TeamMember{Role: member.Role, Subject: member.Subject})
+	}
+	httputil.WriteJSON(w, http.StatusOK, list)
+}
+
+func (h *handler) addMember(w http.ResponseWriter, r *http.Request, team string) {
+	member := &TeamMember{}
+	if err := httputil.ReadBody(r, member); err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	added, err := h.service.AddMember(r.Context(), team, tenant.Member{Role: member.Role, Subject: member.Subject})
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusCreated, &TeamMember{
+		TypeMeta: metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: "TeamMember"},
+		Role:     added.Role,
+		Subject:  added.Subject,
+	})
+}
+
+// removeMember removes the member given by the role, kind, name and namespace query parameters
+func (h *handler) removeMember(w http.ResponseWriter, r *http.Request, team string) {
+	query := r.URL.Query()
+	member := tenant.Member{
+		Role:    query.Get("role"),
+		Subject: rbac.Subject{Kind: query.Get("kind"), Name: query.Get("name"), Namespace: query.Get("namespace")},
+	}
+	if err := h.service.RemoveMember(r.Context(), team, member); err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, &metav1.Status{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+		Status:   metav1.StatusSuccess,
+	})
+}
+
+func (h *handler) listMemberships(w http.ResponseWriter, r *http.Request, user tenant.User) {
+	memberships, err := h.service.Memberships(r.Context(), user)
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	list := &MembershipList{
+		TypeMeta: metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: "MembershipList"},
+		Items:    make([]Membership, 0, len(memberships)),
+	}
+	for _, membership := range memberships {
+		list.Items = append(list.Items, Membership{
+			ObjectMeta: metav1.ObjectMeta{Name: membership.Team},
+			Roles:      membership.Roles,
+		})
+	}
+	httputil.WriteJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/tenant"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeAuthorizer allows the requests listed as "<user> <verb> <team>/<subresource>"
+type fakeAuthorizer map[string]bool
+
+func (a fakeAuthorizer) Authorize(ctx context.Context, user tenant.User, attributes authorizationv1.ResourceAttributes) (bool, string, error) {
+	return a[user.Name+" "+attributes.Verb+" "+attributes.Name+"/"+attributes.Subresource], "", nil
+}
+
+func TestHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	service := &tenant.Service{Client: fake.NewFakeClientWithScheme(scheme,
+		&tenantv1alpha1.Team{
+			ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+			Spec: tenantv1alpha1.TeamSpec{
+				Manager:  "alice",
+				Regulars: []rbac.Subject{{Kind: rbac.UserKind, Name: "bob"}},
+			},
+		},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-prod", Labels: teamutil.Labels("nebula")}},
+	)}
+	authn := &requestHeaderAuthenticator{usernameHeaders: []string{"X-Remote-User"}, groupHeaders: []string{"X-Remote-Group"}}
+	authz := fakeAuthorizer{
+		"alice get nebula/namespaces":    true,
+		"alice get nebula/members":       true,
+		"alice create nebula/members":    true,
+		"alice delete nebula/members":    true,
+		"bob create nebula/namespaces":   true,
+		"bob get nebula/namespaces":      true,
+		"alice get missing/namespaces":   true,
+		"alice create nebula/namespaces": true,
+	}
+	h := newHandler(service, authn, authz)
+	prefix := "/apis/" + GroupVersion.String()
+
+	tests := []struct {
+		name     string
+		user     string
+		method   string
+		path     string
+		body     string
+		code     int
+		contains string
+	}{
+		{"unauthenticated", "", "GET", prefix, "", http.StatusUnauthorized, ""},
+		{"discovery", "bob", "GET", prefix, "", http.StatusOK, `"name":"teams/namespaces"`},
+		{"group", "bob", "GET", "/apis/" + GroupName, "", http.StatusOK, `"preferredVersion"`},
+		{"memberships", "bob", "GET", prefix + "/memberships", "", http.StatusOK, `"roles":["regular"]`},
+		{"create namespace", "bob", "POST", prefix + "/teams/nebula/namespaces", "metadata:\n  name: nebula-dev\n", http.StatusCreated, `"kubenebula.io/creator":"bob"`},
+		{"list namespaces", "bob", "GET", prefix + "/teams/nebula/namespaces", "", http.StatusOK, `"name":"nebula-dev"`},
+		{"invalid namespace", "alice", "POST", prefix + "/teams/nebula/namespaces", `{"metadata":{"name":"kube-x"}}`, http.StatusUnprocessableEntity, ""},
+		{"missing team", "alice", "GET", prefix + "/teams/missing/namespaces", "", http.StatusNotFound, ""},
+		{"forbidden", "bob", "GET", prefix + "/teams/nebula/members", "", http.StatusForbidden, `cannot get teams/members`},
+		{"add member", "alice", "POST", prefix + "/teams/nebula/members", `{"role":"viewer","subject":{"kind":"Group","name":"auditors"}}`, http.StatusCreated, `"apiGroup":"rbac.authorization.k8s.io"`},
+		{"list members", "alice", "GET", prefix + "/teams/nebula/members", "", http.StatusOK, `"name":"auditors"`},
+		{"remove member", "alice", "DELETE", prefix + "/teams/nebula/members?role=regular&kind=User&name=bob", "", http.StatusOK, `"Success"`},
+		{"remove missing member", "alice", "DELETE", prefix + "/teams/nebula/members?role=regular&kind=User&name=bob", "", http.StatusNotFound, ""},
+		{"unsupported method", "alice", "DELETE", prefix + "/teams/nebula/namespaces", "", http.StatusMethodNotAllowed, ""},
+		{"unknown resource", "alice", "GET", prefix + "/projects", "", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			r.TLS = withClientCertificate("front-proxy-client")
+			if test.user != "" {
+				r.Header.Set("X-Remote-User", test.user)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != test.code || !strings.Contains(w.Body.String(), test.contains) {
+				t.Fatalf("%s %s = %d %s, expected %d containing %s", test.method, test.path, w.Code, w.Body, test.code, test.contains)
+			}
+			if w.Code >= 400 {
+				status := &metav1.Status{}
+				if err := json.Unmarshal(w.Body.Bytes(), status); err != nil || status.Kind != "Status" || int(status.Code) != test.code {
+					t.Errorf("expected a Status, got %s", w.Body)
+				}
+			}
+		})
+	}
+
+	namespace := &corev1.Namespace{}
+	if err := service.Get(context.TODO(), client.ObjectKey{Name: "nebula-dev"}, namespace); err != nil {
+		t.Fatal(err)
+	}
+	if namespace.Annotations[constants.TeamAnnotationKey] != "nebula" {
+		t.Errorf("annotations of the created namespace = %v", namespace.Annotations)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package apiserver serves the api.kubenebula.io aggregated API, registered with the kube-apiserver
+// through an APIService. It exposes the namespaces and members of teams as teams/<name>/namespaces
+// and teams/<name>/members, and the teams of the caller as memberships.
+package apiserver
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"kubenebula.io/kubenebula/tenant"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("apiserver")
+
+// Server is a manager Runnable serving the aggregated API over TLS
+type Server struct {
+	// Service implements the team operations.
+	Service *tenant.Service
+	// Authorizer authorizes the requests to team subresources.
+	Authorizer tenant.Authorizer
+	// Reader reads the front proxy configuration from kube-system without going through the cache.
+	Reader client.Reader
+	// Host and Port the server listens on.
+	Host string
+	Port int
+	// CertDir contains the tls.crt and tls.key serving certificate.
+	CertDir string
+}
+
+// NeedLeaderElection implements the LeaderElectionRunnable interface, every replica serves the API.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the API until stop is closed.
+func (s *Server) Start(stop <-chan struct{}) error {
+	authn, err := loadRequestHeaderAuthenticator(context.Background(), s.Reader)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(filepath.Join(s.CertDir, "tls.crt"), filepath.Join(s.CertDir, "tls.key"))
+	if err != nil {
+		return err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    authn.clientCAs,
+	}
+	listener, err := tls.Listen("tcp", net.JoinHostPort(s.Host, strconv.Itoa(s.Port)), cfg)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: newHandler(s.Service, authn, s.Authorizer)}
+	idleConnsClosed := make(chan struct{})
+	go func() {
+		<-stop
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Error(err, "error shutting down the aggregated API server")
+		}
+		close(idleConnsClosed)
+	}()
+
+	log.Info("Serving aggregated API", "group", GroupVersion.String(), "port", s.Port)
+	if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	<-idleConnsClosed
+	return nil
+}
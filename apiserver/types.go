@@ -0,0 +1,64 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// The types of the aggregated API are not custom resources.
+// +kubebuilder:skip
+
+package apiserver
+
+import (
+	rbac "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"kubenebula.io/kubenebula/constants"
+)
+
+// GroupName is the API group served by the aggregated API server
+const GroupName = "api.kubenebula.io"
+
+// GroupVersion is the group version served by the aggregated API server
+var GroupVersion = schema.GroupVersion{Group: GroupName, Version: constants.APIVersion}
+
+// TeamMember is a subject holding a built-in team role, served by teams/<name>/members
+type TeamMember struct {
+	metav1.TypeMeta `json:",inline"`
+	// Role is admin, regular or viewer.
+	Role string `json:"role"`
+	// Subject is a User, Group or ServiceAccount.
+	Subject rbac.Subject `json:"subject"`
+}
+
+// TeamMemberList contains the members of a team
+type TeamMemberList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TeamMember `json:"items"`
+}
+
+// Membership is a team the caller belongs to, named after the team
+type Membership struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Roles the caller holds in the team.
+	Roles []string `json:"roles"`
+}
+
+// MembershipList contains the teams the caller belongs to
+type MembershipList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Membership `json:"items"`
+}
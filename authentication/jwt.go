@@ -0,0 +1,355 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authentication
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"time"
+
+	"kubenebula.io/kubenebula/constants"
+
+	// register the hashes of the supported algorithms
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// Claims are the JWT claims of a token
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	// Groups are added to the team groups of the user.
+	Groups []string `json:"groups,omitempty"`
+}
+
+// audience is a single audience string or an array of audiences
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+// header is the JOSE header of a token
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// algorithms are the supported signature algorithms by name
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// Key is a public key verifying tokens, selected by the kid header when both have an ID
+type Key struct {
+	ID        string
+	PublicKey crypto.PublicKey
+	// Local marks the key of the tokens issued by the login endpoint, only they may name a built-in user.
+	Local bool
+}
+
+// verify verifies signature of signed with algorithm
+func (k *Key) verify(algorithm string, signed, signature []byte) bool {
+	hash, ok := algorithms[algorithm]
+	if !ok {
+		return false
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch key := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(algorithm, "RS") && rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(algorithm, "ES") || len(signature) != 2*size {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+// reservedSubjectPrefix is the prefix of the users of the kube-apiserver and its components, such as
+// system:admin or system:serviceaccount:kube-system:..., that no token may name
+const reservedSubjectPrefix = "system:"
+
+// Verifier validates the signature, lifetime, issuer and audience of tokens
+type Verifier struct {
+	Keys []Key
+	// Issuer is the required iss claim, empty accepts any issuer.
+	Issuer string
+	// Audiences lists the accepted aud claims, empty accepts any audience.
+	Audiences []string
+	// Now returns the current time, time.Now when nil.
+	Now func() time.Time
+}
+
+// Verify returns the claims of a valid token
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	h := &header{}
+	if err := decodeSegment(parts[0], h); err != nil {
+		return nil, fmt.Errorf("malformed token header: %v", err)
+	}
+	if _, ok := algorithms[h.Algorithm]; !ok {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", h.Algorithm)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %v", err)
+	}
+	var verified *Key
+	for i := range v.Keys {
+		key := &v.Keys[i]
+		if h.KeyID != "" && key.ID != "" && h.KeyID != key.ID {
+			continue
+		}
+		if key.verify(h.Algorithm, []byte(parts[0]+"."+parts[1]), signature) {
+			verified = key
+			break
+		}
+	}
+	if verified == nil {
+		return nil, errors.New("invalid token signature")
+	}
+
+	claims := &Claims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %v", err)
+	}
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	switch unix := now().Unix(); {
+	case claims.ExpiresAt == 0:
+		return nil, errors.New("token without expiration")
+	case unix >= claims.ExpiresAt:
+		return nil, errors.New("token expired")
+	case unix < claims.NotBefore:
+		return nil, errors.New("token not valid yet")
+	}
+	if v.Issuer != "" && claims.Issuer != v.Issuer {
+		return nil, fmt.Errorf("unexpected token issuer %q", claims.Issuer)
+	}
+	if len(v.Audiences) > 0 && !intersects(v.Audiences, claims.Audience) {
+		return nil, fmt.Errorf("unexpected token audience %q", claims.Audience)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token without subject")
+	}
+	if strings.HasPrefix(claims.Subject, reservedSubjectPrefix) {
+		return nil, fmt.Errorf("token subject %q is reserved for the kube-apiserver", claims.Subject)
+	}
+	if strings.HasPrefix(claims.Subject, constants.BuiltinUserPrefix) && !verified.Local {
+		return nil, fmt.Errorf("token subject %q is reserved for the built-in users", claims.Subject)
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, obj interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, obj)
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonWebKey is a public key of a JSON Web Key Set
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	// RSA modulus and exponent
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC curve and coordinates
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+// ParseJWKS returns the signature keys of a JSON Web Key Set, keys of other types and uses are skipped
+func ParseJWKS(data []byte) ([]Key, error) {
+	set := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	var keys []Key
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var publicKey crypto.PublicKey
+		switch jwk.KeyType {
+		case "RSA":
+			n, err := decodeInt(jwk.N)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: invalid modulus: %v", jwk.KeyID, err)
+			}
+			e, err := decodeInt(jwk.E)
+			if err != nil || !e.IsInt64() {
+				return nil, fmt.Errorf("key %q: invalid exponent", jwk.KeyID)
+			}
+			publicKey = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			curve, ok := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}[jwk.Curve]
+			if !ok {
+				return nil, fmt.Errorf("key %q: unsupported curve %q", jwk.KeyID, jwk.Curve)
+			}
+			x, errX := decodeInt(jwk.X)
+			y, errY := decodeInt(jwk.Y)
+			if errX != nil || errY != nil || !curve.IsOnCurve(x, y) {
+				return nil, fmt.Errorf("key %q: invalid point", jwk.KeyID)
+			}
+			publicKey = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		default:
+			continue
+		}
+		keys = append(keys, Key{ID: jwk.KeyID, PublicKey: publicKey})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA or EC signature keys")
+	}
+	return keys, nil
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// ParsePEMKey returns the public key of a PEM encoded RSA or EC public key, certificate or private key,
+// and the private key when given one
+func ParsePEMKey(data []byte) (crypto.PublicKey, crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, errors.New("no PEM data")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	switch key := key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil, nil
+	case *rsa.PrivateKey:
+		return key.Public(), key, nil
+	case *ecdsa.PrivateKey:
+		return key.Public(), key, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+// LoadKeys reads the keys of a JWKS file and of a PEM signing key file, either may be empty
+func LoadKeys(jwksFile, signingKeyFile string) ([]Key, error) {
+	var keys []Key
+	if jwksFile != "" {
+		data, err := ioutil.ReadFile(jwksFile)
+		if err != nil {
+			return nil, err
+		}
+		if keys, err = ParseJWKS(data); err != nil {
+			return nil, fmt.Errorf("%s: %v", jwksFile, err)
+		}
+	}
+	if signingKeyFile != "" {
+		data, err := ioutil.ReadFile(signingKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, _, err := ParsePEMKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", signingKeyFile, err)
+		}
+		keys = append(keys, Key{PublicKey: publicKey, Local: true})
+	}
+	return keys, nil
+}
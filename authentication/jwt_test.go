@@ -0,0 +1,179 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authentication
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"kubenebula.io/kubenebula/constants"
+)
+
+var testNow = time.Unix(1570000000, 0)
+
+// signToken signs claims with key, the way an external issuer would
+func signToken(t *testing.T, key crypto.Signer, algorithm, keyID string, claims interface{}) string {
+	encode := func(obj interface{}) string {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(&header{Algorithm: algorithm, KeyID: keyID, Type: "JWT"}) + "." + encode(claims)
+	signature, err := sign(key, algorithm, []byte(signed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() *Claims {
+	return &Claims{Issuer: "kubenebula", Subject: "alice", Audience: audience{"kubernetes"}, ExpiresAt: testNow.Add(time.Hour).Unix()}
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	verifier := &Verifier{
+		Keys:      []Key{{ID: "rsa", PublicKey: rsaKey.Public()}, {ID: "ec", PublicKey: ecKey.Public(), Local: true}},
+		Issuer:    "kubenebula",
+		Audiences: []string{"kubernetes"},
+		Now:       func() time.Time { return testNow },
+	}
+	claims := func(mutate func(*Claims)) *Claims {
+		c := validClaims()
+		mutate(c)
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"RS256", signToken(t, rsaKey, "RS256", "rsa", validClaims()), true},
+		{"ES256", signToken(t, ecKey, "ES256", "ec", validClaims()), true},
+		{"without key ID", signToken(t, rsaKey, "RS512", "", validClaims()), true},
+		{"audience string", signToken(t, ecKey, "ES256", "", map[string]interface{}{"sub": "alice", "iss": "kubenebula", "aud": "kubernetes", "exp": testNow.Unix() + 60}), true},
+		{"wrong key ID", signToken(t, rsaKey, "RS256", "ec", validClaims()), false},
+		{"unknown key", signToken(t, otherKey, "RS256", "", validClaims()), false},
+		{"algorithm mismatch", signToken(t, rsaKey, "ES256", "rsa", validClaims()), false},
+		{"none", "eyJhbGciOiJub25lIn0.eyJzdWIiOiJhbGljZSJ9.", false},
+		{"malformed", "token", false},
+		{"expired", signToken(t, rsaKey, "RS256", "", claims(func(c *Claims) { c.ExpiresAt = testNow.Unix() })), false},
+		{"without expiration", signToken(t, rsaKey, "RS256", "", claims(func(c *Claims) { c.ExpiresAt = 0 })), false},
+		{"not valid yet", signToken(t, rsaKey, "RS256", "", claims(func(c *Claims) { c.NotBefore = testNow.Unix() + 1 })), false},
+		{"wrong issuer", signToken(t, rsaKey, "RS256", "", claims(func(c *Claims) { c.Issuer = "dex" })), false},
+		{"wrong audience", signToken(t, rsaKey, "RS256", "", claims(func(c *Claims) { c.Audience = audience{"vault"} })), false},
+		{"without subject", signToken(t, rsaKey, "RS256", "", claims(func(c *Claims) { c.Subject = "" })), false},
+	}
+	for _, test := range tests {
+		claims, err := verifier.Verify(test.token)
+		if test.valid && (err != nil || claims.Subject != "alice") {
+			t.Errorf("%s: Verify() = %+v, %v", test.name, claims, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: Verify() accepted the token", test.name)
+		}
+	}
+
+	// only the local key issues tokens for the built-in users
+	builtin := claims(func(c *Claims) { c.Subject = constants.BuiltinUserPrefix + "alice" })
+	if _, err := verifier.Verify(signToken(t, ecKey, "ES256", "ec", builtin)); err != nil {
+		t.Errorf("built-in user rejected with the local key: %v", err)
+	}
+	if _, err := verifier.Verify(signToken(t, rsaKey, "RS256", "rsa", builtin)); err == nil {
+		t.Error("built-in user accepted with an external key")
+	}
+
+	// no key issues tokens for the kube-apiserver users
+	system := claims(func(c *Claims) { c.Subject = "system:kube-controller-manager" })
+	for _, token := range []string{signToken(t, ecKey, "ES256", "ec", system), signToken(t, rsaKey, "RS256", "rsa", system)} {
+		if _, err := verifier.Verify(token); err == nil {
+			t.Error("system: subject accepted")
+		}
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	encode := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": %q, "e": %q},
+		{"kty": "EC", "kid": "ec", "crv": "P-384", "x": %q, "y": %q},
+		{"kty": "RSA", "kid": "encryption", "use": "enc", "n": %q, "e": "AQAB"},
+		{"kty": "oct", "kid": "secret", "k": "c2VjcmV0"}
+	]}`, encode(rsaKey.N), encode(big.NewInt(int64(rsaKey.E))), encode(ecKey.X), encode(ecKey.Y), encode(rsaKey.N))
+
+	keys, err := ParseJWKS([]byte(jwks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].ID != "rsa" || keys[1].ID != "ec" {
+		t.Fatalf("ParseJWKS() = %+v", keys)
+	}
+	verifier := &Verifier{Keys: keys, Now: func() time.Time { return testNow }}
+	for _, token := range []string{signToken(t, rsaKey, "RS256", "rsa", validClaims()), signToken(t, ecKey, "ES384", "ec", validClaims())} {
+		if _, err := verifier.Verify(token); err != nil {
+			t.Errorf("Verify() = %v", err)
+		}
+	}
+
+	if _, err := ParseJWKS([]byte(`{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`)); err == nil {
+		t.Error("expected an error for a point off the curve")
+	}
+}
+
+func TestParsePEMKey(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecDER, _ := x509.MarshalECPrivateKey(ecKey)
+	publicDER, _ := x509.MarshalPKIXPublicKey(rsaKey.Public())
+
+	tests := []struct {
+		name    string
+		block   *pem.Block
+		private bool
+	}{
+		{"RSA private key", &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}, true},
+		{"EC private key", &pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}, true},
+		{"public key", &pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}, false},
+	}
+	for _, test := range tests {
+		publicKey, signer, err := ParsePEMKey(pem.EncodeToMemory(test.block))
+		if err != nil || publicKey == nil || (signer != nil) != test.private {
+			t.Errorf("%s: ParsePEMKey() = %v, %v, %v", test.name, publicKey, signer, err)
+		}
+	}
+	if _, _, err := ParsePEMKey([]byte("not a key")); err == nil {
+		t.Error("expected an error without PEM data")
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authentication
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// Signer issues tokens accepted by a Verifier holding the public key of Key
+type Signer struct {
+	// Key is an RSA or EC private key, which selects the RS256 or ES256, ES384 and ES512 algorithms.
+	Key crypto.Signer
+	// Issuer is set as the iss claim when not empty.
+	Issuer string
+	// Audiences are set as the aud claim when not empty.
+	Audiences []string
+	// TTL is the lifetime of the tokens.
+	TTL time.Duration
+	// Now returns the current time, time.Now when nil.
+	Now func() time.Time
+}
+
+// LoadSigningKey reads the private key of a PEM signing key file
+func LoadSigningKey(signingKeyFile string) (crypto.Signer, error) {
+	data, err := ioutil.ReadFile(signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	_, key, err := ParsePEMKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", signingKeyFile, err)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%s: not a private key", signingKeyFile)
+	}
+	return key, nil
+}
+
+// Sign returns a token of subject and its expiration time
+func (s *Signer) Sign(subject string) (string, time.Time, error) {
+	algorithm, err := signingAlgorithm(s.Key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	issuedAt := now()
+	expiresAt := issuedAt.Add(s.TTL)
+	claims := &Claims{
+		Issuer:    s.Issuer,
+		Subject:   subject,
+		Audience:  s.Audiences,
+		ExpiresAt: expiresAt.Unix(),
+		NotBefore: issuedAt.Unix(),
+		IssuedAt:  issuedAt.Unix(),
+	}
+	h, err := encodeSegment(&header{Algorithm: algorithm, Type: "JWT"})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	c, err := encodeSegment(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	signed := h + "." + c
+	signature, err := sign(s.Key, algorithm, []byte(signed))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), expiresAt, nil
+}
+
+// signingAlgorithm returns the algorithm of key, the one whose hash matches the size of EC keys
+func signingAlgorithm(key crypto.Signer) (string, error) {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return "RS256", nil
+	case *ecdsa.PrivateKey:
+		switch key.Curve.Params().BitSize {
+		case 256:
+			return "ES256", nil
+		case 384:
+			return "ES384", nil
+		case 521:
+			return "ES512", nil
+		}
+		return "", fmt.Errorf("unsupported curve %s", key.Curve.Params().Name)
+	case nil:
+		return "", errors.New("no signing key")
+	}
+	return "", fmt.Errorf("unsupported key type %T", key)
+}
+
+// sign returns the signature of signed with algorithm, EC signatures are the padded r and s values
+func sign(key crypto.Signer, algorithm string, signed []byte) ([]byte, error) {
+	hash := algorithms[algorithm]
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			return nil, err
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		rBytes, sBytes := r.Bytes(), s.Bytes()
+		copy(signature[size-len(rBytes):size], rBytes)
+		copy(signature[2*size-len(sBytes):], sBytes)
+		return signature, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+func encodeSegment(obj interface{}) (string, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authentication
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p521Key, _ := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	now := func() time.Time { return testNow }
+
+	for _, key := range []crypto.Signer{rsaKey, p256Key, p521Key} {
+		signer := &Signer{Key: key, Issuer: "kubenebula", Audiences: []string{"kubernetes"}, TTL: time.Hour, Now: now}
+		token, expiresAt, err := signer.Sign("alice")
+		if err != nil {
+			t.Fatalf("%T: Sign() = %v", key, err)
+		}
+		if !expiresAt.Equal(testNow.Add(time.Hour)) {
+			t.Errorf("%T: expiresAt = %v", key, expiresAt)
+		}
+		verifier := &Verifier{Keys: []Key{{PublicKey: key.Public()}}, Issuer: "kubenebula", Audiences: []string{"kubernetes"}, Now: now}
+		claims, err := verifier.Verify(token)
+		if err != nil {
+			t.Fatalf("%T: Verify() = %v", key, err)
+		}
+		if claims.Subject != "alice" || !reflect.DeepEqual([]string(claims.Audience), []string{"kubernetes"}) {
+			t.Errorf("%T: claims = %+v", key, claims)
+		}
+
+		verifier.Now = func() time.Time { return expiresAt }
+		if _, err := verifier.Verify(token); err == nil {
+			t.Errorf("%T: Verify() accepted an expired token", key)
+		}
+	}
+
+	if _, _, err := (&Signer{TTL: time.Hour}).Sign("alice"); err == nil {
+		t.Error("Sign() without key succeeded")
+	}
+}
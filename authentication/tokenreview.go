@@ -0,0 +1,169 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package authentication serves a TokenReview webhook authenticating the users of the kube-apiserver with JWTs,
+// adding them to the kubenebula:team:<team>:<role> groups of the team roles they hold. Tokens of disabled
+// built-in users are rejected.
+package authentication
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/tenant"
+	"kubenebula.io/kubenebula/users"
+	"kubenebula.io/kubenebula/utils/httputil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var log = logf.Log.WithName("authentication")
+
+// TokenReviewPath is the path of the TokenReview webhook on the webhook server
+const TokenReviewPath = "/authenticate"
+
+// Options configure the token authenticator
+type Options struct {
+	// JWKSFile is a JSON Web Key Set with the public keys of the token issuers.
+	JWKSFile string
+	// SigningKeyFile is a PEM private or public key of locally issued tokens.
+	SigningKeyFile string
+	// Issuer is the required iss claim, empty accepts any issuer.
+	Issuer string
+	// Audiences lists the accepted aud claims, empty accepts any audience.
+	Audiences []string
+}
+
+// NewVerifier returns the Verifier of the keys, issuer and audiences of options.
+func NewVerifier(options Options) (*Verifier, error) {
+	keys, err := LoadKeys(options.JWKSFile, options.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("a JWKS file or a signing key file is required")
+	}
+	return &Verifier{Keys: keys, Issuer: options.Issuer, Audiences: options.Audiences}, nil
+}
+
+// Add registers the TokenReview webhook with the webhook server of the Manager.
+func Add(mgr manager.Manager, options Options) error {
+	verifier, err := NewVerifier(options)
+	if err != nil {
+		return err
+	}
+	mgr.GetWebhookServer().Register(TokenReviewPath, &tokenReviewer{
+		verifier: verifier,
+		service:  &tenant.Service{Client: mgr.GetClient()},
+	})
+	return nil
+}
+
+// reservedGroupPrefixes are the prefixes of the groups tokens can not claim: the team groups are only granted
+// by team memberships and the system: groups belong to the kube-apiserver, such as system:masters
+var reservedGroupPrefixes = []string{constants.TeamGroupPrefix, "system:"}
+
+// ClaimedGroups returns the groups claimed by a token without the reserved groups
+func ClaimedGroups(claims *Claims) []string {
+	var groups []string
+	for _, group := range claims.Groups {
+		reserved := false
+		for _, prefix := range reservedGroupPrefixes {
+			if strings.HasPrefix(group, prefix) {
+				reserved = true
+				break
+			}
+		}
+		if !reserved {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// tokenReviewer answers the TokenReviews of the kube-apiserver, the authentication.k8s.io/v1beta1
+// and v1 reviews share the same fields
+type tokenReviewer struct {
+	verifier *Verifier
+	service  *tenant.Service
+}
+
+func (t *tokenReviewer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	review := &authenticationv1.TokenReview{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, httputil.MaxBodyBytes)).Decode(review); err != nil {
+		http.Error(w, fmt.Sprintf("invalid TokenReview: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.APIVersion == "" {
+		review.APIVersion = authenticationv1.SchemeGroupVersion.String()
+	}
+	review.Kind = "TokenReview"
+	review.Status = t.review(r, review.Spec)
+	httputil.WriteJSON(w, http.StatusOK, review)
+}
+
+// review authenticates the token of spec, failures are reported as unauthenticated
+func (t *tokenReviewer) review(r *http.Request, spec authenticationv1.TokenReviewSpec) authenticationv1.TokenReviewStatus {
+	claims, err := t.verifier.Verify(spec.Token)
+	if err != nil {
+		// tokens of other authenticators end up here too, so this is not worth more than a debug message
+		log.V(1).Info("Rejected token", "reason", err.Error())
+		return authenticationv1.TokenReviewStatus{Error: err.Error()}
+	}
+	if len(spec.Audiences) > 0 && !intersects(spec.Audiences, claims.Audience) {
+		return authenticationv1.TokenReviewStatus{Error: fmt.Sprintf("token audience %q not in %q", claims.Audience, spec.Audiences)}
+	}
+
+	disabled, err := users.Disabled(r.Context(), t.service, claims.Subject)
+	if err != nil {
+		log.Error(err, "unable to get user", "user", claims.Subject)
+		return authenticationv1.TokenReviewStatus{Error: "unable to get user"}
+	}
+	if disabled {
+		return authenticationv1.TokenReviewStatus{Error: fmt.Sprintf("user %q is disabled", claims.Subject)}
+	}
+
+	user := tenant.User{Name: claims.Subject, Groups: ClaimedGroups(claims)}
+	memberships, err := t.service.Memberships(r.Context(), user)
+	if err != nil {
+		log.Error(err, "unable to get team memberships", "user", user.Name)
+		return authenticationv1.TokenReviewStatus{Error: "unable to get team memberships"}
+	}
+	groups := append(append([]string{}, user.Groups...), tenant.Groups(memberships)...)
+
+	status := authenticationv1.TokenReviewStatus{
+		Authenticated: true,
+		User:          authenticationv1.UserInfo{Username: claims.Subject, Groups: groups},
+	}
+	if len(spec.Audiences) > 0 {
+		for _, audience := range spec.Audiences {
+			if intersects([]string{audience}, claims.Audience) {
+				status.Audiences = append(status.Audiences, audience)
+			}
+		}
+	}
+	return status
+}
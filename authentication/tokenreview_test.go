@@ -0,0 +1,139 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authentication
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbac "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/tenant"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestTokenReview(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	c := fake.NewFakeClientWithScheme(scheme,
+		&tenantv1alpha1.Team{
+			ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+			Spec: tenantv1alpha1.TeamSpec{
+				Manager:  "alice",
+				Regulars: []rbac.Subject{{Kind: rbac.GroupKind, Name: "developers"}},
+			},
+		},
+		&tenantv1alpha1.Team{
+			ObjectMeta: metav1.ObjectMeta{Name: "comet"},
+			Spec:       tenantv1alpha1.TeamSpec{Parent: "nebula"},
+		},
+		&tenantv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "alice"}},
+		&tenantv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "mallory"}, Spec: tenantv1alpha1.UserSpec{Disabled: true}},
+	)
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	reviewer := &tokenReviewer{
+		verifier: &Verifier{Keys: []Key{{PublicKey: key.Public(), Local: true}}, Now: func() time.Time { return testNow }},
+		service:  &tenant.Service{Client: c},
+	}
+	token := func(subject string, groups ...string) string {
+		claims := validClaims()
+		claims.Subject, claims.Groups = subject, groups
+		return signToken(t, key, "RS256", "", claims)
+	}
+
+	tests := []struct {
+		name          string
+		apiVersion    string
+		token         string
+		audiences     []string
+		authenticated bool
+		groups        []string
+	}{
+		{
+			name: "manager", apiVersion: "authentication.k8s.io/v1beta1", token: token("alice"), authenticated: true,
+			groups: []string{"kubenebula:team:comet:admin", "kubenebula:team:nebula:admin"},
+		},
+		{
+			name: "group member", apiVersion: "authentication.k8s.io/v1", token: token("bob", "developers"), authenticated: true,
+			groups: []string{"developers", "kubenebula:team:nebula:regular"},
+		},
+		{
+			name: "reserved groups", token: token("eve", "system:masters", "kubenebula:team:nebula:admin", "auditors"), authenticated: true,
+			groups: []string{"auditors"},
+		},
+		{name: "no teams", token: token("eve"), authenticated: true, groups: []string{}},
+		{name: "invalid token", token: "token"},
+		{name: "system user", token: token("system:admin", "system:masters")},
+		{name: "system service account", token: token("system:serviceaccount:kube-system:default")},
+		{name: "built-in user", token: token(constants.BuiltinUserPrefix + "alice"), authenticated: true, groups: []string{}},
+		{name: "disabled user", token: token(constants.BuiltinUserPrefix + "mallory")},
+		{name: "external user named like a disabled user", token: token("mallory"), authenticated: true, groups: []string{}},
+		{name: "audience", token: token("eve"), audiences: []string{"vault", "kubernetes"}, authenticated: true, groups: []string{}},
+		{name: "wrong audience", token: token("eve"), audiences: []string{"vault"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"apiVersion": %q, "kind": "TokenReview", "spec": {"token": %q, "audiences": %s}}`,
+				test.apiVersion, test.token, toJSON(t, test.audiences))
+			w := httptest.NewRecorder()
+			reviewer.ServeHTTP(w, httptest.NewRequest("POST", TokenReviewPath, strings.NewReader(body)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("POST %s = %d %s", TokenReviewPath, w.Code, w.Body)
+			}
+
+			review := &authenticationv1.TokenReview{}
+			if err := json.Unmarshal(w.Body.Bytes(), review); err != nil {
+				t.Fatal(err)
+			}
+			if expected := test.apiVersion; expected != "" && review.APIVersion != expected {
+				t.Errorf("apiVersion = %q, expected %q", review.APIVersion, expected)
+			}
+			status := review.Status
+			if status.Authenticated != test.authenticated || (!test.authenticated && status.Error == "") {
+				t.Fatalf("status = %+v", status)
+			}
+			if test.authenticated && !reflect.DeepEqual(append([]string{}, status.User.Groups...), test.groups) {
+				t.Errorf("groups = %v, expected %v", status.User.Groups, test.groups)
+			}
+			if test.authenticated && len(test.audiences) > 0 && !reflect.DeepEqual(status.Audiences, []string{"kubernetes"}) {
+				t.Errorf("audiences = %v", status.Audiences)
+			}
+		})
+	}
+}
+
+func toJSON(t *testing.T, obj interface{}) string {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package authorization serves a SubjectAccessReview webhook granting the team roles in the team namespaces,
+// including namespaces created after the roles were assigned, without waiting for their RoleBindings.
+//
+// The webhook only allows: requests outside team namespaces, outside the namespace scope of the namespace
+// controller or not granted by a team role get no opinion, so the kube-apiserver falls through to the next
+// authorizer, usually RBAC.
+package authorization
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/controllers/namespace"
+	"kubenebula.io/kubenebula/tenant"
+	"kubenebula.io/kubenebula/utils/httputil"
+	"kubenebula.io/kubenebula/utils/sliceutil"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var log = logf.Log.WithName("authorization")
+
+const (
+	// SubjectAccessReviewPath is the path of the SubjectAccessReview webhook on the webhook server
+	SubjectAccessReviewPath = "/authorize"
+
+	// teamRoleTeamField is the field index of the TeamRoles of the cache by team
+	teamRoleTeamField = "spec.team"
+)
+
+// Add registers the SubjectAccessReview webhook with the webhook server of the Manager. The webhook reads
+// namespaces, teams, TeamRoles and NamespaceRoleTemplates from the cache of the Manager and only grants
+// roles in the namespaces of scope, the scope of the namespace controller.
+func Add(mgr manager.Manager, scope namespace.Scope) error {
+	// every review lists the TeamRoles of one team, the index spares going through those of all teams
+	if err := mgr.GetFieldIndexer().IndexField(&tenantv1alpha1.TeamRole{}, teamRoleTeamField, teamRoleTeam); err != nil {
+		return err
+	}
+	mgr.GetWebhookServer().Register(SubjectAccessReviewPath, &accessReviewer{client: mgr.GetClient(), scope: scope})
+	return nil
+}
+
+// accessReviewer answers the SubjectAccessReviews of the kube-apiserver, the authorization.k8s.io/v1beta1
+// and v1 reviews share the same fields
+type accessReviewer struct {
+	client client.Client
+	scope  namespace.Scope
+}
+
+func (a *accessReviewer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	review := &authorizationv1.SubjectAccessReview{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, httputil.MaxBodyBytes)).Decode(review); err != nil {
+		http.Error(w, fmt.Sprintf("invalid SubjectAccessReview: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.APIVersion == "" {
+		review.APIVersion = authorizationv1.SchemeGroupVersion.String()
+	}
+	review.Kind = "SubjectAccessReview"
+	allowed, reason, err := a.authorize(r.Context(), &review.Spec)
+	switch {
+	case err != nil:
+		// no opinion, the next authorizer decides
+		log.Error(err, "unable to review access", "user", review.Spec.User)
+		review.Status = authorizationv1.SubjectAccessReviewStatus{EvaluationError: err.Error()}
+	case allowed:
+		review.Status = authorizationv1.SubjectAccessReviewStatus{Allowed: true, Reason: reason}
+	default:
+		review.Status = authorizationv1.SubjectAccessReviewStatus{}
+	}
+	httputil.WriteJSON(w, http.StatusOK, review)
+}
+
+// authorize returns whether a team role grants the namespaced resource request of spec
+func (a *accessReviewer) authorize(ctx context.Context, spec *authorizationv1.SubjectAccessReviewSpec) (bool, string, error) {
+	attributes := spec.ResourceAttributes
+	if attributes == nil || attributes.Namespace == "" {
+		return false, "", nil
+	}
+	ns := &corev1.Namespace{}
+	if err := a.client.Get(ctx, types.NamespacedName{Name: attributes.Namespace}, ns); err != nil {
+		return false, "", client.IgnoreNotFound(err)
+	}
+	// the namespace controller does not bind the team roles outside its scope
+	if !a.scope.Contains(ns) {
+		return false, "", nil
+	}
+	teamName := teamutil.TeamName(ns)
+	if teamName == "" || !teamutil.HasLabels(ns.Labels, teamName) {
+		return false, "", nil
+	}
+	team := &tenantv1alpha1.Team{}
+	if err := a.client.Get(ctx, types.NamespacedName{Name: teamName}, team); err != nil {
+		return false, "", client.IgnoreNotFound(err)
+	}
+	ancestors, err := teamutil.InheritedAncestors(a.client, team)
+	if err != nil {
+		return false, "", err
+	}
+
+	user := tenant.User{Name: spec.User, Groups: spec.Groups}
+	roles := tenant.Roles(team, ancestors, nil, user)
+	// the namespace controller binds the creator of the namespace as an admin
+	if creator := ns.Annotations[constants.CreatorAnnotationKey]; creator != "" && creator != constants.System && creator == user.Name {
+		roles = append(roles, tenantv1alpha1.TeamAdminTemplate)
+	}
+	for _, role := range roles {
+		namespaceRole, err := namespace.NamespaceRole(ctx, a.client, namespace.NamespaceRoleNames[role])
+		if err != nil {
+			return false, "", err
+		}
+		if namespaceRole != nil && rulesAllow(namespaceRole.Rules, attributes) {
+			return true, fmt.Sprintf("%s of team %q", role, teamName), nil
+		}
+	}
+
+	teamRoles := &tenantv1alpha1.TeamRoleList{}
+	if err := a.client.List(ctx, teamRoles, client.MatchingField(teamRoleTeamField, teamName)); err != nil {
+		return false, "", err
+	}
+	for i := range teamRoles.Items {
+		teamRole := &teamRoles.Items[i]
+		if teamRole.Spec.Team == teamName && teamutil.HasUser(teamRole.Spec.Members, user.Name, user.Groups) &&
+			rulesAllow(teamRole.Spec.Rules, attributes) {
+			return true, fmt.Sprintf("%s of team %q", teamRole.GetRoleName(), teamName), nil
+		}
+	}
+	return false, "", nil
+}
+
+// teamRoleTeam returns the team of a TeamRole for the field index
+func teamRoleTeam(obj runtime.Object) []string {
+	teamRole, ok := obj.(*tenantv1alpha1.TeamRole)
+	if !ok || teamRole.Spec.Team == "" {
+		return nil
+	}
+	return []string{teamRole.Spec.Team}
+}
+
+// rulesAllow returns whether one of rules allows the resource request, with the semantics of RBAC
+func rulesAllow(rules []rbac.PolicyRule, attributes *authorizationv1.ResourceAttributes) bool {
+	resource := attributes.Resource
+	if attributes.Subresource != "" {
+		resource += "/" + attributes.Subresource
+	}
+	for _, rule := range rules {
+		if (sliceutil.HasString(rule.Verbs, rbac.VerbAll) || sliceutil.HasString(rule.Verbs, attributes.Verb)) &&
+			(sliceutil.HasString(rule.APIGroups, rbac.APIGroupAll) || sliceutil.HasString(rule.APIGroups, attributes.Group)) &&
+			matchesResource(rule.Resources, resource, attributes.Subresource) &&
+			(len(rule.ResourceNames) == 0 || sliceutil.HasString(rule.ResourceNames, attributes.Name)) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesResource(resources []string, resource, subresource string) bool {
+	for _, r := range resources {
+		if r == rbac.ResourceAll || r == resource || (subresource != "" && r == "*/"+subresource) {
+			return true
+		}
+	}
+	return false
+}
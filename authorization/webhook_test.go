@@ -0,0 +1,185 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authorization
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/controllers/namespace"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestReviewer(objects ...runtime.Object) *accessReviewer {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	selector, _ := labels.Parse("environment!=sandbox")
+	scope := namespace.Scope{ExcludedNamespaces: append([]string{"nebula-excluded"}, constants.SystemNamespaces...), Selector: selector}
+	return &accessReviewer{client: fake.NewFakeClientWithScheme(scheme, objects...), scope: scope}
+}
+
+func TestAccessReviewer(t *testing.T) {
+	teamNamespace := func(name, team string, annotations map[string]string) *corev1.Namespace {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+		if team != "" {
+			ns.Labels = teamutil.Labels(team)
+		}
+		return ns
+	}
+	sandbox := teamNamespace("nebula-sandbox", "nebula", nil)
+	sandbox.Labels["environment"] = "sandbox"
+	a := newTestReviewer(
+		&tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "department"}, Spec: tenantv1alpha1.TeamSpec{Manager: "head"}},
+		&tenantv1alpha1.Team{
+			ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+			Spec: tenantv1alpha1.TeamSpec{
+				Manager:  "alice",
+				Parent:   "department",
+				Regulars: []rbac.Subject{{Kind: rbac.GroupKind, Name: "nebula-devs"}},
+				Viewers:  []rbac.Subject{{Kind: rbac.UserKind, Name: "auditor"}},
+			},
+		},
+		&tenantv1alpha1.TeamRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "nebula-deployer"},
+			Spec: tenantv1alpha1.TeamRoleSpec{
+				Team:     "nebula",
+				RoleName: "deployer",
+				Members:  []rbac.Subject{{Kind: rbac.UserKind, Name: "ci"}},
+				Rules:    []rbac.PolicyRule{{Verbs: []string{"patch"}, APIGroups: []string{"apps"}, Resources: []string{"deployments", "*/scale"}}},
+			},
+		},
+		&tenantv1alpha1.TeamRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "comet-deployer"},
+			Spec: tenantv1alpha1.TeamRoleSpec{
+				Team:     "comet",
+				RoleName: "deployer",
+				Members:  []rbac.Subject{{Kind: rbac.UserKind, Name: "ci"}},
+				Rules:    []rbac.PolicyRule{{Verbs: []string{"delete"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}}},
+			},
+		},
+		teamNamespace("nebula-prod", "nebula", map[string]string{constants.CreatorAnnotationKey: "carol"}),
+		teamNamespace("comet-prod", "comet", nil),
+		teamNamespace("default", "", nil),
+		teamNamespace("nebula-excluded", "nebula", nil),
+		sandbox,
+	)
+
+	tests := []struct {
+		name       string
+		user       string
+		groups     []string
+		attributes *authorizationv1.ResourceAttributes
+		allowed    bool
+	}{
+		{"manager", "alice", nil, &authorizationv1.ResourceAttributes{Namespace: "nebula-prod", Verb: "delete", Group: "rbac.authorization.k8s.io", Resource: "roles"}, true},
+		{"ancestor manager", "head", nil, &authorizationv1.ResourceAttributes{Namespace: "nebula-prod", Verb: "create", Resource: "secrets"}, true},
+		{"namespace creator", "carol", nil, &authorizationv1.ResourceAttributes{Namespace: "nebula-prod", Verb: "create", Resource: "secrets"}, true},
+		{"regular group", "bob", []string{"nebula-devs"}, &authorizationv1.ResourceAttributes{Namespace: "nebula-prod", Verb: "create", Group: "apps", Resource: "deployments"}, true},
+		{"regular role management", "bob", []string{"nebula-devs"}, &authorizationv1.ResourceAttributes{Namespace: "nebula-prod", Verb: "create", Group: "rbac.authorization.k8s.io", Resource: "rolebindings"}, false},
+		{"viewer", "auditor", nil, &authorizationv1.ResourceAttributes{Namespace: "nebula-prod", Verb: "list", Resource: "pods"}, true},
+		{"viewer exec", "auditor", nil, &authorizationv1.ResourceAttributes{Namespace: "nebula-prod", Verb: "create", Resource: "pods", Subresource: "exec"}, false},
+		{"team role", "ci", nil, &authorizationv1.ResourceAttributes{Namespace: "nebula-prod", Verb: "patch", Group: "apps", Resource: "deployments"}, true},
+		{"team role subresource", "ci", nil, &authorizationv1.ResourceAttributes{Namespace: "nebula-prod", Verb: "patch", Group: "apps", Resource: "statefulsets", Subresource: "scale"}, true},
+		{"team role verb", "ci", nil, &authorizationv1.ResourceAttributes{Namespace: "nebula-prod", Verb: "delete", Group: "apps", Resource: "deployments"}, false},
+		{"other team", "alice", nil, &authorizationv1.ResourceAttributes{Namespace: "comet-prod", Verb: "get", Resource: "pods"}, false},
+		{"not a team namespace", "alice", nil, &authorizationv1.ResourceAttributes{Namespace: "default", Verb: "get", Resource: "pods"}, false},
+		{"excluded namespace", "alice", nil, &authorizationv1.ResourceAttributes{Namespace: "nebula-excluded", Verb: "get", Resource: "pods"}, false},
+		{"unselected namespace", "alice", nil, &authorizationv1.ResourceAttributes{Namespace: "nebula-sandbox", Verb: "get", Resource: "pods"}, false},
+		{"missing namespace", "alice", nil, &authorizationv1.ResourceAttributes{Namespace: "nebula-dev", Verb: "get", Resource: "pods"}, false},
+		{"cluster scoped", "alice", nil, &authorizationv1.ResourceAttributes{Verb: "get", Resource: "nodes"}, false},
+		{"non-resource", "alice", nil, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			review := &authorizationv1.SubjectAccessReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "authorization.k8s.io/v1beta1", Kind: "SubjectAccessReview"},
+				Spec:     authorizationv1.SubjectAccessReviewSpec{User: test.user, Groups: test.groups, ResourceAttributes: test.attributes},
+			}
+			if test.attributes == nil {
+				review.Spec.NonResourceAttributes = &authorizationv1.NonResourceAttributes{Path: "/healthz", Verb: "get"}
+			}
+			body, _ := json.Marshal(review)
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, httptest.NewRequest("POST", SubjectAccessReviewPath, strings.NewReader(string(body))))
+			if w.Code != http.StatusOK {
+				t.Fatalf("POST %s = %d %s", SubjectAccessReviewPath, w.Code, w.Body)
+			}
+
+			result := &authorizationv1.SubjectAccessReview{}
+			if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+				t.Fatal(err)
+			}
+			if result.APIVersion != "authorization.k8s.io/v1beta1" {
+				t.Errorf("apiVersion = %q", result.APIVersion)
+			}
+			if result.Status.Allowed != test.allowed || result.Status.Denied || result.Status.EvaluationError != "" {
+				t.Errorf("status = %+v, expected allowed %v", result.Status, test.allowed)
+			}
+		})
+	}
+}
+
+func TestNamespaceRoleTemplates(t *testing.T) {
+	a := newTestReviewer(
+		&tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}, Spec: tenantv1alpha1.TeamSpec{
+			Viewers: []rbac.Subject{{Kind: rbac.UserKind, Name: "auditor"}},
+		}},
+		&tenantv1alpha1.NamespaceRoleTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "viewer"},
+			Spec:       tenantv1alpha1.NamespaceRoleTemplateSpec{Rules: []rbac.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"web"}}}},
+		},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-prod", Labels: teamutil.Labels("nebula")}},
+	)
+
+	for _, name := range []string{"web", "db"} {
+		review := &authorizationv1.SubjectAccessReviewSpec{User: "auditor", ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Namespace: "nebula-prod", Verb: "get", Resource: "pods", Name: name,
+		}}
+		allowed, reason, err := a.authorize(context.TODO(), review)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := name == "web"; allowed != expected {
+			t.Errorf("get pod %s allowed = %v (%s), expected %v", name, allowed, reason, expected)
+		}
+	}
+}
+
+func TestTeamRoleTeam(t *testing.T) {
+	teamRole := &tenantv1alpha1.TeamRole{Spec: tenantv1alpha1.TeamRoleSpec{Team: "nebula"}}
+	if values := teamRoleTeam(teamRole); len(values) != 1 || values[0] != "nebula" {
+		t.Errorf("teamRoleTeam() = %v, expected [nebula]", values)
+	}
+	if values := teamRoleTeam(&tenantv1alpha1.Team{}); len(values) != 0 {
+		t.Errorf("teamRoleTeam() of a Team = %v, expected none", values)
+	}
+}
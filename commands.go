@@ -0,0 +1,174 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/maintenance"
+	"kubenebula.io/kubenebula/users"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// commands are one-shot maintenance operations, run as `manager <command> [flags]`
+var commands = map[string]func(args []string) error{
+	"migrate-labels":   migrateLabels,
+	"prune-finalizers": pruneFinalizers,
+	"set-password":     setPassword,
+	"uninstall":        uninstall,
+}
+
+func runCommand(name string, args []string) error {
+	command, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown command %q, available commands: %v", name, names)
+	}
+	return command(args)
+}
+
+// newFlagSet returns a flag set for the command which also accepts the global flags such as --kubeconfig
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	return fs
+}
+
+func newClient() (client.Client, error) {
+	return client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+}
+
+func migrateLabels(args []string) error {
+	fs := newFlagSet("migrate-labels")
+	dryRun := fs.Bool("dry-run", false, "Print the namespaces that would be relabelled without changing them.")
+	_ = fs.Parse(args)
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	report, err := maintenance.MigrateTeamLabels(c, *dryRun)
+	if err != nil {
+		return err
+	}
+	report.Print(os.Stdout)
+	return nil
+}
+
+func pruneFinalizers(args []string) error {
+	fs := newFlagSet("prune-finalizers")
+	dryRun := fs.Bool("dry-run", false, "Print the namespaces whose finalizer would be removed without changing them.")
+	namespaceScope := bindScopeFlags(fs)
+	_ = fs.Parse(args)
+
+	scope, err := namespaceScope.scope()
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	report, err := maintenance.PruneNamespaceFinalizers(c, scope.Contains, *dryRun)
+	if err != nil {
+		return err
+	}
+	report.Print(os.Stdout)
+	return nil
+}
+
+func uninstall(args []string) error {
+	fs := newFlagSet("uninstall")
+	options := maintenance.UninstallOptions{}
+	fs.BoolVar(&options.DryRun, "dry-run", false, "Print the changes without applying them.")
+	fs.BoolVar(&options.DeleteGenerated, "delete-generated", false,
+		"Also delete the ClusterRoles, ClusterRoleBindings, Roles and RoleBindings generated by the controllers.")
+	_ = fs.Parse(args)
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	report, err := maintenance.Uninstall(c, options)
+	if report != nil {
+		report.Print(os.Stdout)
+	}
+	return err
+}
+
+func setPassword(args []string) error {
+	fs := newFlagSet("set-password")
+	create := fs.Bool("create", false, "Create the user when it does not exist.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: set-password [flags] <user>, the password is read from the first line of stdin\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("a user name is required")
+	}
+
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if err := users.ValidatePassword(password); err != nil {
+		return err
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	user := &tenantv1alpha1.User{}
+	if err := c.Get(ctx, types.NamespacedName{Name: fs.Arg(0)}, user); err != nil {
+		if !apierrors.IsNotFound(err) || !*create {
+			return err
+		}
+		user.Name = fs.Arg(0)
+		if err := c.Create(ctx, user); err != nil {
+			return err
+		}
+		fmt.Printf("Created user %s\n", user.Name)
+	}
+	if err := users.SetPassword(ctx, c, user, password); err != nil {
+		return err
+	}
+	fmt.Printf("Set the password of user %s\n", user.Name)
+	return nil
+}
@@ -25,19 +25,43 @@ const (
 	TeamViewer    = "team-viewer"
 	AdminUserName = "admin"
 
-	TeamLabelKey             = "kubenebula.io/teambase64"  //Team Label in namespace
+	TeamLabelKey             = "kubenebula.io/team"        //Team name label, set when the name is a valid label value
+	TeamHashLabelKey         = "kubenebula.io/team-hash"   //Team name hash label, set when the name is not a valid label value
+	LegacyTeamLabelKey       = "kubenebula.io/teambase64"  //Base64 team label written by earlier releases, removed by migrate-labels
+	TeamRoleLabelKey         = "kubenebula.io/team-role"   //TeamRole name label, set on the objects materialized from a TeamRole
 	DisplayNameAnnotationKey = "kubenebula.io/alias-name"  //别名
 	DescriptionAnnotationKey = "kubenebula.io/description" //描述
 	CreatorAnnotationKey     = "kubenebula.io/creator"     //创建者
 	AvatarAnnotationKey      = "kubenebula.io/avatar"
 	TeamAnnotationKey        = "kubenebula.io/team" //Team Label in namespace
 	System                   = "system"             //默认的系统创建者，创建的资源视为不可被用户删除的资源
+	TeamFinalizer            = "finalizers.tenant.kubenebula.io"
+	NamespaceFinalizer       = "finalizers.kubenebula.io/namespaces"
+
+	QuotaPresetAnnotationKey    = "kubenebula.io/quota-preset"    //QuotaPreset of a namespace, overrides the one of its team
+	UnmanagedQuotaAnnotationKey = "kubenebula.io/unmanaged-quota" //"true" keeps the controller off the ResourceQuota and LimitRange of a namespace
+	QuotaPresetLabelKey         = "kubenebula.io/quota-preset"    //QuotaPreset name label, set on the ResourceQuota and LimitRange created from it
+
+	NamespaceClaimAnnotationKey  = "kubenebula.io/namespace-claim"  //NamespaceClaim a namespace was created from
+	RequesterAnnotationKey       = "kubenebula.io/requester"        //User who created a NamespaceClaim, set by the admission webhook
+	RequesterGroupsAnnotationKey = "kubenebula.io/requester-groups" //JSON array of the groups of the requester, set by the admission webhook
+
+	TeamGroupPrefix   = "kubenebula:team:" //Prefix of the kubenebula:team:<team>:<role> groups of authenticated team members
+	BuiltinUserPrefix = "kubenebula:user:" //Prefix of the kubenebula:user:<name> usernames of the built-in users in tokens and RBAC subjects
+
+	KubeSystemNamespace    = "kube-system"
+	KubePublicNamespace    = "kube-public"
+	KubeNodeLeaseNamespace = "kube-node-lease"
+	KubeNebulaNamespace    = "kubenebula-system"
 
 	ResourceLabel              = "kubenebula.io/resource"
 	ResourceClusterRole        = "clusterrole"
 	ResourceRole               = "role"
 	ResourceClusterRoleBinding = "clusterrolebinding"
 	ResourceRoleBinding        = "rolebinding"
+	ResourceResourceQuota      = "resourcequota"
+	ResourceLimitRange         = "limitrange"
+	ResourceNetworkPolicy      = "networkpolicy"
 	UserNameHeader             = "X-Token-Username"
 	TenantResourcesTag         = "Tenant Resources"
 	NamespaceResourcesTag      = "Namespace Resources"
@@ -58,6 +82,6 @@ const (
 )
 
 var (
-	TeamRoles = []string{TeamAdmin, TeamRegular, TeamViewer}
-	//SystemNamespaces = []string{KubeSphereNamespace, KubeSphereLoggingNamespace, KubeSphereMonitoringNamespace, OpenPitrixNamespace, KubeSystemNamespace, IstioNamespace, KubesphereDevOpsNamespace, PorterNamespace}
+	TeamRoles        = []string{TeamAdmin, TeamRegular, TeamViewer}
+	SystemNamespaces = []string{KubeSystemNamespace, KubePublicNamespace, KubeNodeLeaseNamespace, KubeNebulaNamespace}
 )
@@ -2,25 +2,30 @@ package namespace
 
 import (
 	"context"
-	"encoding/base64"
+	"fmt"
 	//appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
 	rbac "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 	"kubenebula.io/kubenebula/api/tenant/v1alpha1"
 	"kubenebula.io/kubenebula/constants"
 
 	"kubenebula.io/kubenebula/utils/sliceutil"
+	"kubenebula.io/kubenebula/utils/teamutil"
 	"reflect"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
@@ -34,6 +39,14 @@ const (
 	viewerDescription    = "拥有命名空间所有资源的查看权限"
 )
 
+// Reasons of the events recorded on namespaces for their roles
+const (
+	roleCreatedReason = "RoleCreated"
+	roleUpdatedReason = "RoleUpdated"
+	roleDeletedReason = "RoleDeleted"
+	roleFailedReason  = "RoleFailed"
+)
+
 var (
 	admin = rbac.Role{
 		ObjectMeta: metav1.ObjectMeta{
@@ -64,6 +77,13 @@ var (
 			constants.DescriptionAnnotationKey: viewerDescription}},
 		Rules: []rbac.PolicyRule{{Verbs: []string{"get", "list", "watch"}, APIGroups: []string{"*"}, Resources: []string{"*"}}}}
 	defaultRoles = []rbac.Role{admin, developer, viewer}
+
+	// NamespaceRoleNames maps the built-in team roles to the Roles they are bound to in the team namespaces
+	NamespaceRoleNames = map[string]string{
+		v1alpha1.TeamAdminTemplate:   admin.Name,
+		v1alpha1.TeamRegularTemplate: developer.Name,
+		v1alpha1.TeamViewerTemplate:  viewer.Name,
+	}
 )
 
 /**
@@ -73,38 +93,175 @@ var (
 
 // Add creates a new Namespace Controller and adds it to the Manager with default RBAC. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
-func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+// Only namespaces in scope are watched and reconciled. The network policies of isolated teams always allow
+// traffic from networkSystemNamespaces.
+func Add(mgr manager.Manager, scope Scope, networkSystemNamespaces []string) error {
+	return add(mgr, newReconciler(mgr, scope, networkSystemNamespaces), scope)
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &NamespaceReconcile{Client: mgr.GetClient(), Scheme: mgr.GetScheme()}
+func newReconciler(mgr manager.Manager, scope Scope, networkSystemNamespaces []string) reconcile.Reconciler {
+	return &NamespaceReconcile{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Scope:                   scope,
+		NetworkSystemNamespaces: networkSystemNamespaces,
+		Recorder:                mgr.GetEventRecorderFor("namespace-controller"),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
+func add(mgr manager.Manager, r reconcile.Reconciler, scope Scope) error {
 	// Create a new controller
 	c, err := controller.New("namespace-controller", mgr, controller.Options{Reconciler: r})
 	if err != nil {
 		return err
 	}
 	// Watch for changes to Namespace
-	err = c.Watch(&source.Kind{Type: &corev1.Namespace{}}, &handler.EnqueueRequestForObject{})
+	err = c.Watch(&source.Kind{Type: &corev1.Namespace{}}, &handler.EnqueueRequestForObject{}, scope.Predicate())
+	if err != nil {
+		return err
+	}
+	// Watch for changes to the role templates and enqueue every team namespace
+	err = c.Watch(&source.Kind{Type: &v1alpha1.NamespaceRoleTemplate{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: &templateNamespaceMapper{Client: mgr.GetClient(), Scope: scope},
+	})
+	if err != nil {
+		return err
+	}
+	// Watch for changes to the quota presets and enqueue every team namespace
+	err = c.Watch(&source.Kind{Type: &v1alpha1.QuotaPreset{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: &templateNamespaceMapper{Client: mgr.GetClient(), Scope: scope},
+	})
+	if err != nil {
+		return err
+	}
+	// Watch for changes to the resource quotas and limit ranges created from quota presets to revert manual changes
+	for _, obj := range []runtime.Object{&corev1.ResourceQuota{}, &corev1.LimitRange{}} {
+		err = c.Watch(&source.Kind{Type: obj}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(quotaObjectNamespaceMapper),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// Watch for changes to the network policies created for isolated teams to revert manual changes
+	err = c.Watch(&source.Kind{Type: &networking.NetworkPolicy{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(networkPolicyNamespaceMapper),
+	})
+	if err != nil {
+		return err
+	}
+	// Watch for changes to the custom team roles and enqueue the namespaces of their team
+	err = c.Watch(&source.Kind{Type: &v1alpha1.TeamRole{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: &teamNamespaceMapper{Client: mgr.GetClient(), Scope: scope, TeamName: func(obj handler.MapObject) string {
+			return obj.Object.(*v1alpha1.TeamRole).Spec.Team
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	// Watch for changes to Team membership and enqueue the namespaces of the team
+	err = c.Watch(&source.Kind{Type: &v1alpha1.Team{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: &teamNamespaceMapper{Client: mgr.GetClient(), Scope: scope},
+	}, teamSpecChanged)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// teamSpecChanged drops Team updates that only touch the status, which the team
+// controller writes on every reconcile and would otherwise requeue all namespaces of the team
+var teamSpecChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() ||
+			!reflect.DeepEqual(e.MetaOld.GetDeletionTimestamp(), e.MetaNew.GetDeletionTimestamp())
+	},
+}
+
+// teamNamespaceMapper maps a Team to the namespaces that belong to it and to its descendants
+type teamNamespaceMapper struct {
+	client.Client
+	Scope Scope
+	// TeamName returns the team of the object, the object is the Team itself when it is nil
+	TeamName func(obj handler.MapObject) string
+}
+
+func (m *teamNamespaceMapper) Map(obj handler.MapObject) []reconcile.Request {
+	teamNames := []string{obj.Meta.GetName()}
+	if m.TeamName != nil {
+		teamNames = []string{m.TeamName(obj)}
+	} else {
+		// the descendants of a team inherit its admins and viewers
+		teams := &v1alpha1.TeamList{}
+		if err := m.List(context.TODO(), teams); err != nil {
+			klog.Errorf("list teams of team: %s, error: %s", obj.Meta.GetName(), err)
+			return nil
+		}
+		teamNames = append(teamNames, teamutil.Descendants(teams.Items, obj.Meta.GetName())...)
+	}
+
+	var requests []reconcile.Request
+	for _, teamName := range teamNames {
+		nsList := &corev1.NamespaceList{}
+		options := client.ListOptions{LabelSelector: teamutil.Selector(teamName)}
+		if err := m.List(context.TODO(), nsList, &options); err != nil {
+			klog.Errorf("list namespaces of team: %s, error: %s", teamName, err)
+			return nil
+		}
+		for _, namespace := range nsList.Items {
+			if !m.Scope.Contains(&namespace) {
+				continue
+			}
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: namespace.Name}})
+		}
+	}
+	return requests
+}
+
+// templateNamespaceMapper maps a NamespaceRoleTemplate or a QuotaPreset to every team namespace in scope
+type templateNamespaceMapper struct {
+	client.Client
+	Scope Scope
+}
+
+func (m *templateNamespaceMapper) Map(obj handler.MapObject) []reconcile.Request {
+	nsList := &corev1.NamespaceList{}
+	if err := m.List(context.TODO(), nsList); err != nil {
+		klog.Errorf("list namespaces of role template: %s, error: %s", obj.Meta.GetName(), err)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, namespace := range nsList.Items {
+		if teamutil.TeamName(&namespace) == "" || !m.Scope.Contains(&namespace) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: namespace.Name}})
+	}
+	return requests
+}
+
 var _ reconcile.Reconciler = &NamespaceReconcile{}
 
 // NamespaceReconcile reconciles a Namespace object
 type NamespaceReconcile struct {
 	client.Client
 	Scheme *runtime.Scheme
+	Scope  Scope
+	// NetworkSystemNamespaces are allowed to reach the namespaces of isolated teams
+	NetworkSystemNamespaces []string
+	Recorder                record.EventRecorder
 }
 
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=tenant.kubenebula.io,resources=namespaceroletemplates;teamroles;quotapresets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=resourcequotas;limitranges,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=escalate;bind
+
 // Reconcile reads that state of the cluster for a Namespace object and makes changes based on the state read
 // and what is in the Namespace.Spec
 // +kubebuilder:rbac:groups=core.kubenebula.io,resources=namespaces,verbs=get;list;watch;create;update;patch;delete
@@ -125,8 +282,13 @@ func (r *NamespaceReconcile) Reconcile(request reconcile.Request) (reconcile.Res
 		return reconcile.Result{}, err
 	}
 
+	// Namespaces out of scope are never touched, except for removing the finalizer added while they were in scope
+	if !r.Scope.Contains(instance) && !releasing(instance) {
+		return reconcile.Result{}, nil
+	}
+
 	// name of your custom finalizer
-	finalizer := "finalizers.kubenebula.io/namespaces"
+	finalizer := constants.NamespaceFinalizer
 
 	if instance.ObjectMeta.DeletionTimestamp.IsZero() {
 		// The object is not being deleted, so if it does not have our finalizer,
@@ -155,7 +317,7 @@ func (r *NamespaceReconcile) Reconcile(request reconcile.Request) (reconcile.Res
 		// Our finalizer has finished, so the reconciler can do nothing.
 		return reconcile.Result{}, nil
 	}
-	if err = r.chechOrAddTeamLabel(instance); err != nil {
+	if err = r.checkAndUpdateTeamLabels(instance); err != nil {
 		return reconcile.Result{}, err
 	}
 	controlledByTeam, err := r.isControlledByTeam(instance)
@@ -164,214 +326,358 @@ func (r *NamespaceReconcile) Reconcile(request reconcile.Request) (reconcile.Res
 	}
 
 	if !controlledByTeam {
-		err = r.deleteRoleBindings(instance)
+		if err = r.deleteRoleBindings(instance); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err = r.pruneTeamRoles(instance, nil); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err = r.deleteQuota(instance); err != nil {
+			return reconcile.Result{}, err
+		}
+		err = r.deleteNetworkPolicy(instance)
 		return reconcile.Result{}, err
 	}
 
-	//if err = r.checkAndBindTeam(instance); err != nil {
-	//	return reconcile.Result{}, err
-	//}
-
 	if err = r.checkAndCreateRoles(instance); err != nil {
 		return reconcile.Result{}, err
 	}
 
-	//if err = r.checkAndCreateRoleBindings(instance); err != nil {
-	//	return reconcile.Result{}, err
-	//}
+	if err = r.checkAndCreateRoleBindings(instance); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if err = r.checkAndCreateTeamRoles(instance); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if err = r.checkAndCreateQuota(instance); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if err = r.checkAndCreateNetworkPolicy(instance); err != nil {
+		return reconcile.Result{}, err
+	}
 	return reconcile.Result{}, nil
 }
 
 func (r *NamespaceReconcile) isControlledByTeam(namespace *corev1.Namespace) (bool, error) {
-	// without team label
-	if teamName, ok := namespace.Labels[constants.TeamLabelKey]; !ok || (teamName == "") {
+	teamName := teamutil.TeamName(namespace)
+	// without team or team labels
+	if teamName == "" || !teamutil.HasLabels(namespace.Labels, teamName) {
 		return false, nil
 	}
 	return true, nil
 }
 
 // Create default roles
+// checkAndCreateRoles creates or updates every role of the namespace and deletes the stale ones in one pass,
+// the outcome of each role is recorded as an event on the namespace
 func (r *NamespaceReconcile) checkAndCreateRoles(namespace *corev1.Namespace) error {
-	for _, role := range defaultRoles {
-		found := &rbac.Role{}
-		err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace.Name, Name: role.Name}, found)
-		if err != nil {
-			if errors.IsNotFound(err) {
-				role := role.DeepCopy()
-				role.Namespace = namespace.Name
-				err = r.Create(context.TODO(), role)
-				if err != nil {
-					klog.Error(err)
-					return err
-				}
-				return nil
-			} else {
-				klog.Error(err)
-				return err
-			}
+	roles, err := r.namespaceRoles()
+	if err != nil {
+		klog.Errorf("list namespace role templates namespace: %s, error: %s", namespace.Name, err)
+		return err
+	}
+	var errs []error
+	if err = r.pruneRoles(namespace, roles); err != nil {
+		errs = append(errs, err)
+	}
+	for i := range roles {
+		if err := r.checkAndCreateRole(namespace, &roles[i]); err != nil {
+			errs = append(errs, err)
 		}
-		if !reflect.DeepEqual(found.Rules, role.Rules) {
-			found.Rules = role.Rules
-			if err := r.Update(context.TODO(), found); err != nil {
-				klog.Error(err)
-				return err
-			}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (r *NamespaceReconcile) checkAndCreateRole(namespace *corev1.Namespace, role *rbac.Role) error {
+	found := &rbac.Role{}
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace.Name, Name: role.Name}, found)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			klog.Errorf("get role namespace: %s, role: %s, error: %s", namespace.Name, role.Name, err)
+			r.event(namespace, corev1.EventTypeWarning, roleFailedReason, "Failed to get role %s: %s", role.Name, err)
+			return err
 		}
+		role = role.DeepCopy()
+		role.Namespace = namespace.Name
+		if err = r.Create(context.TODO(), role); err != nil {
+			klog.Errorf("create role namespace: %s, role: %s, error: %s", namespace.Name, role.Name, err)
+			r.event(namespace, corev1.EventTypeWarning, roleFailedReason, "Failed to create role %s: %s", role.Name, err)
+			return err
+		}
+		r.event(namespace, corev1.EventTypeNormal, roleCreatedReason, "Created role %s", role.Name)
+		return nil
 	}
+
+	labels, labelsChanged := mergeStrings(found.Labels, role.Labels)
+	annotations, annotationsChanged := mergeStrings(found.Annotations, role.Annotations)
+	if !labelsChanged && !annotationsChanged && reflect.DeepEqual(found.Rules, role.Rules) {
+		return nil
+	}
+	found.Labels = labels
+	found.Annotations = annotations
+	found.Rules = role.Rules
+	if err := r.Update(context.TODO(), found); err != nil {
+		klog.Errorf("update role namespace: %s, role: %s, error: %s", namespace.Name, role.Name, err)
+		r.event(namespace, corev1.EventTypeWarning, roleFailedReason, "Failed to update role %s: %s", role.Name, err)
+		return err
+	}
+	r.event(namespace, corev1.EventTypeNormal, roleUpdatedReason, "Updated role %s", role.Name)
 	return nil
 }
 
-//只绑定创建者到admin
-func (r *NamespaceReconcile) checkAndCreateRoleBindings(namespace *corev1.Namespace) error {
-	return nil
+// mergeStrings returns current with the entries of desired set, and whether that changed anything.
+// Entries that are only in current are kept, so labels and annotations added by users survive.
+func mergeStrings(current, desired map[string]string) (map[string]string, bool) {
+	changed := false
+	for key, value := range desired {
+		if existing, ok := current[key]; ok && existing == value {
+			continue
+		}
+		if current == nil {
+			current = make(map[string]string, len(desired))
+		}
+		current[key] = value
+		changed = true
+	}
+	return current, changed
 }
 
-/*
-func (r *NamespaceReconcile) checkAndCreateRoleBindings(namespace *corev1.Namespace) error {
+// event records an event on the namespace when the reconciler has a recorder
+func (r *NamespaceReconcile) event(namespace *corev1.Namespace, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder != nil {
+		r.Recorder.Eventf(namespace, eventType, reason, messageFmt, args...)
+	}
+}
 
-	teamName := namespace.Labels[constants.TeamLabelKey]
-	creatorName := namespace.Annotations[constants.CreatorAnnotationKey]
+// namespaceRoles returns the Roles rendered from the NamespaceRoleTemplates, or the built-in roles when there is no template
+func (r *NamespaceReconcile) namespaceRoles() ([]rbac.Role, error) {
+	return NamespaceRoles(context.TODO(), r)
+}
 
-	creator := rbac.Subject{APIGroup: "rbac.authorization.k8s.io", Kind: "User", Name: creatorName}
+// NamespaceRoles returns the Roles of the team namespaces, rendered from the NamespaceRoleTemplates
+// or the built-in roles when there is no template
+func NamespaceRoles(ctx context.Context, c client.Reader) ([]rbac.Role, error) {
+	templates := &v1alpha1.NamespaceRoleTemplateList{}
+	if err := c.List(ctx, templates); err != nil {
+		return nil, err
+	}
+	if len(templates.Items) == 0 {
+		return defaultRoles, nil
+	}
+	roles := make([]rbac.Role, 0, len(templates.Items))
+	for i := range templates.Items {
+		roles = append(roles, renderNamespaceRole(&templates.Items[i]))
+	}
+	return roles, nil
+}
 
-	teamAdminBinding := &rbac.ClusterRoleBinding{}
+// NamespaceRole returns the named Role of the team namespaces as NamespaceRoles would, or nil when it is
+// not one of them. It reads the single template of the role, the other templates are only listed when the
+// role has none.
+func NamespaceRole(ctx context.Context, c client.Reader, name string) (*rbac.Role, error) {
+	template := &v1alpha1.NamespaceRoleTemplate{}
+	err := c.Get(ctx, types.NamespacedName{Name: name}, template)
+	if err == nil {
+		role := renderNamespaceRole(template)
+		return &role, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+	// the built-in roles only apply when there is no template at all
+	templates := &v1alpha1.NamespaceRoleTemplateList{}
+	if err := c.List(ctx, templates); err != nil {
+		return nil, err
+	}
+	if len(templates.Items) > 0 {
+		return nil, nil
+	}
+	for i := range defaultRoles {
+		if defaultRoles[i].Name == name {
+			return defaultRoles[i].DeepCopy(), nil
+		}
+	}
+	return nil, nil
+}
 
-	err := r.Get(context.TODO(), types.NamespacedName{Name: fmt.Sprintf("team:%s:admin", teamName)}, teamAdminBinding)
+// renderNamespaceRole returns the Role of a NamespaceRoleTemplate
+func renderNamespaceRole(template *v1alpha1.NamespaceRoleTemplate) rbac.Role {
+	role := rbac.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        template.Name,
+			Labels:      map[string]string{constants.ResourceLabel: constants.ResourceRole},
+			Annotations: map[string]string{constants.CreatorAnnotationKey: constants.System},
+		},
+		Rules: template.Spec.Rules,
+	}
+	if template.Spec.DisplayName != "" {
+		role.Annotations[constants.DisplayNameAnnotationKey] = template.Spec.DisplayName
+	}
+	if template.Spec.Description != "" {
+		role.Annotations[constants.DescriptionAnnotationKey] = template.Spec.Description
+	}
+	return role
+}
 
+// pruneRoles deletes the Roles created by the controller that are no longer in roles
+func (r *NamespaceReconcile) pruneRoles(namespace *corev1.Namespace, roles []rbac.Role) error {
+	roleList := &rbac.RoleList{}
+	err := r.List(context.TODO(), roleList, client.InNamespace(namespace.Name),
+		client.MatchingLabels{constants.ResourceLabel: constants.ResourceRole})
 	if err != nil {
+		klog.Errorf("list roles namespace: %s, error: %s", namespace.Name, err)
 		return err
 	}
-
-	adminBinding := &rbac.RoleBinding{}
-	adminBinding.Name = admin.Name
-	adminBinding.Namespace = namespace.Name
-	adminBinding.RoleRef = rbac.RoleRef{Name: admin.Name, APIGroup: "rbac.authorization.k8s.io", Kind: "Role"}
-	adminBinding.Subjects = teamAdminBinding.Subjects
-
-	if creator.Name != "" {
-		if adminBinding.Subjects == nil {
-			adminBinding.Subjects = make([]rbac.Subject, 0)
+	for i := range roleList.Items {
+		found := &roleList.Items[i]
+		if found.Annotations[constants.CreatorAnnotationKey] != constants.System || containsRole(roles, found.Name) {
+			continue
 		}
-		if !k8sutil.ContainsUser(adminBinding.Subjects, creatorName) {
-			adminBinding.Subjects = append(adminBinding.Subjects, creator)
+		klog.V(4).Infof("deleting stale role namespace: %s, role: %s", namespace.Name, found.Name)
+		if err := r.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("deleting role namespace: %s, role: %s, error: %s", namespace.Name, found.Name, err)
+			r.event(namespace, corev1.EventTypeWarning, roleFailedReason, "Failed to delete stale role %s: %s", found.Name, err)
+			return err
 		}
+		r.event(namespace, corev1.EventTypeNormal, roleDeletedReason, "Deleted stale role %s", found.Name)
 	}
+	return nil
+}
 
-	found := &rbac.RoleBinding{}
+func containsRole(roles []rbac.Role, name string) bool {
+	for _, role := range roles {
+		if role.Name == name {
+			return true
+		}
+	}
+	return false
+}
 
-	err = r.Get(context.TODO(), types.NamespacedName{Namespace: namespace.Name, Name: adminBinding.Name}, found)
+// checkAndCreateRoleBindings binds the members of each team role to its Role in the namespace. Team roles
+// whose Role is not defined by the NamespaceRoleTemplates are not bound.
+func (r *NamespaceReconcile) checkAndCreateRoleBindings(namespace *corev1.Namespace) error {
 
-	if errors.IsNotFound(err) {
-		err = r.Create(context.TODO(), adminBinding)
-		if err != nil {
-			klog.Errorf("creating role binding namespace: %s,role binding: %s, error: %s", namespace.Name, adminBinding.Name, err)
+	teamName := teamutil.TeamName(namespace)
+	creatorName := namespace.Annotations[constants.CreatorAnnotationKey]
+
+	team := &v1alpha1.Team{}
+	err := r.Get(context.TODO(), types.NamespacedName{Name: teamName}, team)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			klog.Errorf("get team namespace: %s, team: %s, error: %s", namespace.Name, teamName, err)
 			return err
 		}
-		found = adminBinding
-	} else if err != nil {
-		klog.Errorf("get role binding namespace: %s,role binding: %s, error: %s", namespace.Name, adminBinding.Name, err)
-		return err
+		// bind the creator only until the team shows up
+		team = &v1alpha1.Team{}
 	}
 
-	if !reflect.DeepEqual(found.RoleRef, adminBinding.RoleRef) {
-		err = r.Delete(context.TODO(), found)
-		if err != nil {
-			klog.Errorf("deleting role binding namespace: %s, role binding: %s, error: %s", namespace.Name, adminBinding.Name, err)
-			return err
-		}
-		err = fmt.Errorf("conflict role binding %s.%s, waiting for recreate", namespace.Name, adminBinding.Name)
-		klog.Errorf("conflict role binding namespace: %s, role binding: %s, error: %s", namespace.Name, adminBinding.Name, err)
+	// the admins and viewers of the ancestor teams hold the same roles in the namespace
+	ancestors, err := teamutil.InheritedAncestors(r, team)
+	if err != nil {
+		klog.Errorf("get ancestors namespace: %s, team: %s, error: %s", namespace.Name, teamName, err)
 		return err
 	}
 
-	if !reflect.DeepEqual(found.Subjects, adminBinding.Subjects) {
-		found.Subjects = adminBinding.Subjects
-		err = r.Update(context.TODO(), found)
-		if err != nil {
-			klog.Errorf("updating role binding namespace: %s, role binding: %s, error: %s", namespace.Name, adminBinding.Name, err)
-			return err
-		}
+	admins := teamutil.InheritedAdmins(team, ancestors)
+	if creatorName != "" && creatorName != constants.System {
+		admins = append(admins, rbac.Subject{APIGroup: rbac.GroupName, Kind: rbac.UserKind, Name: creatorName})
 	}
 
-	teamViewerBinding := &rbac.ClusterRoleBinding{}
-
-	err = r.Get(context.TODO(), types.NamespacedName{Name: fmt.Sprintf("team:%s:viewer", teamName)}, teamViewerBinding)
-
+	roles, err := r.namespaceRoles()
 	if err != nil {
+		klog.Errorf("list namespace role templates namespace: %s, error: %s", namespace.Name, err)
 		return err
 	}
-
-	viewerBinding := &rbac.RoleBinding{}
-	viewerBinding.Name = viewer.Name
-	viewerBinding.Namespace = namespace.Name
-	viewerBinding.RoleRef = rbac.RoleRef{Name: viewer.Name, APIGroup: "rbac.authorization.k8s.io", Kind: "Role"}
-	viewerBinding.Subjects = teamViewerBinding.Subjects
-
-	err = r.Get(context.TODO(), types.NamespacedName{Namespace: namespace.Name, Name: viewerBinding.Name}, found)
-
-	if errors.IsNotFound(err) {
-		err = r.Create(context.TODO(), viewerBinding)
-		if err != nil {
-			klog.Errorf("creating role binding namespace: %s, role binding: %s, error: %s", namespace.Name, viewerBinding.Name, err)
+	for _, binding := range []struct {
+		role    string
+		members []rbac.Subject
+	}{
+		{v1alpha1.TeamAdminTemplate, admins},
+		{v1alpha1.TeamRegularTemplate, team.Spec.Regulars},
+		{v1alpha1.TeamViewerTemplate, teamutil.InheritedViewers(team, ancestors)},
+	} {
+		roleName := NamespaceRoleNames[binding.role]
+		if !containsRole(roles, roleName) {
+			if err = r.deleteRoleBinding(namespace, roleName); err != nil {
+				return err
+			}
+			continue
+		}
+		members := teamutil.WithGroup(binding.members, teamName, binding.role)
+		if err = r.checkAndCreateRoleBinding(namespace, roleName, members); err != nil {
 			return err
 		}
-		found = viewerBinding
-	} else if err != nil {
-		return err
 	}
+	return nil
+}
 
-	if !reflect.DeepEqual(found.RoleRef, viewerBinding.RoleRef) {
-		err = r.Delete(context.TODO(), found)
-		if err != nil {
-			klog.Errorf("deleting conflict role binding namespace: %s, role binding: %s, %s", namespace.Name, viewerBinding.Name, err)
-			return err
-		}
-		err = fmt.Errorf("conflict role binding %s.%s, waiting for recreate", namespace.Name, viewerBinding.Name)
-		klog.Errorf("conflict role binding namespace: %s, role binding: %s, error: %s", namespace.Name, viewerBinding.Name, err)
+// deleteRoleBinding deletes the role binding named name if it was created by the controller
+func (r *NamespaceReconcile) deleteRoleBinding(namespace *corev1.Namespace, name string) error {
+	found := &rbac.RoleBinding{}
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace.Name, Name: name}, found)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		klog.Errorf("get role binding namespace: %s, role binding: %s, error: %s", namespace.Name, name, err)
 		return err
 	}
-
-	if !reflect.DeepEqual(found.Subjects, viewerBinding.Subjects) {
-		found.Subjects = viewerBinding.Subjects
-		err = r.Update(context.TODO(), found)
-		if err != nil {
-			klog.Errorf("updating role binding namespace: %s, role binding: %s, error: %s", namespace.Name, viewerBinding.Name, err)
-			return err
-		}
+	if found.Annotations[constants.CreatorAnnotationKey] != constants.System {
+		return nil
+	}
+	klog.V(4).Infof("deleting role binding without role namespace: %s, role binding: %s", namespace.Name, name)
+	if err := r.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+		klog.Errorf("deleting role binding namespace: %s, role binding: %s, error: %s", namespace.Name, name, err)
+		return err
 	}
-
 	return nil
 }
-*/
-func (r *NamespaceReconcile) checkAndBindTeam(namespace *corev1.Namespace) error {
 
-	teamName := namespace.Labels[constants.TeamLabelKey]
+// checkAndCreateRoleBinding makes the subjects of the role binding named after roleName match members exactly
+func (r *NamespaceReconcile) checkAndCreateRoleBinding(namespace *corev1.Namespace, roleName string, members []rbac.Subject) error {
 
-	if teamName == "" {
-		return nil
-	}
+	roleBinding := &rbac.RoleBinding{}
+	roleBinding.Name = roleName
+	roleBinding.Namespace = namespace.Name
+	roleBinding.Labels = map[string]string{constants.ResourceLabel: constants.ResourceRoleBinding}
+	roleBinding.Annotations = map[string]string{constants.CreatorAnnotationKey: constants.System}
+	roleBinding.RoleRef = rbac.RoleRef{Name: roleName, APIGroup: rbac.GroupName, Kind: "Role"}
+	roleBinding.Subjects = teamutil.Subjects(members)
 
-	team := &v1alpha1.Team{}
+	found := &rbac.RoleBinding{}
 
-	err := r.Get(context.TODO(), types.NamespacedName{Name: teamName}, team)
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace.Name, Name: roleBinding.Name}, found)
 
-	if err != nil {
-		// skip if team not found
-		if errors.IsNotFound(err) {
-			return nil
+	if errors.IsNotFound(err) {
+		err = r.Create(context.TODO(), roleBinding)
+		if err != nil {
+			klog.Errorf("creating role binding namespace: %s, role binding: %s, error: %s", namespace.Name, roleBinding.Name, err)
+			return err
 		}
-		klog.Errorf("bind team namespace: %s, team: %s, error: %s", namespace.Name, teamName, err)
+		found = roleBinding
+	} else if err != nil {
+		klog.Errorf("get role binding namespace: %s, role binding: %s, error: %s", namespace.Name, roleBinding.Name, err)
 		return err
 	}
 
-	if !metav1.IsControlledBy(namespace, team) {
-		if err := controllerutil.SetControllerReference(team, namespace, r.Scheme); err != nil {
-			klog.Errorf("bind team namespace: %s, team: %s, error: %s", namespace.Name, teamName, err)
+	if !reflect.DeepEqual(found.RoleRef, roleBinding.RoleRef) {
+		err = r.Delete(context.TODO(), found)
+		if err != nil {
+			klog.Errorf("deleting conflict role binding namespace: %s, role binding: %s, error: %s", namespace.Name, roleBinding.Name, err)
 			return err
 		}
-		err = r.Update(context.TODO(), namespace)
+		err = fmt.Errorf("conflict role binding %s.%s, waiting for recreate", namespace.Name, roleBinding.Name)
+		klog.Errorf("conflict role binding namespace: %s, role binding: %s, error: %s", namespace.Name, roleBinding.Name, err)
+		return err
+	}
+
+	if !teamutil.EqualSubjects(found.Subjects, roleBinding.Subjects) {
+		found.Subjects = roleBinding.Subjects
+		err = r.Update(context.TODO(), found)
 		if err != nil {
-			klog.Errorf("bind team namespace: %s, team: %s, error: %s", namespace.Name, teamName, err)
+			klog.Errorf("updating role binding namespace: %s, role binding: %s, error: %s", namespace.Name, roleBinding.Name, err)
 			return err
 		}
 	}
@@ -407,29 +713,18 @@ func (r *NamespaceReconcile) deleteRoleBindings(namespace *corev1.Namespace) err
 	}
 	return nil
 }
-func (r *NamespaceReconcile) chechOrAddTeamLabel(namespace *corev1.Namespace) (err error) {
-	team := ""
-	if namespace.Annotations != nil {
-		if value, ok := namespace.Annotations[constants.TeamAnnotationKey]; ok {
-			team = value
-		}
+
+// checkAndUpdateTeamLabels keeps the team labels in line with the team annotation, see teamutil.Labels
+func (r *NamespaceReconcile) checkAndUpdateTeamLabels(namespace *corev1.Namespace) error {
+	team := teamutil.TeamName(namespace)
+	if teamutil.HasLabels(namespace.Labels, team) {
+		return nil
 	}
-	if team != "" {
-		if namespace.Labels == nil {
-			namespace.Labels = make(map[string]string)
-			namespace.Labels[constants.TeamLabelKey] = base64.RawURLEncoding.EncodeToString([]byte(team))
-			if err := r.Update(context.Background(), namespace); err != nil {
-				return err
-			}
-		}
-		if value, ok := namespace.Labels[constants.TeamLabelKey]; !ok || (value == "") {
-			namespace.Labels[constants.TeamLabelKey] = base64.RawURLEncoding.EncodeToString([]byte(team))
-			if err := r.Update(context.Background(), namespace); err != nil {
-				return err
-			}
-		}
+	namespace.Labels = teamutil.SetLabels(namespace.Labels, team)
+	if err := r.Update(context.Background(), namespace); err != nil {
+		klog.Errorf("updating team labels namespace: %s, team: %s, error: %s", namespace.Name, team, err)
+		return err
 	}
-
 	return nil
 }
 
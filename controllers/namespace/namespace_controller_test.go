@@ -0,0 +1,236 @@
+package namespace
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+func TestTeamNamespaceMapper(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	team := &v1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}}
+	mapper := &teamNamespaceMapper{Client: fake.NewFakeClientWithScheme(scheme,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-dev", Labels: teamutil.Labels("nebula")}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other-dev", Labels: teamutil.Labels("other")}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+	)}
+
+	requests := mapper.Map(handler.MapObject{Meta: team, Object: team})
+	if len(requests) != 1 || requests[0].Name != "nebula-dev" {
+		t.Errorf("Map() = %v, want only nebula-dev", requests)
+	}
+}
+
+func TestTeamSpecChanged(t *testing.T) {
+	old := &v1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula", Generation: 1}}
+
+	statusOnly := old.DeepCopy()
+	statusOnly.Status.NamespaceCount = 2
+	specChanged := old.DeepCopy()
+	specChanged.Generation = 2
+	deleting := old.DeepCopy()
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+
+	for name, tc := range map[string]struct {
+		team *v1alpha1.Team
+		want bool
+	}{
+		"status only":  {statusOnly, false},
+		"spec changed": {specChanged, true},
+		"deleting":     {deleting, true},
+	} {
+		e := event.UpdateEvent{MetaOld: old, ObjectOld: old, MetaNew: tc.team, ObjectNew: tc.team}
+		if got := teamSpecChanged.Update(e); got != tc.want {
+			t.Errorf("%s: Update() = %v, want %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestCheckAndCreateRolesFromTemplates(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	generated := func(name string) *rbac.Role {
+		role := admin.DeepCopy()
+		role.Name = name
+		role.Namespace = "nebula-dev"
+		return role
+	}
+	custom := &rbac.Role{ObjectMeta: metav1.ObjectMeta{Name: "custom", Namespace: "nebula-dev",
+		Labels: map[string]string{constants.ResourceLabel: constants.ResourceRole}}}
+	r := &NamespaceReconcile{Client: fake.NewFakeClientWithScheme(scheme,
+		&v1alpha1.NamespaceRoleTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "operator"},
+			Spec: v1alpha1.NamespaceRoleTemplateSpec{
+				DisplayName: "运维",
+				Rules:       []rbac.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{"apps"}, Resources: []string{"*"}}},
+			},
+		},
+		generated("admin"), custom,
+	)}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-dev"}}
+
+	if err := r.checkAndCreateRoles(namespace); err != nil {
+		t.Fatal(err)
+	}
+
+	roles := &rbac.RoleList{}
+	if err := r.List(context.TODO(), roles, client.InNamespace("nebula-dev")); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, role := range roles.Items {
+		names = append(names, role.Name)
+		if role.Name == "operator" && role.Annotations[constants.DisplayNameAnnotationKey] != "运维" {
+			t.Errorf("operator annotations = %v", role.Annotations)
+		}
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"custom", "operator"}) {
+		t.Errorf("roles = %v, want the template role and the user role", names)
+	}
+}
+
+func TestNamespaceRole(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	operator := &v1alpha1.NamespaceRoleTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "operator"},
+		Spec: v1alpha1.NamespaceRoleTemplateSpec{
+			Rules: []rbac.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{"apps"}, Resources: []string{"*"}}},
+		},
+	}
+	withTemplates := fake.NewFakeClientWithScheme(scheme, operator)
+	withoutTemplates := fake.NewFakeClientWithScheme(scheme)
+
+	for name, tc := range map[string]struct {
+		client client.Reader
+		role   string
+		want   []rbac.PolicyRule
+	}{
+		"template":         {withTemplates, "operator", operator.Spec.Rules},
+		"not in templates": {withTemplates, admin.Name, nil},
+		"built-in":         {withoutTemplates, admin.Name, admin.Rules},
+		"not a built-in":   {withoutTemplates, "operator", nil},
+	} {
+		role, err := NamespaceRole(context.TODO(), tc.client, tc.role)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if tc.want == nil {
+			if role != nil {
+				t.Errorf("%s: NamespaceRole() = %v, want nil", name, role.Name)
+			}
+			continue
+		}
+		if role == nil || role.Name != tc.role || !reflect.DeepEqual(role.Rules, tc.want) {
+			t.Errorf("%s: NamespaceRole() = %+v, want the rules of %s", name, role, tc.role)
+		}
+	}
+}
+
+func TestCheckAndCreateRolesInOnePass(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	stale := developer.DeepCopy()
+	stale.Namespace = "nebula-dev"
+	stale.Labels = map[string]string{"owner": "someone"}
+	stale.Annotations = nil
+	recorder := record.NewFakeRecorder(10)
+	r := &NamespaceReconcile{Client: fake.NewFakeClientWithScheme(scheme, stale), Recorder: recorder}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-dev"}}
+
+	if err := r.checkAndCreateRoles(namespace); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range defaultRoles {
+		found := &rbac.Role{}
+		if err := r.Get(context.TODO(), types.NamespacedName{Namespace: "nebula-dev", Name: want.Name}, found); err != nil {
+			t.Fatalf("role %s: %v", want.Name, err)
+		}
+		if !reflect.DeepEqual(found.Annotations, want.Annotations) || found.Labels[constants.ResourceLabel] != constants.ResourceRole {
+			t.Errorf("role %s metadata = %+v", want.Name, found.ObjectMeta)
+		}
+	}
+	found := &rbac.Role{}
+	_ = r.Get(context.TODO(), types.NamespacedName{Namespace: "nebula-dev", Name: developer.Name}, found)
+	if found.Labels["owner"] != "someone" {
+		t.Errorf("user label dropped: %v", found.Labels)
+	}
+
+	close(recorder.Events)
+	var events []string
+	for event := range recorder.Events {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+	want := []string{
+		"Normal RoleCreated Created role admin",
+		"Normal RoleCreated Created role viewer",
+		"Normal RoleUpdated Updated role developer",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
+
+func TestCheckAndCreateRoleBindingsFromTemplates(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	binding := func(name, creator string) *rbac.RoleBinding {
+		return &rbac.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "nebula-dev", Annotations: map[string]string{constants.CreatorAnnotationKey: creator}},
+			RoleRef:    rbac.RoleRef{APIGroup: rbac.GroupName, Kind: "Role", Name: name},
+		}
+	}
+	r := &NamespaceReconcile{Client: fake.NewFakeClientWithScheme(scheme,
+		&v1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}, Spec: v1alpha1.TeamSpec{Manager: "alice"}},
+		&v1alpha1.NamespaceRoleTemplate{ObjectMeta: metav1.ObjectMeta{Name: admin.Name}},
+		&v1alpha1.NamespaceRoleTemplate{ObjectMeta: metav1.ObjectMeta{Name: "operator"}},
+		binding(developer.Name, constants.System),
+		binding(viewer.Name, "bob"),
+	)}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-dev", Labels: teamutil.Labels("nebula"),
+		Annotations: map[string]string{constants.TeamAnnotationKey: "nebula"}}}
+
+	if err := r.checkAndCreateRoleBindings(namespace); err != nil {
+		t.Fatal(err)
+	}
+
+	bindings := &rbac.RoleBindingList{}
+	if err := r.List(context.TODO(), bindings, client.InNamespace("nebula-dev")); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, binding := range bindings.Items {
+		names = append(names, binding.Name)
+	}
+	sort.Strings(names)
+	// no template defines the developer and viewer roles, the binding created by bob is left alone
+	if !reflect.DeepEqual(names, []string{admin.Name, viewer.Name}) {
+		t.Errorf("role bindings = %v, want the admin binding and the user binding", names)
+	}
+}
@@ -0,0 +1,148 @@
+package namespace
+
+import (
+	"context"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// networkPolicyName is the name of the NetworkPolicy isolating a team namespace
+const networkPolicyName = "team-isolation"
+
+// namespaceNameLabelKey is the label carrying the name of every namespace, set by Kubernetes 1.21 and later
+const namespaceNameLabelKey = "kubernetes.io/metadata.name"
+
+// Reasons of the events recorded on namespaces for their network policy
+const (
+	networkPolicyAppliedReason = "NetworkPolicyApplied"
+	networkPolicyFailedReason  = "NetworkPolicyFailed"
+)
+
+// isolationPolicy returns the ingress NetworkPolicy of the namespace for the isolation mode of its team,
+// nil when the namespace is not isolated
+func (r *NamespaceReconcile) isolationPolicy(namespace *corev1.Namespace, isolation v1alpha1.NetworkIsolation) *networking.NetworkPolicy {
+	var peers []networking.NetworkPolicyPeer
+	switch isolation {
+	case v1alpha1.TeamNetworkIsolation:
+		peers = append(peers, networking.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: teamutil.Labels(teamutil.TeamName(namespace))},
+		})
+	case v1alpha1.NamespaceNetworkIsolation:
+		peers = append(peers, networking.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{}})
+	default:
+		return nil
+	}
+	if len(r.NetworkSystemNamespaces) > 0 {
+		peers = append(peers, networking.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      namespaceNameLabelKey,
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   r.NetworkSystemNamespaces,
+			}}},
+		})
+	}
+
+	policy := &networking.NetworkPolicy{}
+	policy.Name = networkPolicyName
+	policy.Namespace = namespace.Name
+	policy.Labels = map[string]string{constants.ResourceLabel: constants.ResourceNetworkPolicy}
+	policy.Annotations = map[string]string{constants.CreatorAnnotationKey: constants.System}
+	policy.Spec = networking.NetworkPolicySpec{
+		PodSelector: metav1.LabelSelector{},
+		Ingress:     []networking.NetworkPolicyIngressRule{{From: peers}},
+		PolicyTypes: []networking.PolicyType{networking.PolicyTypeIngress},
+	}
+	return policy
+}
+
+// checkAndCreateNetworkPolicy makes the NetworkPolicy of the namespace match the network isolation of its team,
+// overwriting manual changes, and deletes it when the team is not isolated
+func (r *NamespaceReconcile) checkAndCreateNetworkPolicy(namespace *corev1.Namespace) error {
+	teamName := teamutil.TeamName(namespace)
+	team := &v1alpha1.Team{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: teamName}, team); err != nil {
+		if !errors.IsNotFound(err) {
+			klog.Errorf("get team namespace: %s, team: %s, error: %s", namespace.Name, teamName, err)
+			return err
+		}
+		team = &v1alpha1.Team{}
+	}
+
+	policy := r.isolationPolicy(namespace, team.GetNetworkIsolation())
+	if policy == nil {
+		return r.deleteNetworkPolicy(namespace)
+	}
+
+	found := &networking.NetworkPolicy{}
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace.Name, Name: policy.Name}, found)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			klog.Errorf("get network policy namespace: %s, error: %s", namespace.Name, err)
+			return err
+		}
+		if err = r.Create(context.TODO(), policy); err != nil {
+			klog.Errorf("create network policy namespace: %s, error: %s", namespace.Name, err)
+			r.event(namespace, corev1.EventTypeWarning, networkPolicyFailedReason, "Failed to create network policy %s: %s", policy.Name, err)
+			return err
+		}
+		r.event(namespace, corev1.EventTypeNormal, networkPolicyAppliedReason, "Created network policy %s for %s isolation", policy.Name, team.GetNetworkIsolation())
+		return nil
+	}
+
+	labels, labelsChanged := mergeStrings(found.Labels, policy.Labels)
+	annotations, annotationsChanged := mergeStrings(found.Annotations, policy.Annotations)
+	if !labelsChanged && !annotationsChanged && reflect.DeepEqual(found.Spec, policy.Spec) {
+		return nil
+	}
+	found.Labels = labels
+	found.Annotations = annotations
+	found.Spec = policy.Spec
+	if err := r.Update(context.TODO(), found); err != nil {
+		klog.Errorf("update network policy namespace: %s, error: %s", namespace.Name, err)
+		r.event(namespace, corev1.EventTypeWarning, networkPolicyFailedReason, "Failed to update network policy %s: %s", policy.Name, err)
+		return err
+	}
+	r.event(namespace, corev1.EventTypeNormal, networkPolicyAppliedReason, "Updated network policy %s for %s isolation", policy.Name, team.GetNetworkIsolation())
+	return nil
+}
+
+// deleteNetworkPolicy deletes the NetworkPolicy created by the controller
+func (r *NamespaceReconcile) deleteNetworkPolicy(namespace *corev1.Namespace) error {
+	found := &networking.NetworkPolicy{}
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace.Name, Name: networkPolicyName}, found)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		klog.Errorf("get network policy namespace: %s, error: %s", namespace.Name, err)
+		return err
+	}
+	if found.Annotations[constants.CreatorAnnotationKey] != constants.System {
+		return nil
+	}
+	if err := r.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+		klog.Errorf("delete network policy namespace: %s, error: %s", namespace.Name, err)
+		return err
+	}
+	return nil
+}
+
+// networkPolicyNamespaceMapper maps the NetworkPolicy created by the controller to its namespace,
+// so that manual changes are reverted
+func networkPolicyNamespaceMapper(obj handler.MapObject) []reconcile.Request {
+	if obj.Meta.GetName() != networkPolicyName || obj.Meta.GetLabels()[constants.ResourceLabel] != constants.ResourceNetworkPolicy {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: obj.Meta.GetNamespace()}}}
+}
@@ -0,0 +1,77 @@
+package namespace
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCheckAndCreateNetworkPolicy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	team := &v1alpha1.Team{
+		ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+		Spec:       v1alpha1.TeamSpec{NetworkIsolation: v1alpha1.TeamNetworkIsolation},
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-dev", Labels: teamutil.Labels("nebula")}}
+	r := &NamespaceReconcile{
+		Client:                  fake.NewFakeClientWithScheme(scheme, team),
+		NetworkSystemNamespaces: []string{"kube-system"},
+	}
+	key := types.NamespacedName{Namespace: "nebula-dev", Name: networkPolicyName}
+
+	if err := r.checkAndCreateNetworkPolicy(namespace); err != nil {
+		t.Fatal(err)
+	}
+	policy := &networking.NetworkPolicy{}
+	if err := r.Get(context.TODO(), key, policy); err != nil {
+		t.Fatal(err)
+	}
+	from := policy.Spec.Ingress[0].From
+	if len(from) != 2 || !reflect.DeepEqual(from[0].NamespaceSelector.MatchLabels, teamutil.Labels("nebula")) ||
+		!reflect.DeepEqual(from[1].NamespaceSelector.MatchExpressions[0].Values, []string{"kube-system"}) {
+		t.Errorf("team isolation ingress = %+v", from)
+	}
+
+	// manual changes are reverted
+	policy.Spec.Ingress = nil
+	if err := r.Update(context.TODO(), policy); err != nil {
+		t.Fatal(err)
+	}
+	team.Spec.NetworkIsolation = v1alpha1.NamespaceNetworkIsolation
+	if err := r.Update(context.TODO(), team); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.checkAndCreateNetworkPolicy(namespace); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(context.TODO(), key, policy); err != nil {
+		t.Fatal(err)
+	}
+	if from := policy.Spec.Ingress[0].From; len(from) != 2 || from[0].PodSelector == nil || from[0].NamespaceSelector != nil {
+		t.Errorf("namespace isolation ingress = %+v", from)
+	}
+
+	team.Spec.NetworkIsolation = v1alpha1.NoNetworkIsolation
+	if err := r.Update(context.TODO(), team); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.checkAndCreateNetworkPolicy(namespace); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(context.TODO(), key, policy); !errors.IsNotFound(err) {
+		t.Errorf("network policy not deleted: %v", err)
+	}
+}
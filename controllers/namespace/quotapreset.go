@@ -0,0 +1,203 @@
+package namespace
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog"
+	"kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// quotaPresetObjectName is the name of the ResourceQuota and the LimitRange created from a QuotaPreset
+const quotaPresetObjectName = "quota-preset"
+
+// Reasons of the events recorded on namespaces for their quota preset
+const (
+	quotaPresetAppliedReason  = "QuotaPresetApplied"
+	quotaPresetNotFoundReason = "QuotaPresetNotFound"
+	quotaPresetFailedReason   = "QuotaPresetFailed"
+)
+
+// quotaPresetName returns the QuotaPreset of the namespace, the quota-preset annotation overrides the one of the team
+func quotaPresetName(namespace *corev1.Namespace, team *v1alpha1.Team) string {
+	if name, ok := namespace.Annotations[constants.QuotaPresetAnnotationKey]; ok {
+		return name
+	}
+	return team.Spec.QuotaPreset
+}
+
+// checkAndCreateQuota makes the ResourceQuota and LimitRange of the namespace match its QuotaPreset, overwriting
+// manual changes, and deletes them when the namespace no longer uses a preset. Namespaces with the
+// unmanaged-quota annotation are left alone.
+func (r *NamespaceReconcile) checkAndCreateQuota(namespace *corev1.Namespace) error {
+	if namespace.Annotations[constants.UnmanagedQuotaAnnotationKey] == "true" {
+		return nil
+	}
+
+	teamName := teamutil.TeamName(namespace)
+	team := &v1alpha1.Team{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: teamName}, team); err != nil {
+		if !errors.IsNotFound(err) {
+			klog.Errorf("get team namespace: %s, team: %s, error: %s", namespace.Name, teamName, err)
+			return err
+		}
+		team = &v1alpha1.Team{}
+	}
+
+	preset := &v1alpha1.QuotaPreset{}
+	if presetName := quotaPresetName(namespace, team); presetName != "" {
+		if err := r.Get(context.TODO(), types.NamespacedName{Name: presetName}, preset); err != nil {
+			if !errors.IsNotFound(err) {
+				klog.Errorf("get quota preset namespace: %s, preset: %s, error: %s", namespace.Name, presetName, err)
+				return err
+			}
+			// keep the current objects, the namespace is requeued when the preset is created
+			r.event(namespace, corev1.EventTypeWarning, quotaPresetNotFoundReason, "Quota preset %s not found", presetName)
+			return nil
+		}
+	}
+
+	return r.checkAndCreateQuotaObjects(namespace, preset)
+}
+
+// checkAndCreateQuotaObjects makes the ResourceQuota and the LimitRange of the namespace match preset,
+// an empty preset deletes them
+func (r *NamespaceReconcile) checkAndCreateQuotaObjects(namespace *corev1.Namespace, preset *v1alpha1.QuotaPreset) error {
+	var errs []error
+	if err := r.checkAndCreateResourceQuota(namespace, preset); err != nil {
+		errs = append(errs, err)
+	}
+	if err := r.checkAndCreateLimitRange(namespace, preset); err != nil {
+		errs = append(errs, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// checkAndCreateResourceQuota creates or updates the ResourceQuota of the preset, or deletes it when the preset has none
+func (r *NamespaceReconcile) checkAndCreateResourceQuota(namespace *corev1.Namespace, preset *v1alpha1.QuotaPreset) error {
+	found := &corev1.ResourceQuota{}
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace.Name, Name: quotaPresetObjectName}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		klog.Errorf("get resource quota namespace: %s, error: %s", namespace.Name, err)
+		return err
+	}
+	exists := err == nil
+
+	if preset.Spec.ResourceQuota == nil {
+		if !exists || found.Labels[constants.ResourceLabel] != constants.ResourceResourceQuota {
+			return nil
+		}
+		if err := r.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("delete resource quota namespace: %s, error: %s", namespace.Name, err)
+			return err
+		}
+		return nil
+	}
+
+	labels := map[string]string{constants.ResourceLabel: constants.ResourceResourceQuota, constants.QuotaPresetLabelKey: preset.Name}
+	if !exists {
+		quota := &corev1.ResourceQuota{}
+		quota.Name = quotaPresetObjectName
+		quota.Namespace = namespace.Name
+		quota.Labels = labels
+		quota.Spec = *preset.Spec.ResourceQuota.DeepCopy()
+		if err := r.Create(context.TODO(), quota); err != nil {
+			klog.Errorf("create resource quota namespace: %s, error: %s", namespace.Name, err)
+			r.event(namespace, corev1.EventTypeWarning, quotaPresetFailedReason, "Failed to create resource quota of preset %s: %s", preset.Name, err)
+			return err
+		}
+		r.event(namespace, corev1.EventTypeNormal, quotaPresetAppliedReason, "Created resource quota of preset %s", preset.Name)
+		return nil
+	}
+
+	mergedLabels, labelsChanged := mergeStrings(found.Labels, labels)
+	if !labelsChanged && equality.Semantic.DeepEqual(found.Spec, *preset.Spec.ResourceQuota) {
+		return nil
+	}
+	found.Labels = mergedLabels
+	found.Spec = *preset.Spec.ResourceQuota.DeepCopy()
+	if err := r.Update(context.TODO(), found); err != nil {
+		klog.Errorf("update resource quota namespace: %s, error: %s", namespace.Name, err)
+		r.event(namespace, corev1.EventTypeWarning, quotaPresetFailedReason, "Failed to update resource quota of preset %s: %s", preset.Name, err)
+		return err
+	}
+	r.event(namespace, corev1.EventTypeNormal, quotaPresetAppliedReason, "Updated resource quota of preset %s", preset.Name)
+	return nil
+}
+
+// checkAndCreateLimitRange creates or updates the LimitRange of the preset, or deletes it when the preset has none
+func (r *NamespaceReconcile) checkAndCreateLimitRange(namespace *corev1.Namespace, preset *v1alpha1.QuotaPreset) error {
+	found := &corev1.LimitRange{}
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace.Name, Name: quotaPresetObjectName}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		klog.Errorf("get limit range namespace: %s, error: %s", namespace.Name, err)
+		return err
+	}
+	exists := err == nil
+
+	if preset.Spec.LimitRange == nil {
+		if !exists || found.Labels[constants.ResourceLabel] != constants.ResourceLimitRange {
+			return nil
+		}
+		if err := r.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("delete limit range namespace: %s, error: %s", namespace.Name, err)
+			return err
+		}
+		return nil
+	}
+
+	labels := map[string]string{constants.ResourceLabel: constants.ResourceLimitRange, constants.QuotaPresetLabelKey: preset.Name}
+	if !exists {
+		limitRange := &corev1.LimitRange{}
+		limitRange.Name = quotaPresetObjectName
+		limitRange.Namespace = namespace.Name
+		limitRange.Labels = labels
+		limitRange.Spec = *preset.Spec.LimitRange.DeepCopy()
+		if err := r.Create(context.TODO(), limitRange); err != nil {
+			klog.Errorf("create limit range namespace: %s, error: %s", namespace.Name, err)
+			r.event(namespace, corev1.EventTypeWarning, quotaPresetFailedReason, "Failed to create limit range of preset %s: %s", preset.Name, err)
+			return err
+		}
+		r.event(namespace, corev1.EventTypeNormal, quotaPresetAppliedReason, "Created limit range of preset %s", preset.Name)
+		return nil
+	}
+
+	mergedLabels, labelsChanged := mergeStrings(found.Labels, labels)
+	if !labelsChanged && equality.Semantic.DeepEqual(found.Spec, *preset.Spec.LimitRange) {
+		return nil
+	}
+	found.Labels = mergedLabels
+	found.Spec = *preset.Spec.LimitRange.DeepCopy()
+	if err := r.Update(context.TODO(), found); err != nil {
+		klog.Errorf("update limit range namespace: %s, error: %s", namespace.Name, err)
+		r.event(namespace, corev1.EventTypeWarning, quotaPresetFailedReason, "Failed to update limit range of preset %s: %s", preset.Name, err)
+		return err
+	}
+	r.event(namespace, corev1.EventTypeNormal, quotaPresetAppliedReason, "Updated limit range of preset %s", preset.Name)
+	return nil
+}
+
+// deleteQuota deletes the ResourceQuota and LimitRange created from a QuotaPreset, unless the namespace opted out
+func (r *NamespaceReconcile) deleteQuota(namespace *corev1.Namespace) error {
+	if namespace.Annotations[constants.UnmanagedQuotaAnnotationKey] == "true" {
+		return nil
+	}
+	return r.checkAndCreateQuotaObjects(namespace, &v1alpha1.QuotaPreset{})
+}
+
+// quotaObjectNamespaceMapper maps a ResourceQuota or LimitRange created from a QuotaPreset to its namespace,
+// so that manual changes are reverted
+func quotaObjectNamespaceMapper(obj handler.MapObject) []reconcile.Request {
+	if _, ok := obj.Meta.GetLabels()[constants.QuotaPresetLabelKey]; !ok || obj.Meta.GetName() != quotaPresetObjectName {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: obj.Meta.GetNamespace()}}}
+}
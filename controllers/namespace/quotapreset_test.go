@@ -0,0 +1,83 @@
+package namespace
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCheckAndCreateQuota(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	preset := func(name, pods string) *v1alpha1.QuotaPreset {
+		return &v1alpha1.QuotaPreset{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alpha1.QuotaPresetSpec{
+				ResourceQuota: &corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse(pods)}},
+				LimitRange: &corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+					Type:           corev1.LimitTypeContainer,
+					DefaultRequest: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+				}}},
+			},
+		}
+	}
+	namespace := func(name string, annotations map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: teamutil.Labels("nebula"), Annotations: annotations}}
+	}
+	drifted := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: quotaPresetObjectName, Namespace: "nebula-dev",
+			Labels: map[string]string{constants.ResourceLabel: constants.ResourceResourceQuota, constants.QuotaPresetLabelKey: "small"}},
+		Spec: corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1000")}},
+	}
+	unmanaged := drifted.DeepCopy()
+	unmanaged.Namespace = "nebula-custom"
+	r := &NamespaceReconcile{Client: fake.NewFakeClientWithScheme(scheme,
+		&v1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}, Spec: v1alpha1.TeamSpec{QuotaPreset: "small"}},
+		preset("small", "10"), preset("large", "100"), drifted, unmanaged,
+	)}
+
+	tests := []struct {
+		namespace *corev1.Namespace
+		pods      int64
+	}{
+		{namespace("nebula-dev", nil), 10},
+		{namespace("nebula-prod", map[string]string{constants.QuotaPresetAnnotationKey: "large"}), 100},
+		{namespace("nebula-custom", map[string]string{constants.UnmanagedQuotaAnnotationKey: "true"}), 1000},
+	}
+	for _, test := range tests {
+		if err := r.checkAndCreateQuota(test.namespace); err != nil {
+			t.Fatalf("%s: %v", test.namespace.Name, err)
+		}
+		quota := &corev1.ResourceQuota{}
+		if err := r.Get(context.TODO(), types.NamespacedName{Namespace: test.namespace.Name, Name: quotaPresetObjectName}, quota); err != nil {
+			t.Fatalf("%s: %v", test.namespace.Name, err)
+		}
+		if pods := quota.Spec.Hard[corev1.ResourcePods]; pods.Value() != test.pods {
+			t.Errorf("%s: pods quota = %s, expected %d", test.namespace.Name, pods.String(), test.pods)
+		}
+	}
+
+	// a namespace that no longer uses a preset loses its quota objects
+	optedOut := namespace("nebula-dev", map[string]string{constants.QuotaPresetAnnotationKey: ""})
+	if err := r.checkAndCreateQuota(optedOut); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: "nebula-dev", Name: quotaPresetObjectName}, &corev1.ResourceQuota{}); !errors.IsNotFound(err) {
+		t.Errorf("resource quota not deleted: %v", err)
+	}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: "nebula-dev", Name: quotaPresetObjectName}, &corev1.LimitRange{}); !errors.IsNotFound(err) {
+		t.Errorf("limit range not deleted: %v", err)
+	}
+}
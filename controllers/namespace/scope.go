@@ -0,0 +1,58 @@
+package namespace
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/sliceutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Scope selects the namespaces managed by the namespace controller.
+// A namespace is in scope when it is not excluded by name and its labels match the selector.
+type Scope struct {
+	// ExcludedNamespaces are never managed, whatever their labels
+	ExcludedNamespaces []string
+	// Selector restricts the managed namespaces, nil selects every namespace
+	Selector labels.Selector
+}
+
+// DefaultScope excludes the system namespaces and selects every other namespace.
+func DefaultScope() Scope {
+	return Scope{ExcludedNamespaces: constants.SystemNamespaces, Selector: labels.Everything()}
+}
+
+// Contains reports whether the namespace is in scope.
+func (s Scope) Contains(namespace metav1.Object) bool {
+	if sliceutil.HasString(s.ExcludedNamespaces, namespace.GetName()) {
+		return false
+	}
+	return s.Selector == nil || s.Selector.Matches(labels.Set(namespace.GetLabels()))
+}
+
+// Predicate filters namespace events down to the namespaces in scope and the namespaces whose finalizer
+// has to be removed.
+func (s Scope) Predicate() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return s.Contains(e.Meta) || releasing(e.Meta)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return s.Contains(e.MetaNew) || releasing(e.MetaNew)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return s.Contains(e.Meta)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return s.Contains(e.Meta) || releasing(e.Meta)
+		},
+	}
+}
+
+// releasing reports whether the namespace is being deleted and still carries the namespace finalizer.
+// The finalizer is removed whatever the scope, so a namespace that left the scope after it was added does
+// not hang in Terminating.
+func releasing(namespace metav1.Object) bool {
+	return namespace.GetDeletionTimestamp() != nil && sliceutil.HasString(namespace.GetFinalizers(), constants.NamespaceFinalizer)
+}
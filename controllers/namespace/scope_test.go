@@ -0,0 +1,93 @@
+package namespace
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"kubenebula.io/kubenebula/constants"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestScopeContains(t *testing.T) {
+	namespace := func(name string, nsLabels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: nsLabels}}
+	}
+	selected := Scope{
+		ExcludedNamespaces: []string{"kube-system"},
+		Selector:           labels.SelectorFromSet(labels.Set{"tenant": "true"}),
+	}
+
+	for _, tc := range []struct {
+		name      string
+		scope     Scope
+		namespace *corev1.Namespace
+		want      bool
+	}{
+		{"default scope", DefaultScope(), namespace("dev", nil), true},
+		{"default scope system", DefaultScope(), namespace("kube-system", nil), false},
+		{"zero scope", Scope{}, namespace("kube-system", nil), true},
+		{"selected", selected, namespace("dev", map[string]string{"tenant": "true"}), true},
+		{"not selected", selected, namespace("dev", nil), false},
+		{"excluded and selected", selected, namespace("kube-system", map[string]string{"tenant": "true"}), false},
+	} {
+		if got := tc.scope.Contains(tc.namespace); got != tc.want {
+			t.Errorf("%s: Contains() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestScopePredicate(t *testing.T) {
+	scope := Scope{Selector: labels.SelectorFromSet(labels.Set{"tenant": "true"})}
+	now := metav1.Now()
+	outOfScope := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dev"}}
+	terminating := outOfScope.DeepCopy()
+	terminating.DeletionTimestamp = &now
+	finalized := terminating.DeepCopy()
+	finalized.Finalizers = []string{constants.NamespaceFinalizer}
+
+	for _, tc := range []struct {
+		name      string
+		namespace *corev1.Namespace
+		want      bool
+	}{
+		{"out of scope", outOfScope, false},
+		{"out of scope terminating", terminating, false},
+		{"out of scope terminating with finalizer", finalized, true},
+	} {
+		e := event.UpdateEvent{MetaOld: outOfScope, ObjectOld: outOfScope, MetaNew: tc.namespace, ObjectNew: tc.namespace}
+		if got := scope.Predicate().Update(e); got != tc.want {
+			t.Errorf("%s: Update() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestReconcileRemovesFinalizerOutOfScope(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	now := metav1.Now()
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:              "kube-system",
+		DeletionTimestamp: &now,
+		Finalizers:        []string{constants.NamespaceFinalizer, "kubernetes"},
+	}}
+	r := &NamespaceReconcile{Client: fake.NewFakeClientWithScheme(scheme, namespace), Scope: DefaultScope()}
+
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "kube-system"}}); err != nil {
+		t.Fatal(err)
+	}
+	found := &corev1.Namespace{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "kube-system"}, found); err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Finalizers) != 1 || found.Finalizers[0] != "kubernetes" {
+		t.Errorf("finalizers = %v, want only kubernetes", found.Finalizers)
+	}
+}
@@ -0,0 +1,184 @@
+package namespace
+
+import (
+	"context"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog"
+	"kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const roleConflictReason = "RoleConflict"
+
+// checkAndCreateTeamRoles materializes every TeamRole of the namespace's team as a Role and a RoleBinding
+// named after the role, and deletes the ones of TeamRoles that are gone or moved to another team
+func (r *NamespaceReconcile) checkAndCreateTeamRoles(namespace *corev1.Namespace) error {
+	teamName := teamutil.TeamName(namespace)
+	teamRoles := &v1alpha1.TeamRoleList{}
+	if err := r.List(context.TODO(), teamRoles); err != nil {
+		klog.Errorf("list team roles namespace: %s, error: %s", namespace.Name, err)
+		return err
+	}
+	var desired []v1alpha1.TeamRole
+	for _, teamRole := range teamRoles.Items {
+		if teamRole.Spec.Team == teamName && teamRole.DeletionTimestamp.IsZero() {
+			desired = append(desired, teamRole)
+		}
+	}
+
+	var errs []error
+	if err := r.pruneTeamRoles(namespace, desired); err != nil {
+		errs = append(errs, err)
+	}
+	for i := range desired {
+		if err := r.checkAndCreateTeamRole(namespace, &desired[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (r *NamespaceReconcile) checkAndCreateTeamRole(namespace *corev1.Namespace, teamRole *v1alpha1.TeamRole) error {
+	role := &rbac.Role{}
+	role.Name = teamRole.GetRoleName()
+	role.Namespace = namespace.Name
+	role.Labels = map[string]string{constants.ResourceLabel: constants.ResourceRole, constants.TeamRoleLabelKey: teamRole.Name}
+	role.Rules = teamRole.Spec.Rules
+	if err := controllerutil.SetControllerReference(teamRole, role, r.Scheme); err != nil {
+		return err
+	}
+
+	found := &rbac.Role{}
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace.Name, Name: role.Name}, found)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			klog.Errorf("get role namespace: %s, role: %s, error: %s", namespace.Name, role.Name, err)
+			return err
+		}
+		if err = r.Create(context.TODO(), role); err != nil {
+			klog.Errorf("create role namespace: %s, role: %s, error: %s", namespace.Name, role.Name, err)
+			r.event(namespace, corev1.EventTypeWarning, roleFailedReason, "Failed to create role %s: %s", role.Name, err)
+			return err
+		}
+		r.event(namespace, corev1.EventTypeNormal, roleCreatedReason, "Created role %s of team role %s", role.Name, teamRole.Name)
+	} else if found.Labels[constants.TeamRoleLabelKey] != teamRole.Name {
+		r.event(namespace, corev1.EventTypeWarning, roleConflictReason, "Role %s already exists, team role %s is not applied", role.Name, teamRole.Name)
+		return nil
+	} else {
+		labels, labelsChanged := mergeStrings(found.Labels, role.Labels)
+		if labelsChanged || !reflect.DeepEqual(found.Rules, role.Rules) {
+			found.Labels = labels
+			found.Rules = role.Rules
+			if err := r.Update(context.TODO(), found); err != nil {
+				klog.Errorf("update role namespace: %s, role: %s, error: %s", namespace.Name, role.Name, err)
+				r.event(namespace, corev1.EventTypeWarning, roleFailedReason, "Failed to update role %s: %s", role.Name, err)
+				return err
+			}
+			r.event(namespace, corev1.EventTypeNormal, roleUpdatedReason, "Updated role %s of team role %s", role.Name, teamRole.Name)
+		}
+	}
+
+	return r.checkAndCreateTeamRoleBinding(namespace, teamRole)
+}
+
+func (r *NamespaceReconcile) checkAndCreateTeamRoleBinding(namespace *corev1.Namespace, teamRole *v1alpha1.TeamRole) error {
+	roleBinding := &rbac.RoleBinding{}
+	roleBinding.Name = teamRole.GetRoleName()
+	roleBinding.Namespace = namespace.Name
+	roleBinding.Labels = map[string]string{constants.ResourceLabel: constants.ResourceRoleBinding, constants.TeamRoleLabelKey: teamRole.Name}
+	roleBinding.RoleRef = rbac.RoleRef{APIGroup: rbac.GroupName, Kind: "Role", Name: teamRole.GetRoleName()}
+	roleBinding.Subjects = teamutil.Subjects(teamutil.WithGroup(teamRole.Spec.Members, teamRole.Spec.Team, teamRole.GetRoleName()))
+	if err := controllerutil.SetControllerReference(teamRole, roleBinding, r.Scheme); err != nil {
+		return err
+	}
+
+	found := &rbac.RoleBinding{}
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace.Name, Name: roleBinding.Name}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		klog.Errorf("get role binding namespace: %s, role binding: %s, error: %s", namespace.Name, roleBinding.Name, err)
+		return err
+	}
+	if err == nil {
+		if found.Labels[constants.TeamRoleLabelKey] != teamRole.Name {
+			r.event(namespace, corev1.EventTypeWarning, roleConflictReason, "Role binding %s already exists, team role %s is not applied", roleBinding.Name, teamRole.Name)
+			return nil
+		}
+		if reflect.DeepEqual(found.RoleRef, roleBinding.RoleRef) {
+			if teamutil.EqualSubjects(found.Subjects, roleBinding.Subjects) {
+				return nil
+			}
+			found.Subjects = roleBinding.Subjects
+			if err := r.Update(context.TODO(), found); err != nil {
+				klog.Errorf("update role binding namespace: %s, role binding: %s, error: %s", namespace.Name, roleBinding.Name, err)
+				return err
+			}
+			return nil
+		}
+		// The role ref is immutable, create the binding again
+		if err := r.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("delete role binding namespace: %s, role binding: %s, error: %s", namespace.Name, roleBinding.Name, err)
+			return err
+		}
+	}
+	if err := r.Create(context.TODO(), roleBinding); err != nil {
+		klog.Errorf("create role binding namespace: %s, role binding: %s, error: %s", namespace.Name, roleBinding.Name, err)
+		return err
+	}
+	return nil
+}
+
+// pruneTeamRoles deletes the Roles and RoleBindings materialized from TeamRoles that are not in desired
+func (r *NamespaceReconcile) pruneTeamRoles(namespace *corev1.Namespace, desired []v1alpha1.TeamRole) error {
+	roleNames := make(map[string]string, len(desired))
+	for _, teamRole := range desired {
+		roleNames[teamRole.Name] = teamRole.GetRoleName()
+	}
+	stale := func(labels map[string]string, name string) bool {
+		teamRole, ok := labels[constants.TeamRoleLabelKey]
+		return ok && roleNames[teamRole] != name
+	}
+
+	roleBindings := &rbac.RoleBindingList{}
+	if err := r.List(context.TODO(), roleBindings, client.InNamespace(namespace.Name)); err != nil {
+		klog.Errorf("list role bindings namespace: %s, error: %s", namespace.Name, err)
+		return err
+	}
+	for i := range roleBindings.Items {
+		found := &roleBindings.Items[i]
+		if !stale(found.Labels, found.Name) {
+			continue
+		}
+		if err := r.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("delete role binding namespace: %s, role binding: %s, error: %s", namespace.Name, found.Name, err)
+			return err
+		}
+	}
+
+	roles := &rbac.RoleList{}
+	if err := r.List(context.TODO(), roles, client.InNamespace(namespace.Name)); err != nil {
+		klog.Errorf("list roles namespace: %s, error: %s", namespace.Name, err)
+		return err
+	}
+	for i := range roles.Items {
+		found := &roles.Items[i]
+		if !stale(found.Labels, found.Name) {
+			continue
+		}
+		if err := r.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("delete role namespace: %s, role: %s, error: %s", namespace.Name, found.Name, err)
+			r.event(namespace, corev1.EventTypeWarning, roleFailedReason, "Failed to delete stale role %s: %s", found.Name, err)
+			return err
+		}
+		r.event(namespace, corev1.EventTypeNormal, roleDeletedReason, "Deleted stale role %s", found.Name)
+	}
+	return nil
+}
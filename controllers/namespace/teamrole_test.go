@@ -0,0 +1,68 @@
+package namespace
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCheckAndCreateTeamRoles(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	teamRole := func(name, team string) *v1alpha1.TeamRole {
+		return &v1alpha1.TeamRole{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID("uid-" + name)},
+			Spec: v1alpha1.TeamRoleSpec{
+				Team:    team,
+				Rules:   []rbac.PolicyRule{{Verbs: []string{"update"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}}},
+				Members: []rbac.Subject{{Kind: rbac.UserKind, Name: "lisi"}},
+			},
+		}
+	}
+	stale := &rbac.Role{ObjectMeta: metav1.ObjectMeta{Name: "oncall", Namespace: "nebula-dev",
+		Labels: map[string]string{constants.ResourceLabel: constants.ResourceRole, constants.TeamRoleLabelKey: "oncall"}}}
+	taken := &rbac.Role{ObjectMeta: metav1.ObjectMeta{Name: "taken", Namespace: "nebula-dev"}}
+	recorder := record.NewFakeRecorder(10)
+	r := &NamespaceReconcile{
+		Client:   fake.NewFakeClientWithScheme(scheme, teamRole("deployer", "nebula"), teamRole("other", "other"), teamRole("taken", "nebula"), stale, taken),
+		Scheme:   scheme,
+		Recorder: recorder,
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-dev", Labels: teamutil.Labels("nebula"),
+		Annotations: map[string]string{constants.TeamAnnotationKey: "nebula"}}}
+
+	if err := r.checkAndCreateTeamRoles(namespace); err != nil {
+		t.Fatal(err)
+	}
+
+	binding := &rbac.RoleBinding{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: "nebula-dev", Name: "deployer"}, binding); err != nil {
+		t.Fatal(err)
+	}
+	if binding.RoleRef.Name != "deployer" || len(binding.Subjects) != 2 || binding.Subjects[0].Name != "lisi" ||
+		binding.Subjects[1].Kind != rbac.GroupKind || binding.Subjects[1].Name != "kubenebula:team:nebula:deployer" {
+		t.Errorf("role binding = %+v", binding)
+	}
+	for name, exists := range map[string]bool{"deployer": true, "other": false, "oncall": false, "taken": true} {
+		err := r.Get(context.TODO(), types.NamespacedName{Namespace: "nebula-dev", Name: name}, &rbac.Role{})
+		if exists != !errors.IsNotFound(err) {
+			t.Errorf("role %s exists = %v, want %v", name, !exists, exists)
+		}
+	}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: "nebula-dev", Name: "taken"}, &rbac.RoleBinding{}); !errors.IsNotFound(err) {
+		t.Errorf("binding created for conflicting role: %v", err)
+	}
+}
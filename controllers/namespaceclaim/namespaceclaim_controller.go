@@ -0,0 +1,208 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package namespaceclaim
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/tenant"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var log = logf.Log.WithName("namespaceclaim-controller")
+
+// NamespaceClaimReconciler creates the namespace requested by a NamespaceClaim once the requester is verified
+// to be an admin or regular member of the team and the team has room for another namespace.
+type NamespaceClaimReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+// rejection is a claim that can never be satisfied, it ends in the Rejected phase
+type rejection struct {
+	reason  string
+	message string
+}
+
+func (e *rejection) Error() string {
+	return e.message
+}
+
+func reject(reason, format string, args ...interface{}) error {
+	return &rejection{reason: reason, message: fmt.Sprintf(format, args...)}
+}
+
+// +kubebuilder:rbac:groups=tenant.kubenebula.io,resources=namespaceclaims,verbs=get;list;watch
+// +kubebuilder:rbac:groups=tenant.kubenebula.io,resources=namespaceclaims/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=tenant.kubenebula.io,resources=teams;quotapresets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;create
+
+func (r *NamespaceClaimReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	instance := &tenantv1alpha1.NamespaceClaim{}
+	err := r.Get(context.TODO(), req.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// The namespace outlives its claim.
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if !instance.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+	// Bound and Rejected are final, a rejected claim is submitted again as a new claim
+	switch instance.Status.Phase {
+	case tenantv1alpha1.NamespaceClaimBound, tenantv1alpha1.NamespaceClaimRejected:
+		return reconcile.Result{}, nil
+	}
+
+	status := instance.Status.DeepCopy()
+	status.ObservedGeneration = instance.Generation
+
+	reconcileErr := r.claimNamespace(instance)
+	switch e := reconcileErr.(type) {
+	case nil:
+		status.Phase = tenantv1alpha1.NamespaceClaimBound
+		status.Reason = "NamespaceCreated"
+		status.Message = fmt.Sprintf("namespace %s created for team %s", instance.Spec.Namespace, instance.Spec.Team)
+	case *rejection:
+		log.Info("Rejecting namespace claim", "namespaceclaim", instance.Name, "reason", e.reason, "message", e.message)
+		status.Phase = tenantv1alpha1.NamespaceClaimRejected
+		status.Reason = e.reason
+		status.Message = e.message
+		reconcileErr = nil
+	default:
+		status.Phase = tenantv1alpha1.NamespaceClaimPending
+		status.Reason = "ReconcileFailed"
+		status.Message = reconcileErr.Error()
+	}
+	if err := r.updateStatus(instance, status); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, reconcileErr
+}
+
+// claimNamespace verifies the claim and creates its namespace. It returns a rejection when the claim can never
+// be satisfied and other errors when it should be retried.
+func (r *NamespaceClaimReconciler) claimNamespace(instance *tenantv1alpha1.NamespaceClaim) error {
+	// the requester annotations are only trustworthy when set by the admission webhooks
+	requester, ok := tenant.ClaimRequester(instance)
+	if !ok {
+		return reject("MissingRequester", "the requester of the claim is unknown")
+	}
+	if errs := tenant.ValidateNamespaceName(instance.Spec.Namespace); len(errs) > 0 {
+		return reject("InvalidName", "invalid namespace name %q: %s", instance.Spec.Namespace, strings.Join(errs, ", "))
+	}
+
+	team := &tenantv1alpha1.Team{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: instance.Spec.Team}, team); err != nil {
+		if errors.IsNotFound(err) {
+			return reject("TeamNotFound", "team %s does not exist", instance.Spec.Team)
+		}
+		return err
+	}
+	if !team.DeletionTimestamp.IsZero() {
+		return reject("TeamDeleting", "team %s is being deleted", instance.Spec.Team)
+	}
+	ancestors, err := teamutil.InheritedAncestors(r, team)
+	if err != nil {
+		return err
+	}
+	members := append(teamutil.InheritedAdmins(team, ancestors), team.Spec.Regulars...)
+	if !teamutil.HasUser(members, requester.Name, requester.Groups) {
+		return reject("Forbidden", "user %s is not an admin or regular member of team %s", requester.Name, team.Name)
+	}
+
+	if instance.Spec.QuotaPreset != "" {
+		preset := &tenantv1alpha1.QuotaPreset{}
+		if err := r.Get(context.TODO(), types.NamespacedName{Name: instance.Spec.QuotaPreset}, preset); err != nil {
+			if errors.IsNotFound(err) {
+				return reject("QuotaPresetNotFound", "quota preset %s does not exist", instance.Spec.QuotaPreset)
+			}
+			return err
+		}
+	}
+
+	found := &corev1.Namespace{}
+	err = r.Get(context.TODO(), types.NamespacedName{Name: instance.Spec.Namespace}, found)
+	if err == nil {
+		// the namespace was created by an earlier reconcile whose status update failed
+		if found.Annotations[constants.NamespaceClaimAnnotationKey] == instance.Name {
+			return nil
+		}
+		return reject("AlreadyExists", "namespace %s already exists", instance.Spec.Namespace)
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
+	if err := tenant.CheckNamespaceLimit(context.TODO(), r, team); err != nil {
+		if errors.IsForbidden(err) {
+			return reject("QuotaExceeded", "%s", err)
+		}
+		return err
+	}
+
+	namespace := tenant.NewNamespace(instance.Spec.Namespace, team.Name, requester.Name)
+	namespace.Annotations[constants.NamespaceClaimAnnotationKey] = instance.Name
+	if instance.Spec.Description != "" {
+		namespace.Annotations[constants.DescriptionAnnotationKey] = instance.Spec.Description
+	}
+	if instance.Spec.QuotaPreset != "" {
+		namespace.Annotations[constants.QuotaPresetAnnotationKey] = instance.Spec.QuotaPreset
+	}
+	log.Info("Creating claimed namespace", "namespaceclaim", instance.Name, "namespace", namespace.Name, "team", team.Name)
+	if err := r.Create(context.TODO(), namespace); err != nil {
+		if errors.IsAlreadyExists(err) {
+			return reject("AlreadyExists", "namespace %s already exists", instance.Spec.Namespace)
+		}
+		if errors.IsForbidden(err) {
+			// denied by an admission webhook such as the team quota
+			return reject("Forbidden", "%s", err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *NamespaceClaimReconciler) updateStatus(instance *tenantv1alpha1.NamespaceClaim, status *tenantv1alpha1.NamespaceClaimStatus) error {
+	if reflect.DeepEqual(&instance.Status, status) {
+		return nil
+	}
+	instance.Status = *status
+	return r.Status().Update(context.TODO(), instance)
+}
+
+func (r *NamespaceClaimReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&tenantv1alpha1.NamespaceClaim{}).
+		Complete(r)
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package namespaceclaim
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/tenant"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newTestReconciler(objects ...runtime.Object) *NamespaceClaimReconciler {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	return &NamespaceClaimReconciler{Client: fake.NewFakeClientWithScheme(scheme, objects...), Scheme: scheme}
+}
+
+func newClaim(namespace, team, username string, groups ...string) *tenantv1alpha1.NamespaceClaim {
+	claim := &tenantv1alpha1.NamespaceClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "claim"},
+		Spec: tenantv1alpha1.NamespaceClaimSpec{
+			Namespace:   namespace,
+			Team:        team,
+			Description: "development",
+		},
+	}
+	tenant.SetClaimRequester(claim, tenant.User{Name: username, Groups: groups})
+	return claim
+}
+
+func TestReconcileNamespaceClaim(t *testing.T) {
+	parent := &tenantv1alpha1.Team{
+		ObjectMeta: metav1.ObjectMeta{Name: "platform"},
+		Spec:       tenantv1alpha1.TeamSpec{Manager: "carol"},
+	}
+	team := &tenantv1alpha1.Team{
+		ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+		Spec: tenantv1alpha1.TeamSpec{
+			Manager:  "alice",
+			Parent:   "platform",
+			Regulars: []rbac.Subject{{Kind: rbac.GroupKind, Name: "nebula-devs"}},
+			Viewers:  []rbac.Subject{{Kind: rbac.UserKind, Name: "victor"}},
+		},
+	}
+	limited := team.DeepCopy()
+	limited.Spec.Quota = &tenantv1alpha1.TeamQuota{Hard: corev1.ResourceList{tenantv1alpha1.ResourceNamespaces: resource.MustParse("1")}}
+	teamNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-prod", Labels: teamutil.Labels("nebula")}}
+	preset := &tenantv1alpha1.QuotaPreset{ObjectMeta: metav1.ObjectMeta{Name: "small"}}
+	withPreset := newClaim("nebula-dev", "nebula", "alice")
+	withPreset.Spec.QuotaPreset = "small"
+	missingPreset := withPreset.DeepCopy()
+	missingPreset.Spec.QuotaPreset = "huge"
+	malformed := newClaim("nebula-dev", "nebula", "alice")
+	malformed.Annotations[constants.RequesterGroupsAnnotationKey] = "nebula-devs"
+
+	tests := []struct {
+		name    string
+		claim   *tenantv1alpha1.NamespaceClaim
+		objects []runtime.Object
+		phase   tenantv1alpha1.NamespaceClaimPhase
+		reason  string
+	}{
+		{"manager", withPreset, []runtime.Object{team, parent, preset}, tenantv1alpha1.NamespaceClaimBound, "NamespaceCreated"},
+		{"regular group", newClaim("nebula-dev", "nebula", "bob", "nebula-devs"), []runtime.Object{team, parent}, tenantv1alpha1.NamespaceClaimBound, "NamespaceCreated"},
+		{"ancestor admin", newClaim("nebula-dev", "nebula", "carol"), []runtime.Object{team, parent}, tenantv1alpha1.NamespaceClaimBound, "NamespaceCreated"},
+		{"viewer", newClaim("nebula-dev", "nebula", "victor"), []runtime.Object{team, parent}, tenantv1alpha1.NamespaceClaimRejected, "Forbidden"},
+		{"missing requester", &tenantv1alpha1.NamespaceClaim{ObjectMeta: metav1.ObjectMeta{Name: "claim"}}, nil, tenantv1alpha1.NamespaceClaimRejected, "MissingRequester"},
+		{"malformed requester groups", malformed, []runtime.Object{team, parent}, tenantv1alpha1.NamespaceClaimRejected, "MissingRequester"},
+		{"invalid name", newClaim("Nebula_Dev", "nebula", "alice"), []runtime.Object{team}, tenantv1alpha1.NamespaceClaimRejected, "InvalidName"},
+		{"reserved name", newClaim("kube-nebula", "nebula", "alice"), []runtime.Object{team}, tenantv1alpha1.NamespaceClaimRejected, "InvalidName"},
+		{"missing team", newClaim("nebula-dev", "nebula", "alice"), nil, tenantv1alpha1.NamespaceClaimRejected, "TeamNotFound"},
+		{"missing preset", missingPreset, []runtime.Object{team, parent}, tenantv1alpha1.NamespaceClaimRejected, "QuotaPresetNotFound"},
+		{"existing namespace", newClaim("nebula-prod", "nebula", "alice"), []runtime.Object{team, parent, teamNamespace}, tenantv1alpha1.NamespaceClaimRejected, "AlreadyExists"},
+		{"namespace limit", newClaim("nebula-dev", "nebula", "alice"), []runtime.Object{limited, parent, teamNamespace}, tenantv1alpha1.NamespaceClaimRejected, "QuotaExceeded"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestReconciler(append(test.objects, test.claim.DeepCopy())...)
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "claim"}}); err != nil {
+				t.Fatal(err)
+			}
+			claim := &tenantv1alpha1.NamespaceClaim{}
+			if err := r.Get(context.TODO(), types.NamespacedName{Name: "claim"}, claim); err != nil {
+				t.Fatal(err)
+			}
+			if claim.Status.Phase != test.phase || claim.Status.Reason != test.reason {
+				t.Errorf("status = %s/%s (%s), expected %s/%s", claim.Status.Phase, claim.Status.Reason, claim.Status.Message, test.phase, test.reason)
+			}
+			if test.phase != tenantv1alpha1.NamespaceClaimBound {
+				return
+			}
+
+			namespace := &corev1.Namespace{}
+			if err := r.Get(context.TODO(), types.NamespacedName{Name: "nebula-dev"}, namespace); err != nil {
+				t.Fatal(err)
+			}
+			if !teamutil.HasLabels(namespace.Labels, "nebula") {
+				t.Errorf("namespace labels = %v, expected the labels of team nebula", namespace.Labels)
+			}
+			expected := map[string]string{
+				constants.TeamAnnotationKey:           "nebula",
+				constants.CreatorAnnotationKey:        test.claim.Annotations[constants.RequesterAnnotationKey],
+				constants.DescriptionAnnotationKey:    "development",
+				constants.NamespaceClaimAnnotationKey: "claim",
+			}
+			if test.claim.Spec.QuotaPreset != "" {
+				expected[constants.QuotaPresetAnnotationKey] = test.claim.Spec.QuotaPreset
+			}
+			for key, value := range expected {
+				if namespace.Annotations[key] != value {
+					t.Errorf("annotation %s = %q, expected %q", key, namespace.Annotations[key], value)
+				}
+			}
+
+			// a bound claim is final, deleting its namespace does not create it again
+			if err := r.Delete(context.TODO(), namespace); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "claim"}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Get(context.TODO(), types.NamespacedName{Name: "nebula-dev"}, namespace); err == nil {
+				t.Error("namespace of a bound claim was created again")
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package team
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReconcileStatus(t *testing.T) {
+	team := &tenantv1alpha1.Team{
+		ObjectMeta: metav1.ObjectMeta{Name: "nebula", UID: "uid-nebula", Generation: 3},
+		Spec: tenantv1alpha1.TeamSpec{
+			Manager:  "lead",
+			Admins:   []rbac.Subject{{Kind: rbac.GroupKind, Name: "operators"}},
+			Regulars: []rbac.Subject{{Kind: rbac.UserKind, Name: "dev"}, {Kind: rbac.GroupKind, Name: "developers"}},
+			Viewers:  []rbac.Subject{{Kind: rbac.UserKind, Name: "auditor"}},
+		},
+	}
+	r := newTestReconciler(team, newTeamNamespace("nebula-test", team), newTeamNamespace("nebula-dev", team))
+
+	if _, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "nebula"}}); err != nil {
+		t.Fatal(err)
+	}
+	instance := &tenantv1alpha1.Team{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "nebula"}, instance); err != nil {
+		t.Fatal(err)
+	}
+
+	status := instance.Status
+	if status.ObservedGeneration != 3 {
+		t.Errorf("observedGeneration = %d, expected 3", status.ObservedGeneration)
+	}
+	for conditionType, reason := range map[tenantv1alpha1.TeamConditionType]string{
+		tenantv1alpha1.TeamParentResolved:  "NoParent",
+		tenantv1alpha1.TeamRolesReady:      "RolesCreated",
+		tenantv1alpha1.TeamBindingsReady:   "BindingsSynced",
+		tenantv1alpha1.TeamNamespacesBound: "NamespacesBound",
+		tenantv1alpha1.TeamReady:           "Reconciled",
+	} {
+		condition := status.GetCondition(conditionType)
+		if condition == nil || condition.Status != corev1.ConditionTrue || condition.Reason != reason {
+			t.Errorf("%s condition = %+v, expected True %s", conditionType, condition, reason)
+		}
+	}
+	if expected := (tenantv1alpha1.TeamMemberCount{Admins: 2, Regulars: 2, Viewers: 1}); status.Members != expected {
+		t.Errorf("members = %+v, expected %+v", status.Members, expected)
+	}
+	if expected := []string{"nebula-dev", "nebula-test"}; !reflect.DeepEqual(status.Namespaces, expected) || status.NamespaceCount != 2 {
+		t.Errorf("namespaces = %v (%d), expected %v (2)", status.Namespaces, status.NamespaceCount, expected)
+	}
+}
+
+func TestReconcileStatusWithoutNamespaces(t *testing.T) {
+	team := &tenantv1alpha1.Team{
+		ObjectMeta: metav1.ObjectMeta{Name: "nebula", UID: "uid-nebula", Generation: 1},
+		Spec:       tenantv1alpha1.TeamSpec{Manager: "lead", Parent: "department"},
+	}
+	r := newTestReconciler(team)
+
+	if _, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "nebula"}}); err != nil {
+		t.Fatal(err)
+	}
+	instance := &tenantv1alpha1.Team{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "nebula"}, instance); err != nil {
+		t.Fatal(err)
+	}
+
+	status := instance.Status
+	if condition := status.GetCondition(tenantv1alpha1.TeamParentResolved); condition == nil || condition.Status != corev1.ConditionFalse || condition.Reason != "ParentNotFound" {
+		t.Errorf("ParentResolved condition = %+v, expected False ParentNotFound", condition)
+	}
+	if condition := status.GetCondition(tenantv1alpha1.TeamNamespacesBound); condition == nil || condition.Reason != "NoNamespaces" {
+		t.Errorf("NamespacesBound condition = %+v, expected NoNamespaces", condition)
+	}
+	if len(status.Namespaces) != 0 || status.NamespaceCount != 0 {
+		t.Errorf("namespaces = %v (%d), expected none", status.Namespaces, status.NamespaceCount)
+	}
+	if expected := (tenantv1alpha1.TeamMemberCount{Admins: 1}); status.Members != expected {
+		t.Errorf("members = %+v, expected %+v", status.Members, expected)
+	}
+}
+
+func TestReconcileStatusInheritedMembers(t *testing.T) {
+	department := &tenantv1alpha1.Team{
+		ObjectMeta: metav1.ObjectMeta{Name: "department", UID: "uid-department"},
+		Spec: tenantv1alpha1.TeamSpec{
+			Manager: "head",
+			Admins:  []rbac.Subject{{Kind: rbac.UserKind, Name: "lead"}},
+			Viewers: []rbac.Subject{{Kind: rbac.UserKind, Name: "auditor"}, {Kind: rbac.GroupKind, Name: "board"}},
+		},
+	}
+	team := &tenantv1alpha1.Team{
+		ObjectMeta: metav1.ObjectMeta{Name: "nebula", UID: "uid-nebula"},
+		Spec: tenantv1alpha1.TeamSpec{
+			Manager: "lead",
+			Parent:  "department",
+			Viewers: []rbac.Subject{{APIGroup: rbac.GroupName, Kind: rbac.UserKind, Name: "auditor"}},
+		},
+	}
+	r := newTestReconciler(department, team)
+
+	if _, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "nebula"}}); err != nil {
+		t.Fatal(err)
+	}
+	instance := &tenantv1alpha1.Team{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "nebula"}, instance); err != nil {
+		t.Fatal(err)
+	}
+	// lead and auditor are both members of the team and of its parent
+	if expected := (tenantv1alpha1.TeamMemberCount{Admins: 2, Viewers: 2}); instance.Status.Members != expected {
+		t.Errorf("members = %+v, expected %+v", instance.Status.Members, expected)
+	}
+}
+
+// countingClient counts the writes made through the status subresource.
+type countingClient struct {
+	client.Client
+	statusUpdates int
+}
+
+func (c *countingClient) Status() client.StatusWriter {
+	return &countingStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+type countingStatusWriter struct {
+	client.StatusWriter
+	client *countingClient
+}
+
+func (w *countingStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	w.client.statusUpdates++
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func TestUpdateStatus(t *testing.T) {
+	team := &tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula", Generation: 2}}
+	r := newTestReconciler(team)
+	c := &countingClient{Client: r.Client}
+	r.Client = c
+
+	status := team.Status.DeepCopy()
+	if err := r.updateStatus(team, status); err != nil || c.statusUpdates != 0 {
+		t.Fatalf("updateStatus() unchanged = %v with %d writes, expected none", err, c.statusUpdates)
+	}
+
+	status.ObservedGeneration = team.Generation
+	status.SetCondition(tenantv1alpha1.TeamReady, corev1.ConditionTrue, "Reconciled", "")
+	if err := r.updateStatus(team, status); err != nil || c.statusUpdates != 1 {
+		t.Fatalf("updateStatus() changed = %v with %d writes, expected one", err, c.statusUpdates)
+	}
+	instance := &tenantv1alpha1.Team{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "nebula"}, instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status.ObservedGeneration != 2 || instance.Status.GetCondition(tenantv1alpha1.TeamReady) == nil {
+		t.Errorf("status = %+v, expected observedGeneration 2 and Ready", instance.Status)
+	}
+}
@@ -23,16 +23,26 @@ import (
 	rbac "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/quotautil"
 	"kubenebula.io/kubenebula/utils/sliceutil"
+	"kubenebula.io/kubenebula/utils/teamutil"
 	"reflect"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -46,8 +56,20 @@ const (
 	teamViewerDescription  = "Allows viewer access to view all resources in the team."
 )
 
+// deletionBlockedRequeuePeriod is how often a team whose deletion is blocked checks its namespaces again
+const deletionBlockedRequeuePeriod = 30 * time.Second
+
 var log = logf.Log.WithName("team-controller")
 
+var driftCorrections = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kubenebula_team_drift_corrections_total",
+	Help: "Number of corrections made to team owned objects that drifted from the desired state.",
+}, []string{"kind", "action"})
+
+func init() {
+	metrics.Registry.MustRegister(driftCorrections)
+}
+
 // TeamReconciler reconciles a Team object
 type TeamReconciler struct {
 	client.Client
@@ -57,6 +79,9 @@ type TeamReconciler struct {
 
 // +kubebuilder:rbac:groups=tenant.kubenebula.io,resources=teams,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=tenant.kubenebula.io,resources=teams/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=tenant.kubenebula.io,resources=teamroletemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods;persistentvolumeclaims;services,verbs=get;list;watch
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings,verbs=get;list;watch;create;update;patch;delete
 
 func (r *TeamReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	//_ = context.Background()
@@ -73,7 +98,7 @@ func (r *TeamReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return reconcile.Result{}, err
 	}
 	// name of your custom finalizer
-	finalizer := "finalizers.tenant.kubenebula.io"
+	finalizer := constants.TeamFinalizer
 	if instance.ObjectMeta.DeletionTimestamp.IsZero() { //被创建
 		// The object is not being deleted, so if it does not have our finalizer,
 		// then lets add the finalizer and update the object.
@@ -87,11 +112,13 @@ func (r *TeamReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		// The object is being deleted
 		if sliceutil.HasString(instance.ObjectMeta.Finalizers, finalizer) {
 			// our finalizer is present, so lets handle our external dependency
-			//删除依赖的资源
-			//if err := r.deleteDevOpsProjects(instance); err != nil {
-			//	return reconcile.Result{}, err
-			//}
-			log.Info("Delete team todo some thing", "team", instance.Name, "name", instance.Name)
+			if blocked, err := r.blockedByChildren(instance); err != nil || blocked {
+				return reconcile.Result{RequeueAfter: deletionBlockedRequeuePeriod}, err
+			}
+			released, err := r.releaseNamespaces(instance)
+			if err != nil || !released {
+				return reconcile.Result{RequeueAfter: deletionBlockedRequeuePeriod}, err
+			}
 			// remove our finalizer from the list and update it.
 			instance.ObjectMeta.Finalizers = sliceutil.RemoveString(instance.ObjectMeta.Finalizers, func(item string) bool {
 				return item == finalizer
@@ -104,316 +131,537 @@ func (r *TeamReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return reconcile.Result{}, nil
 	}
 	//创建相关资源
-	if err = r.createTeamAdmin(instance); err != nil {
+	status := instance.Status.DeepCopy()
+	status.ObservedGeneration = instance.Generation
+
+	ancestors, err := teamutil.Ancestors(r, instance)
+	switch {
+	case err == nil && instance.Spec.Parent == "":
+		status.SetCondition(tenantv1alpha1.TeamParentResolved, corev1.ConditionTrue, "NoParent", "")
+	case err == nil:
+		status.SetCondition(tenantv1alpha1.TeamParentResolved, corev1.ConditionTrue, "ParentResolved", "")
+	case errors.IsNotFound(err):
+		// inherit from the ancestors found so far, the team is requeued when the missing one is created
+		status.SetCondition(tenantv1alpha1.TeamParentResolved, corev1.ConditionFalse, "ParentNotFound", err.Error())
+	case teamutil.IsCycle(err):
+		status.SetCondition(tenantv1alpha1.TeamParentResolved, corev1.ConditionFalse, "ParentCycle", err.Error())
+		ancestors = nil
+	default:
 		return reconcile.Result{}, err
 	}
+	status.Ancestors = nil
+	for _, ancestor := range ancestors {
+		status.Ancestors = append(status.Ancestors, ancestor.Name)
+	}
 
-	if err = r.createTeamRegular(instance); err != nil {
-		return reconcile.Result{}, err
+	var reconcileErr error
+	if err = r.createTeamRoles(instance); err != nil {
+		status.SetCondition(tenantv1alpha1.TeamRolesReady, corev1.ConditionFalse, "RolesFailed", err.Error())
+		reconcileErr = err
+	} else {
+		status.SetCondition(tenantv1alpha1.TeamRolesReady, corev1.ConditionTrue, "RolesCreated", "")
 	}
 
-	if err = r.createTeamViewer(instance); err != nil {
-		return reconcile.Result{}, err
+	if err = r.createTeamRoleBindings(instance, ancestors); err != nil {
+		status.SetCondition(tenantv1alpha1.TeamBindingsReady, corev1.ConditionFalse, "BindingsFailed", err.Error())
+		if reconcileErr == nil {
+			reconcileErr = err
+		}
+	} else {
+		status.SetCondition(tenantv1alpha1.TeamBindingsReady, corev1.ConditionTrue, "BindingsSynced", "")
 	}
+	status.Members = countMembers(instance, ancestors)
 
-	if err = r.createTeamRoleBindings(instance); err != nil {
-		return reconcile.Result{}, err
+	namespaces, err := r.bindNamespaces(instance)
+	if err != nil {
+		status.SetCondition(tenantv1alpha1.TeamNamespacesBound, corev1.ConditionFalse, "BindFailed", err.Error())
+		if reconcileErr == nil {
+			reconcileErr = err
+		}
+	} else {
+		status.Namespaces = namespaces
+		status.NamespaceCount = int32(len(namespaces))
+		if len(namespaces) == 0 {
+			status.SetCondition(tenantv1alpha1.TeamNamespacesBound, corev1.ConditionTrue, "NoNamespaces", "")
+		} else {
+			status.SetCondition(tenantv1alpha1.TeamNamespacesBound, corev1.ConditionTrue, "NamespacesBound", "")
+		}
+
+		if status.Quota, err = r.quotaStatus(instance, namespaces); err != nil && reconcileErr == nil {
+			reconcileErr = err
+		}
+	}
+
+	if reconcileErr != nil {
+		status.SetCondition(tenantv1alpha1.TeamReady, corev1.ConditionFalse, "ReconcileFailed", reconcileErr.Error())
+	} else {
+		status.SetCondition(tenantv1alpha1.TeamReady, corev1.ConditionTrue, "Reconciled", "")
 	}
 
-	if err = r.bindNamespaces(instance); err != nil {
+	if err = r.updateStatus(instance, status); err != nil {
 		return reconcile.Result{}, err
 	}
-	return ctrl.Result{}, nil
+	return ctrl.Result{}, reconcileErr
+}
+
+// updateStatus writes status back through the status subresource if it changed.
+func (r *TeamReconciler) updateStatus(instance *tenantv1alpha1.Team, status *tenantv1alpha1.TeamStatus) error {
+	if reflect.DeepEqual(&instance.Status, status) {
+		return nil
+	}
+	instance.Status = *status
+	return r.Status().Update(context.TODO(), instance)
 }
 
 func (r *TeamReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&tenantv1alpha1.Team{}).
-		Complete(r)
-}
+		Owns(&rbac.ClusterRole{}).
+		Owns(&rbac.ClusterRoleBinding{}).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(namespaceToTeam),
+		}).
+		Watches(&source.Kind{Type: &tenantv1alpha1.Team{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: &hierarchyTeamMapper{Client: mgr.GetClient()},
+		}).
+		Watches(&source.Kind{Type: &tenantv1alpha1.TeamRoleTemplate{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: &templateTeamMapper{Client: mgr.GetClient()},
+		}).
+		Build(r)
+	if err != nil {
+		return err
+	}
 
-func (r *TeamReconciler) createTeamAdmin(instance *tenantv1alpha1.Team) error {
-	found := &rbac.ClusterRole{}
+	// Pods, claims and services are counted by the team quota, but most of their updates do not change the usage
+	mapper := &handler.EnqueueRequestsFromMapFunc{ToRequests: &namespacedTeamMapper{Reader: mgr.GetCache()}}
+	if err := c.Watch(&source.Kind{Type: &corev1.Pod{}}, mapper, podUsageChanged); err != nil {
+		return err
+	}
+	if err := c.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}}, mapper, pvcUsageChanged); err != nil {
+		return err
+	}
+	return c.Watch(&source.Kind{Type: &corev1.Service{}}, mapper, createdOrDeleted)
+}
 
-	admin := getTeamAdmin(instance.Name)
+// quotaStatus sums the usage of the team namespaces for the resources limited by the team quota
+func (r *TeamReconciler) quotaStatus(instance *tenantv1alpha1.Team, namespaces []string) (*tenantv1alpha1.TeamQuotaStatus, error) {
+	if instance.Spec.Quota == nil || len(instance.Spec.Quota.Hard) == 0 {
+		return nil, nil
+	}
+	used, err := quotautil.NamespacesUsage(r, namespaces)
+	if err != nil {
+		return instance.Status.Quota, err
+	}
+	return &tenantv1alpha1.TeamQuotaStatus{
+		Hard: instance.Spec.Quota.Hard.DeepCopy(),
+		Used: quotautil.Mask(used, instance.Spec.Quota.Hard),
+	}, nil
+}
 
-	if err := controllerutil.SetControllerReference(instance, admin, r.Scheme); err != nil {
-		return err
+// namespaceToTeam maps a namespace to the team it is labelled for
+func namespaceToTeam(obj handler.MapObject) []reconcile.Request {
+	teamName := teamutil.TeamName(obj.Meta)
+	if teamName == "" {
+		return nil
 	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: teamName}}}
+}
 
-	err := r.Get(context.TODO(), types.NamespacedName{Name: admin.Name}, found)
+// namespacedTeamMapper maps a namespaced object counted by the team quota to the team of its namespace.
+// The namespace is read from the informer cache, which the namespace watch keeps filled.
+type namespacedTeamMapper struct {
+	client.Reader
+}
 
-	if err != nil && errors.IsNotFound(err) {
-		log.Info("Creating team role", "team", instance.Name, "name", admin.Name)
-		err = r.Create(context.TODO(), admin)
-		if err != nil {
-			return err
+func (m *namespacedTeamMapper) Map(obj handler.MapObject) []reconcile.Request {
+	namespace := &corev1.Namespace{}
+	if err := m.Get(context.TODO(), types.NamespacedName{Name: obj.Meta.GetNamespace()}, namespace); err != nil {
+		if !errors.IsNotFound(err) {
+			log.Error(err, "get namespace failed", "namespace", obj.Meta.GetNamespace())
 		}
-		found = admin
-	} else if err != nil {
-		// Error reading the object - requeue the request.
-		return err
+		return nil
 	}
+	return namespaceToTeam(handler.MapObject{Meta: namespace, Object: namespace})
+}
 
-	// Update the found object and write the result back if there are any changes
-	if !reflect.DeepEqual(admin.Rules, found.Rules) || !reflect.DeepEqual(admin.Labels, found.Labels) || !reflect.DeepEqual(admin.Annotations, found.Annotations) {
-		found.Rules = admin.Rules
-		found.Labels = admin.Labels
-		found.Annotations = admin.Annotations
-		log.Info("Updating team role", "team", instance.Name, "name", admin.Name)
-		err = r.Update(context.TODO(), found)
-		if err != nil {
-			return err
+// podUsageChanged drops the pod updates which do not change the pod usage, such as most status updates
+var podUsageChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldPod, newPod := e.ObjectOld.(*corev1.Pod), e.ObjectNew.(*corev1.Pod)
+		return !equalUsage(quotautil.PodUsage(oldPod), quotautil.PodUsage(newPod))
+	},
+}
+
+// pvcUsageChanged drops the claim updates which do not change the storage request
+var pvcUsageChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldPVC, newPVC := e.ObjectOld.(*corev1.PersistentVolumeClaim), e.ObjectNew.(*corev1.PersistentVolumeClaim)
+		return !equalUsage(quotautil.PersistentVolumeClaimUsage(oldPVC), quotautil.PersistentVolumeClaimUsage(newPVC))
+	},
+}
+
+// createdOrDeleted drops all updates, for objects whose usage is only their number
+var createdOrDeleted = predicate.Funcs{UpdateFunc: func(event.UpdateEvent) bool { return false }}
+
+// equalUsage reports whether a and b hold the same quantities of the same resources
+func equalUsage(a, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, quantity := range a {
+		other, ok := b[name]
+		if !ok || quantity.Cmp(other) != 0 {
+			return false
 		}
 	}
-	return nil
+	return true
 }
 
-func (r *TeamReconciler) createTeamRegular(instance *tenantv1alpha1.Team) error {
-	found := &rbac.ClusterRole{}
+// hierarchyTeamMapper maps a team to its descendants, which inherit its members, and to its parent,
+// whose deletion may be blocked by it
+type hierarchyTeamMapper struct {
+	client.Client
+}
 
-	regular := getTeamRegular(instance.Name)
+func (m *hierarchyTeamMapper) Map(obj handler.MapObject) []reconcile.Request {
+	teams := &tenantv1alpha1.TeamList{}
+	if err := m.List(context.TODO(), teams); err != nil {
+		log.Error(err, "list teams failed", "team", obj.Meta.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, name := range teamutil.Descendants(teams.Items, obj.Meta.GetName()) {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+	}
+	if parent := obj.Object.(*tenantv1alpha1.Team).Spec.Parent; parent != "" {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: parent}})
+	}
+	return requests
+}
 
-	if err := controllerutil.SetControllerReference(instance, regular, r.Scheme); err != nil {
-		return err
+// templateTeamMapper maps a TeamRoleTemplate to every team, as all teams render their roles from it
+type templateTeamMapper struct {
+	client.Client
+}
+
+func (m *templateTeamMapper) Map(obj handler.MapObject) []reconcile.Request {
+	teamList := &tenantv1alpha1.TeamList{}
+	if err := m.List(context.TODO(), teamList); err != nil {
+		log.Error(err, "Unable to list teams for template", "template", obj.Meta.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(teamList.Items))
+	for _, team := range teamList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: team.Name}})
 	}
+	return requests
+}
 
-	err := r.Get(context.TODO(), types.NamespacedName{Name: regular.Name}, found)
+// recordDrift logs and counts a correction of an object the team owns.
+// Changes made while the team itself has not been reconciled yet are not drift.
+func (r *TeamReconciler) recordDrift(instance *tenantv1alpha1.Team, kind, name, action string) {
+	if instance.Status.ObservedGeneration != instance.Generation {
+		return
+	}
+	log.Info("Correcting drift", "team", instance.Name, "kind", kind, "name", name, "action", action)
+	driftCorrections.WithLabelValues(kind, action).Inc()
+}
 
-	if err != nil && errors.IsNotFound(err) {
+// teamRoles are the built-in team roles by the name of the TeamRoleTemplate that replaces them
+var teamRoles = []struct {
+	template string
+	builtin  func(teamName string) *rbac.ClusterRole
+}{
+	{tenantv1alpha1.TeamAdminTemplate, getTeamAdmin},
+	{tenantv1alpha1.TeamRegularTemplate, getTeamRegular},
+	{tenantv1alpha1.TeamViewerTemplate, getTeamViewer},
+}
 
-		log.Info("Creating team role", "team", instance.Name, "name", regular.Name)
-		err = r.Create(context.TODO(), regular)
-		// Error reading the object - requeue the request.
+func (r *TeamReconciler) createTeamRoles(instance *tenantv1alpha1.Team) error {
+	for _, teamRole := range teamRoles {
+		role, err := r.renderTeamRole(instance.Name, teamRole.template, teamRole.builtin)
 		if err != nil {
 			return err
 		}
-		found = regular
-	} else if err != nil {
-		// Error reading the object - requeue the request.
-		return err
-	}
-
-	// Update the found object and write the result back if there are any changes
-	if !reflect.DeepEqual(regular.Rules, found.Rules) || !reflect.DeepEqual(regular.Labels, found.Labels) || !reflect.DeepEqual(regular.Annotations, found.Annotations) {
-		found.Rules = regular.Rules
-		found.Labels = regular.Labels
-		found.Annotations = regular.Annotations
-		log.Info("Updating team role", "team", instance.Name, "name", regular.Name)
-		err = r.Update(context.TODO(), found)
-		if err != nil {
+		if err := r.createTeamRole(instance, role); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (r *TeamReconciler) createTeamViewer(instance *tenantv1alpha1.Team) error {
-	found := &rbac.ClusterRole{}
+// renderTeamRole renders the team ClusterRole from the TeamRoleTemplate with the given name,
+// the built-in role is used as is when there is no such template
+func (r *TeamReconciler) renderTeamRole(teamName, templateName string, builtin func(string) *rbac.ClusterRole) (*rbac.ClusterRole, error) {
+	role := builtin(teamName)
+	template := &tenantv1alpha1.TeamRoleTemplate{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: templateName}, template); err != nil {
+		if errors.IsNotFound(err) {
+			return role, nil
+		}
+		return nil, err
+	}
+	role.Rules = template.RenderRules(teamName)
+	if template.Spec.DisplayName != "" {
+		role.Annotations[constants.DisplayNameAnnotationKey] = template.Spec.DisplayName
+	}
+	if template.Spec.Description != "" {
+		role.Annotations[constants.DescriptionAnnotationKey] = template.Spec.Description
+	}
+	return role, nil
+}
 
-	viewer := getTeamViewer(instance.Name)
+func (r *TeamReconciler) createTeamRole(instance *tenantv1alpha1.Team, role *rbac.ClusterRole) error {
+	found := &rbac.ClusterRole{}
 
-	if err := controllerutil.SetControllerReference(instance, viewer, r.Scheme); err != nil {
+	if err := controllerutil.SetControllerReference(instance, role, r.Scheme); err != nil {
 		return err
 	}
 
-	err := r.Get(context.TODO(), types.NamespacedName{Name: viewer.Name}, found)
+	err := r.Get(context.TODO(), types.NamespacedName{Name: role.Name}, found)
 
 	if err != nil && errors.IsNotFound(err) {
-		log.Info("Creating team role", "team", instance.Name, "name", viewer.Name)
-		err = r.Create(context.TODO(), viewer)
-		// Error reading the object - requeue the request.
+		r.recordDrift(instance, "ClusterRole", role.Name, "recreate")
+		log.Info("Creating team role", "team", instance.Name, "name", role.Name)
+		err = r.Create(context.TODO(), role)
 		if err != nil {
 			return err
 		}
-		found = viewer
+		found = role
 	} else if err != nil {
 		// Error reading the object - requeue the request.
 		return err
 	}
 
 	// Update the found object and write the result back if there are any changes
-	if !reflect.DeepEqual(viewer.Rules, found.Rules) || !reflect.DeepEqual(viewer.Labels, found.Labels) || !reflect.DeepEqual(viewer.Annotations, found.Annotations) {
-		found.Rules = viewer.Rules
-		found.Labels = viewer.Labels
-		found.Annotations = viewer.Annotations
-		log.Info("Updating team role", "team", instance.Name, "name", viewer.Name)
+	if !reflect.DeepEqual(role.Rules, found.Rules) || !reflect.DeepEqual(role.Labels, found.Labels) || !reflect.DeepEqual(role.Annotations, found.Annotations) {
+		r.recordDrift(instance, "ClusterRole", role.Name, "update")
+		found.Rules = role.Rules
+		found.Labels = role.Labels
+		found.Annotations = role.Annotations
+		log.Info("Updating team role", "team", instance.Name, "name", role.Name)
 		err = r.Update(context.TODO(), found)
 		if err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
-func (r *TeamReconciler) createTeamRoleBindings(instance *tenantv1alpha1.Team) error {
-	adminRoleBinding := &rbac.ClusterRoleBinding{}
-	adminRoleBinding.Name = getTeamAdminRoleBindingName(instance.Name)
-	adminRoleBinding.Labels = map[string]string{constants.TeamLabelKey: instance.Name}
-	adminRoleBinding.RoleRef = rbac.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: getTeamAdminRoleName(instance.Name)}
-
-	teamManager := rbac.Subject{APIGroup: "rbac.authorization.k8s.io", Kind: "User", Name: instance.Spec.Manager}
-
-	if teamManager.Name != "" {
-		adminRoleBinding.Subjects = []rbac.Subject{teamManager}
-	} else {
-		adminRoleBinding.Subjects = []rbac.Subject{}
-	}
-
-	if err := controllerutil.SetControllerReference(instance, adminRoleBinding, r.Scheme); err != nil {
+// createTeamRoleBindings binds the members of the team and its team groups, the admins and viewers of the ancestors hold the same roles
+func (r *TeamReconciler) createTeamRoleBindings(instance *tenantv1alpha1.Team, ancestors []tenantv1alpha1.Team) error {
+	admins := teamutil.WithGroup(teamutil.InheritedAdmins(instance, ancestors), instance.Name, tenantv1alpha1.TeamAdminTemplate)
+	if err := r.createTeamRoleBinding(instance, getTeamAdminRoleBindingName(instance.Name), getTeamAdminRoleName(instance.Name), admins); err != nil {
 		return err
 	}
 
-	foundRoleBinding := &rbac.ClusterRoleBinding{}
-
-	err := r.Get(context.TODO(), types.NamespacedName{Name: adminRoleBinding.Name}, foundRoleBinding)
-
-	if err != nil && errors.IsNotFound(err) {
-		log.Info("Creating team role binding", "team", instance.Name, "name", adminRoleBinding.Name)
-		err = r.Create(context.TODO(), adminRoleBinding)
-		// Error reading the object - requeue the request.
-		if err != nil {
-			return err
-		}
-		foundRoleBinding = adminRoleBinding
-	} else if err != nil {
-		// Error reading the object - requeue the request.
+	regulars := teamutil.WithGroup(instance.Spec.Regulars, instance.Name, tenantv1alpha1.TeamRegularTemplate)
+	if err := r.createTeamRoleBinding(instance, getTeamRegularRoleBindingName(instance.Name), getTeamRegularRoleName(instance.Name), regulars); err != nil {
 		return err
 	}
 
-	// Update the found object and write the result back if there are any changes
-	if !reflect.DeepEqual(adminRoleBinding.RoleRef, foundRoleBinding.RoleRef) {
-		log.Info("Deleting conflict team role binding", "team", instance.Name, "name", adminRoleBinding.Name)
-		err = r.Delete(context.TODO(), foundRoleBinding)
-		if err != nil {
-			return err
-		}
-		return fmt.Errorf("conflict team role binding %s, waiting for recreate", foundRoleBinding.Name)
+	viewers := teamutil.WithGroup(teamutil.InheritedViewers(instance, ancestors), instance.Name, tenantv1alpha1.TeamViewerTemplate)
+	if err := r.createTeamRoleBinding(instance, getTeamViewerRoleBindingName(instance.Name), getTeamViewerRoleName(instance.Name), viewers); err != nil {
+		return err
 	}
 
-	if teamManager.Name != "" && !hasSubject(foundRoleBinding.Subjects, teamManager) {
-		foundRoleBinding.Subjects = append(foundRoleBinding.Subjects, teamManager)
-		log.Info("Updating team role binding", "team", instance.Name, "name", adminRoleBinding.Name)
-		err = r.Update(context.TODO(), foundRoleBinding)
-		if err != nil {
-			return err
-		}
-	}
+	return nil
+}
 
-	regularRoleBinding := &rbac.ClusterRoleBinding{}
-	regularRoleBinding.Name = getTeamRegularRoleBindingName(instance.Name)
-	regularRoleBinding.Labels = map[string]string{constants.TeamLabelKey: instance.Name}
-	regularRoleBinding.RoleRef = rbac.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: getTeamRegularRoleName(instance.Name)}
-	regularRoleBinding.Subjects = []rbac.Subject{}
+// createTeamRoleBinding makes the subjects of the named ClusterRoleBinding match members exactly.
+func (r *TeamReconciler) createTeamRoleBinding(instance *tenantv1alpha1.Team, name string, roleName string, members []rbac.Subject) error {
+	roleBinding := &rbac.ClusterRoleBinding{}
+	roleBinding.Name = name
+	roleBinding.Labels = teamutil.Labels(instance.Name)
+	roleBinding.RoleRef = rbac.RoleRef{APIGroup: rbac.GroupName, Kind: "ClusterRole", Name: roleName}
+	roleBinding.Subjects = teamutil.Subjects(members)
 
-	if err = controllerutil.SetControllerReference(instance, regularRoleBinding, r.Scheme); err != nil {
+	if err := controllerutil.SetControllerReference(instance, roleBinding, r.Scheme); err != nil {
 		return err
 	}
 
-	err = r.Get(context.TODO(), types.NamespacedName{Name: regularRoleBinding.Name}, foundRoleBinding)
+	found := &rbac.ClusterRoleBinding{}
+
+	err := r.Get(context.TODO(), types.NamespacedName{Name: roleBinding.Name}, found)
 
 	if err != nil && errors.IsNotFound(err) {
-		log.Info("Creating team role binding", "team", instance.Name, "name", regularRoleBinding.Name)
-		err = r.Create(context.TODO(), regularRoleBinding)
+		r.recordDrift(instance, "ClusterRoleBinding", roleBinding.Name, "recreate")
+		log.Info("Creating team role binding", "team", instance.Name, "name", roleBinding.Name)
+		err = r.Create(context.TODO(), roleBinding)
 		// Error reading the object - requeue the request.
 		if err != nil {
 			return err
 		}
-		foundRoleBinding = regularRoleBinding
+		found = roleBinding
 	} else if err != nil {
 		// Error reading the object - requeue the request.
 		return err
 	}
 
 	// Update the found object and write the result back if there are any changes
-	if !reflect.DeepEqual(regularRoleBinding.RoleRef, foundRoleBinding.RoleRef) {
-		log.Info("Deleting conflict team role binding", "team", instance.Name, "name", regularRoleBinding.Name)
-		err = r.Delete(context.TODO(), foundRoleBinding)
+	if !reflect.DeepEqual(roleBinding.RoleRef, found.RoleRef) {
+		r.recordDrift(instance, "ClusterRoleBinding", roleBinding.Name, "delete")
+		log.Info("Deleting conflict team role binding", "team", instance.Name, "name", roleBinding.Name)
+		err = r.Delete(context.TODO(), found)
 		if err != nil {
 			return err
 		}
-		return fmt.Errorf("conflict team role binding %s, waiting for recreate", foundRoleBinding.Name)
-	}
-
-	viewerRoleBinding := &rbac.ClusterRoleBinding{}
-	viewerRoleBinding.Name = getTeamViewerRoleBindingName(instance.Name)
-	viewerRoleBinding.Labels = map[string]string{constants.TeamLabelKey: instance.Name}
-	viewerRoleBinding.RoleRef = rbac.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: getTeamViewerRoleName(instance.Name)}
-	viewerRoleBinding.Subjects = []rbac.Subject{}
-
-	if err = controllerutil.SetControllerReference(instance, viewerRoleBinding, r.Scheme); err != nil {
-		return err
+		return fmt.Errorf("conflict team role binding %s, waiting for recreate", found.Name)
 	}
 
-	err = r.Get(context.TODO(), types.NamespacedName{Name: viewerRoleBinding.Name}, foundRoleBinding)
-
-	if err != nil && errors.IsNotFound(err) {
-		log.Info("Creating team role binding", "team", instance.Name, "name", viewerRoleBinding.Name)
-		err = r.Create(context.TODO(), viewerRoleBinding)
-		// Error reading the object - requeue the request.
-		if err != nil {
-			return err
-		}
-		foundRoleBinding = viewerRoleBinding
-	} else if err != nil {
-		// Error reading the object - requeue the request.
-		return err
-	}
-
-	// Update the found object and write the result back if there are any changes
-	if !reflect.DeepEqual(viewerRoleBinding.RoleRef, foundRoleBinding.RoleRef) {
-		log.Info("Deleting conflict team role binding", "team", instance.Name, "name", viewerRoleBinding.Name)
-		err = r.Delete(context.TODO(), foundRoleBinding)
+	if !teamutil.EqualSubjects(roleBinding.Subjects, found.Subjects) || !reflect.DeepEqual(roleBinding.Labels, found.Labels) {
+		r.recordDrift(instance, "ClusterRoleBinding", roleBinding.Name, "update")
+		found.Subjects = roleBinding.Subjects
+		found.Labels = roleBinding.Labels
+		log.Info("Updating team role binding", "team", instance.Name, "name", roleBinding.Name)
+		err = r.Update(context.TODO(), found)
 		if err != nil {
 			return err
 		}
-		return fmt.Errorf("conflict team role binding %s, waiting for recreate", foundRoleBinding.Name)
 	}
 
 	return nil
 }
 
-func (r *TeamReconciler) bindNamespaces(instance *tenantv1alpha1.Team) error {
+// bindNamespaces binds every namespace labelled for the team and returns their names.
+func (r *TeamReconciler) bindNamespaces(instance *tenantv1alpha1.Team) ([]string, error) {
 
 	nsList := &corev1.NamespaceList{}
-	options := client.ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{constants.TeamLabelKey: instance.Name})}
+	options := client.ListOptions{LabelSelector: teamutil.Selector(instance.Name)}
 	err := r.List(context.TODO(), nsList, &options)
 
 	if err != nil {
 		log.Error(err, fmt.Sprintf("list team %s namespace failed", instance.Name))
-		return err
+		return nil, err
 	}
 
+	cascade := instance.GetDeletionPolicy() == tenantv1alpha1.CascadeDeletionPolicy
+	namespaces := make([]string, 0, len(nsList.Items))
 	for _, namespace := range nsList.Items {
-		if !metav1.IsControlledBy(&namespace, instance) {
+		// only cascading teams own their namespaces, otherwise garbage collection would delete them with the team
+		if cascade && !metav1.IsControlledBy(&namespace, instance) {
+			if sliceutil.HasString(instance.Status.Namespaces, namespace.Name) {
+				r.recordDrift(instance, "Namespace", namespace.Name, "update")
+			}
 			if err := controllerutil.SetControllerReference(instance, &namespace, r.Scheme); err != nil {
-				return err
+				return nil, err
 			}
 			log.Info("Bind team", "namespace", namespace.Name, "team", instance.Name)
 			err = r.Update(context.TODO(), &namespace)
 			if err != nil {
-				return err
+				return nil, err
+			}
+		} else if !cascade && removeOwnerReference(&namespace, instance) {
+			if sliceutil.HasString(instance.Status.Namespaces, namespace.Name) {
+				r.recordDrift(instance, "Namespace", namespace.Name, "update")
+			}
+			log.Info("Unbind team owner reference", "namespace", namespace.Name, "team", instance.Name)
+			err = r.Update(context.TODO(), &namespace)
+			if err != nil {
+				return nil, err
 			}
 		}
+		namespaces = append(namespaces, namespace.Name)
 	}
+	sort.Strings(namespaces)
 
-	return nil
+	return namespaces, nil
 }
 
-func hasSubject(subjects []rbac.Subject, user rbac.Subject) bool {
-	for _, subject := range subjects {
-		if reflect.DeepEqual(subject, user) {
-			return true
+// blockedByChildren reports whether a team being deleted still has child teams, and records it in the status.
+// The validating webhook rejects deleting such teams, this only blocks teams deleted without it.
+func (r *TeamReconciler) blockedByChildren(instance *tenantv1alpha1.Team) (bool, error) {
+	teams := &tenantv1alpha1.TeamList{}
+	if err := r.List(context.TODO(), teams); err != nil {
+		return false, err
+	}
+	var children []string
+	for _, team := range teams.Items {
+		if team.Spec.Parent == instance.Name && team.Name != instance.Name {
+			children = append(children, team.Name)
 		}
 	}
-	return false
+	if len(children) == 0 {
+		return false, nil
+	}
+	sort.Strings(children)
+	log.Info("Team deletion blocked by child teams", "team", instance.Name, "children", children)
+	status := instance.Status.DeepCopy()
+	status.SetCondition(tenantv1alpha1.TeamReady, corev1.ConditionFalse, "DeletionBlocked",
+		fmt.Sprintf("the team still has child teams: %s", strings.Join(children, ", ")))
+	return true, r.updateStatus(instance, status)
+}
+
+// releaseNamespaces applies the deletion policy of a team being deleted to its namespaces.
+// It returns false while the deletion is blocked, which only happens to teams deleted without the validating
+// webhook, it rejects deleting a team with the Block deletion policy that still has namespaces.
+func (r *TeamReconciler) releaseNamespaces(instance *tenantv1alpha1.Team) (bool, error) {
+	nsList := &corev1.NamespaceList{}
+	options := client.ListOptions{LabelSelector: teamutil.Selector(instance.Name)}
+	if err := r.List(context.TODO(), nsList, &options); err != nil {
+		return false, err
+	}
+
+	switch instance.GetDeletionPolicy() {
+	case tenantv1alpha1.CascadeDeletionPolicy:
+		// namespaces are controlled by the team and garbage collected with it
+		log.Info("Deleting team with its namespaces", "team", instance.Name, "namespaces", len(nsList.Items))
+	case tenantv1alpha1.BlockDeletionPolicy:
+		if len(nsList.Items) > 0 {
+			namespaces := make([]string, 0, len(nsList.Items))
+			for _, namespace := range nsList.Items {
+				namespaces = append(namespaces, namespace.Name)
+			}
+			sort.Strings(namespaces)
+			log.Info("Team deletion blocked by namespaces", "team", instance.Name, "namespaces", namespaces)
+			status := instance.Status.DeepCopy()
+			status.Namespaces = namespaces
+			status.NamespaceCount = int32(len(namespaces))
+			status.SetCondition(tenantv1alpha1.TeamReady, corev1.ConditionFalse, "DeletionBlocked",
+				fmt.Sprintf("deletion policy is Block and the team still has namespaces: %s", strings.Join(namespaces, ", ")))
+			return false, r.updateStatus(instance, status)
+		}
+	default:
+		for _, namespace := range nsList.Items {
+			removeOwnerReference(&namespace, instance)
+			namespace.Labels = teamutil.SetLabels(namespace.Labels, "")
+			delete(namespace.Annotations, constants.TeamAnnotationKey)
+			log.Info("Orphan team namespace", "namespace", namespace.Name, "team", instance.Name)
+			if err := r.Update(context.TODO(), &namespace); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// removeOwnerReference removes the owner references to the team and reports whether there were any
+func removeOwnerReference(namespace *corev1.Namespace, instance *tenantv1alpha1.Team) bool {
+	references := namespace.OwnerReferences[:0]
+	for _, reference := range namespace.OwnerReferences {
+		if reference.UID != instance.UID {
+			references = append(references, reference)
+		}
+	}
+	removed := len(references) != len(namespace.OwnerReferences)
+	namespace.OwnerReferences = references
+	return removed
+}
+
+// countMembers counts the distinct subjects holding each team role, including the admins and viewers
+// inherited from the ancestors
+func countMembers(instance *tenantv1alpha1.Team, ancestors []tenantv1alpha1.Team) tenantv1alpha1.TeamMemberCount {
+	return tenantv1alpha1.TeamMemberCount{
+		Admins:   int32(len(teamutil.Subjects(teamutil.InheritedAdmins(instance, ancestors)))),
+		Regulars: int32(len(teamutil.Subjects(instance.Spec.Regulars))),
+		Viewers:  int32(len(teamutil.Subjects(teamutil.InheritedViewers(instance, ancestors)))),
+	}
 }
 
 func getTeamAdmin(teamName string) *rbac.ClusterRole {
 	admin := &rbac.ClusterRole{}
 	admin.Name = getTeamAdminRoleName(teamName)
-	admin.Labels = map[string]string{constants.TeamLabelKey: teamName}
+	admin.Labels = teamutil.Labels(teamName)
 	admin.Annotations = map[string]string{constants.DisplayNameAnnotationKey: constants.TeamAdmin, constants.DescriptionAnnotationKey: teamAdminDescription, constants.CreatorAnnotationKey: constants.System}
 	admin.Rules = []rbac.PolicyRule{
 		{
@@ -421,13 +669,16 @@ func getTeamAdmin(teamName string) *rbac.ClusterRole {
 			APIGroups:     []string{"*"},
 			ResourceNames: []string{teamName},
 			Resources:     []string{"teams", "teams/*"},
+		}, {
+			Verbs:     []string{"create", "get", "list", "watch"},
+			APIGroups: []string{"tenant.kubenebula.io"},
+			Resources: []string{"namespaceclaims"},
+		}, {
+			Verbs:     []string{"get", "list", "watch"},
+			APIGroups: []string{"tenant.kubenebula.io"},
+			Resources: []string{"users"},
 		},
 		//{
-		//	Verbs:     []string{"list"},
-		//	APIGroups: []string{"iam.kubesphere.io"},
-		//	Resources: []string{"users"},
-		//},
-		//{
 		//	Verbs:     []string{"*"},
 		//	APIGroups: []string{"openpitrix.io"},
 		//	Resources: []string{"applications", "apps", "apps/versions", "apps/events", "apps/action", "apps/audits", "repos", "repos/action", "categories", "attachments"},
@@ -439,7 +690,7 @@ func getTeamAdmin(teamName string) *rbac.ClusterRole {
 func getTeamRegular(teamName string) *rbac.ClusterRole {
 	regular := &rbac.ClusterRole{}
 	regular.Name = getTeamRegularRoleName(teamName)
-	regular.Labels = map[string]string{constants.TeamLabelKey: teamName}
+	regular.Labels = teamutil.Labels(teamName)
 	regular.Annotations = map[string]string{constants.DisplayNameAnnotationKey: constants.TeamRegular, constants.DescriptionAnnotationKey: teamRegularDescription, constants.CreatorAnnotationKey: constants.System}
 	regular.Rules = []rbac.PolicyRule{
 		{
@@ -448,10 +699,14 @@ func getTeamRegular(teamName string) *rbac.ClusterRole {
 			Resources:     []string{"teams"},
 			ResourceNames: []string{teamName},
 		}, {
-			Verbs:         []string{"create"},
-			APIGroups:     []string{"tenant.kubenebula.io"},
+			Verbs:         []string{"get", "create"},
+			APIGroups:     []string{"tenant.kubenebula.io", "api.kubenebula.io"},
 			Resources:     []string{"teams/namespaces"},
 			ResourceNames: []string{teamName},
+		}, {
+			Verbs:     []string{"create", "get", "list", "watch"},
+			APIGroups: []string{"tenant.kubenebula.io"},
+			Resources: []string{"namespaceclaims"},
 		},
 		//{
 		//	Verbs:         []string{"get"},
@@ -477,7 +732,7 @@ func getTeamRegular(teamName string) *rbac.ClusterRole {
 func getTeamViewer(teamName string) *rbac.ClusterRole {
 	viewer := &rbac.ClusterRole{}
 	viewer.Name = getTeamViewerRoleName(teamName)
-	viewer.Labels = map[string]string{constants.TeamLabelKey: teamName}
+	viewer.Labels = teamutil.Labels(teamName)
 	viewer.Annotations = map[string]string{constants.DisplayNameAnnotationKey: constants.TeamViewer, constants.DescriptionAnnotationKey: teamViewerDescription, constants.CreatorAnnotationKey: constants.System}
 	viewer.Rules = []rbac.PolicyRule{
 		{
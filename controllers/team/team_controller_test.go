@@ -0,0 +1,313 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package team
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+func newTestReconciler(objects ...runtime.Object) *TeamReconciler {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	return &TeamReconciler{Client: fake.NewFakeClientWithScheme(scheme, objects...), Scheme: scheme}
+}
+
+func newTeamNamespace(name string, team *tenantv1alpha1.Team) *corev1.Namespace {
+	controller := true
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        name,
+		Labels:      teamutil.Labels(team.Name),
+		Annotations: map[string]string{constants.TeamAnnotationKey: team.Name},
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: tenantv1alpha1.GroupVersion.String(),
+			Kind:       "Team",
+			Name:       team.Name,
+			UID:        team.UID,
+			Controller: &controller,
+		}},
+	}}
+}
+
+func TestReleaseNamespacesOrphan(t *testing.T) {
+	team := &tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula", UID: "uid-nebula"}}
+	r := newTestReconciler(team, newTeamNamespace("nebula-test", team))
+
+	released, err := r.releaseNamespaces(team)
+	if err != nil || !released {
+		t.Fatalf("releaseNamespaces() = %v, %v", released, err)
+	}
+
+	namespace := &corev1.Namespace{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "nebula-test"}, namespace); err != nil {
+		t.Fatal(err)
+	}
+	if len(namespace.OwnerReferences) != 0 || len(namespace.Labels) != 0 || teamutil.TeamName(namespace) != "" {
+		t.Errorf("namespace still bound to team: %+v", namespace.ObjectMeta)
+	}
+}
+
+func TestReleaseNamespacesBlock(t *testing.T) {
+	team := &tenantv1alpha1.Team{
+		ObjectMeta: metav1.ObjectMeta{Name: "nebula", UID: "uid-nebula"},
+		Spec:       tenantv1alpha1.TeamSpec{DeletionPolicy: tenantv1alpha1.BlockDeletionPolicy},
+	}
+	r := newTestReconciler(team, newTeamNamespace("nebula-test", team))
+
+	released, err := r.releaseNamespaces(team)
+	if err != nil || released {
+		t.Fatalf("releaseNamespaces() = %v, %v", released, err)
+	}
+	ready := team.Status.GetCondition(tenantv1alpha1.TeamReady)
+	if ready == nil || ready.Reason != "DeletionBlocked" || team.Status.NamespaceCount != 1 {
+		t.Errorf("deletion block not reported: %+v", team.Status)
+	}
+
+	if err := r.Delete(context.TODO(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-test"}}); err != nil {
+		t.Fatal(err)
+	}
+	if released, err = r.releaseNamespaces(team); err != nil || !released {
+		t.Errorf("releaseNamespaces() without namespaces = %v, %v", released, err)
+	}
+}
+
+func TestCreateTeamRolesCorrectsDrift(t *testing.T) {
+	team := &tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula", UID: "uid-nebula", Generation: 1}}
+	team.Status.ObservedGeneration = 1
+	tampered := getTeamViewer(team.Name)
+	tampered.Rules = nil
+	r := newTestReconciler(team, tampered)
+
+	before := testutil.ToFloat64(driftCorrections.WithLabelValues("ClusterRole", "update"))
+	if err := r.createTeamRoles(team); err != nil {
+		t.Fatal(err)
+	}
+
+	role := &rbac.ClusterRole{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: tampered.Name}, role); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(role.Rules, getTeamViewer(team.Name).Rules) {
+		t.Errorf("rules not restored: %+v", role.Rules)
+	}
+	if got := testutil.ToFloat64(driftCorrections.WithLabelValues("ClusterRole", "update")) - before; got != 1 {
+		t.Errorf("drift corrections = %v, want 1", got)
+	}
+}
+
+func TestRenderTeamRole(t *testing.T) {
+	template := &tenantv1alpha1.TeamRoleTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: tenantv1alpha1.TeamViewerTemplate},
+		Spec: tenantv1alpha1.TeamRoleTemplateSpec{
+			DisplayName: "观察员",
+			Rules: []rbac.PolicyRule{{
+				Verbs:         []string{"get"},
+				APIGroups:     []string{"tenant.kubenebula.io"},
+				Resources:     []string{"teams"},
+				ResourceNames: []string{tenantv1alpha1.TeamNamePlaceholder},
+			}},
+		},
+	}
+	r := newTestReconciler(template)
+
+	role, err := r.renderTeamRole("nebula", tenantv1alpha1.TeamViewerTemplate, getTeamViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(role.Rules) != 1 || !reflect.DeepEqual(role.Rules[0].ResourceNames, []string{"nebula"}) {
+		t.Errorf("rules = %+v, want rendered template rules", role.Rules)
+	}
+	if role.Annotations[constants.DisplayNameAnnotationKey] != "观察员" || role.Annotations[constants.DescriptionAnnotationKey] != teamViewerDescription {
+		t.Errorf("annotations = %v", role.Annotations)
+	}
+
+	role, err = r.renderTeamRole("nebula", tenantv1alpha1.TeamAdminTemplate, getTeamAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(role, getTeamAdmin("nebula")) {
+		t.Errorf("role without template = %+v, want built-in role", role)
+	}
+}
+
+func TestQuotaStatus(t *testing.T) {
+	team := &tenantv1alpha1.Team{
+		ObjectMeta: metav1.ObjectMeta{Name: "nebula", UID: "uid-nebula"},
+		Spec: tenantv1alpha1.TeamSpec{Quota: &tenantv1alpha1.TeamQuota{Hard: corev1.ResourceList{
+			tenantv1alpha1.ResourceNamespaces: resource.MustParse("5"),
+			corev1.ResourceServices:           resource.MustParse("10"),
+		}}},
+	}
+	r := newTestReconciler(team, newTeamNamespace("nebula-dev", team), newTeamNamespace("nebula-test", team),
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "nebula-dev"}})
+
+	status, err := r.quotaStatus(team, []string{"nebula-dev", "nebula-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	namespaces, services := status.Used[tenantv1alpha1.ResourceNamespaces], status.Used[corev1.ResourceServices]
+	if len(status.Used) != 2 || namespaces.Value() != 2 || services.Value() != 1 {
+		t.Errorf("quotaStatus() used = %v, expected 2 namespaces and 1 service", status.Used)
+	}
+	if !reflect.DeepEqual(status.Hard, team.Spec.Quota.Hard) {
+		t.Errorf("quotaStatus() hard = %v, expected %v", status.Hard, team.Spec.Quota.Hard)
+	}
+
+	team.Spec.Quota = nil
+	if status, err := r.quotaStatus(team, nil); status != nil || err != nil {
+		t.Errorf("quotaStatus() without quota = %v, %v, expected nil", status, err)
+	}
+}
+
+func TestNamespacedTeamMapper(t *testing.T) {
+	team := &tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}}
+	r := newTestReconciler(newTeamNamespace("nebula-dev", team), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	mapper := &namespacedTeamMapper{Reader: r.Client}
+
+	for namespace, want := range map[string]int{"nebula-dev": 1, "default": 0, "missing": 0} {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace}}
+		requests := mapper.Map(handler.MapObject{Meta: pod, Object: pod})
+		if len(requests) != want || (want == 1 && requests[0].Name != "nebula") {
+			t.Errorf("Map() in %s = %v, want %d requests", namespace, requests, want)
+		}
+	}
+}
+
+func TestQuotaUsagePredicates(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Resources: corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+	}}}}}
+	podStatus := pod.DeepCopy()
+	podStatus.Status.Phase = corev1.PodRunning
+	podStatus.Status.PodIP = "10.0.0.1"
+	podResized := pod.DeepCopy()
+	podResized.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU] = resource.MustParse("1")
+	podSameQuantity := pod.DeepCopy()
+	podSameQuantity.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU] = resource.MustParse("0.5")
+	podDone := pod.DeepCopy()
+	podDone.Status.Phase = corev1.PodSucceeded
+
+	pvc := &corev1.PersistentVolumeClaim{Spec: corev1.PersistentVolumeClaimSpec{Resources: corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+	}}}
+	pvcBound := pvc.DeepCopy()
+	pvcBound.Status.Phase = corev1.ClaimBound
+	pvcExpanded := pvc.DeepCopy()
+	pvcExpanded.Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("2Gi")
+
+	service := &corev1.Service{}
+	serviceNodePort := &corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort}}
+
+	for name, tc := range map[string]struct {
+		predicate interface {
+			Update(event.UpdateEvent) bool
+		}
+		old, new runtime.Object
+		want     bool
+	}{
+		"pod status":          {podUsageChanged, pod, podStatus, false},
+		"pod same quantity":   {podUsageChanged, pod, podSameQuantity, false},
+		"pod resized":         {podUsageChanged, pod, podResized, true},
+		"pod terminated":      {podUsageChanged, pod, podDone, true},
+		"claim bound":         {pvcUsageChanged, pvc, pvcBound, false},
+		"claim expanded":      {pvcUsageChanged, pvc, pvcExpanded, true},
+		"service type change": {createdOrDeleted, service, serviceNodePort, false},
+	} {
+		e := event.UpdateEvent{ObjectOld: tc.old, ObjectNew: tc.new}
+		if got := tc.predicate.Update(e); got != tc.want {
+			t.Errorf("%s: Update() = %v, want %v", name, got, tc.want)
+		}
+	}
+	if !createdOrDeleted.Create(event.CreateEvent{}) || !createdOrDeleted.Delete(event.DeleteEvent{}) {
+		t.Error("createdOrDeleted drops create or delete events")
+	}
+}
+
+func TestCreateTeamRoleBindingsInheritsAncestors(t *testing.T) {
+	department := &tenantv1alpha1.Team{
+		ObjectMeta: metav1.ObjectMeta{Name: "department", UID: "uid-department"},
+		Spec: tenantv1alpha1.TeamSpec{
+			Manager: "head",
+			Viewers: []rbac.Subject{{Kind: rbac.UserKind, Name: "auditor"}},
+		},
+	}
+	team := &tenantv1alpha1.Team{
+		ObjectMeta: metav1.ObjectMeta{Name: "nebula", UID: "uid-nebula"},
+		Spec:       tenantv1alpha1.TeamSpec{Manager: "lead", Parent: "department"},
+	}
+	r := newTestReconciler(department, team)
+
+	if err := r.createTeamRoleBindings(team, []tenantv1alpha1.Team{*department}); err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string][]string{
+		getTeamAdminRoleBindingName("nebula"):   {"lead", "head", "kubenebula:team:nebula:admin"},
+		getTeamRegularRoleBindingName("nebula"): {"kubenebula:team:nebula:regular"},
+		getTeamViewerRoleBindingName("nebula"):  {"auditor", "kubenebula:team:nebula:viewer"},
+	} {
+		binding := &rbac.ClusterRoleBinding{}
+		if err := r.Get(context.TODO(), types.NamespacedName{Name: name}, binding); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, subject := range binding.Subjects {
+			names = append(names, subject.Name)
+		}
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("%s subjects = %v, expected %v", name, names, expected)
+		}
+	}
+}
+
+func TestBlockedByChildren(t *testing.T) {
+	now := metav1.Now()
+	department := &tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "department", UID: "uid-department", DeletionTimestamp: &now}}
+	child := &tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}, Spec: tenantv1alpha1.TeamSpec{Parent: "department"}}
+	r := newTestReconciler(department, child)
+
+	blocked, err := r.blockedByChildren(department)
+	if err != nil || !blocked {
+		t.Fatalf("blockedByChildren() = %v, %v, expected blocked", blocked, err)
+	}
+	if condition := department.Status.GetCondition(tenantv1alpha1.TeamReady); condition == nil || condition.Reason != "DeletionBlocked" {
+		t.Errorf("Ready condition = %+v, expected DeletionBlocked", condition)
+	}
+
+	if err := r.Delete(context.TODO(), child); err != nil {
+		t.Fatal(err)
+	}
+	if blocked, err := r.blockedByChildren(department); err != nil || blocked {
+		t.Errorf("blockedByChildren() = %v, %v, expected not blocked", blocked, err)
+	}
+}
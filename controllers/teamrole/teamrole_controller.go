@@ -0,0 +1,282 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package teamrole
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/sliceutil"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var log = logf.Log.WithName("teamrole-controller")
+
+// reservedRoleNames are the built-in team roles, a TeamRole cannot take over their ClusterRoles
+var reservedRoleNames = []string{tenantv1alpha1.TeamAdminTemplate, tenantv1alpha1.TeamRegularTemplate, tenantv1alpha1.TeamViewerTemplate}
+
+// TeamRoleReconciler reconciles the team level ClusterRole and ClusterRoleBinding of a TeamRole.
+// The namespace controller materializes the Role and RoleBinding in every team namespace.
+type TeamRoleReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=tenant.kubenebula.io,resources=teamroles,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=tenant.kubenebula.io,resources=teamroles/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=escalate;bind
+
+func (r *TeamRoleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	instance := &tenantv1alpha1.TeamRole{}
+	err := r.Get(context.TODO(), req.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// The owned ClusterRole and ClusterRoleBinding are garbage collected.
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if !instance.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	status := instance.Status.DeepCopy()
+	status.ObservedGeneration = instance.Generation
+
+	reconcileErr := r.reconcileClusterRole(instance, status)
+	if err := r.updateStatus(instance, status); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, reconcileErr
+}
+
+// reconcileClusterRole creates the team:<team>:<role> ClusterRole and its binding when the TeamRole has team rules,
+// and deletes them otherwise. The outcome is reported in the Ready condition.
+func (r *TeamRoleReconciler) reconcileClusterRole(instance *tenantv1alpha1.TeamRole, status *tenantv1alpha1.TeamRoleStatus) error {
+	team := &tenantv1alpha1.Team{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: instance.Spec.Team}, team); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		status.SetCondition(tenantv1alpha1.TeamReady, corev1.ConditionFalse, "TeamNotFound",
+			fmt.Sprintf("team %s does not exist", instance.Spec.Team))
+		return r.pruneClusterRoles(instance, "")
+	}
+	if sliceutil.HasString(reservedRoleNames, instance.GetRoleName()) {
+		status.SetCondition(tenantv1alpha1.TeamReady, corev1.ConditionFalse, "ReservedRoleName",
+			fmt.Sprintf("role name %s is reserved for the built-in team roles", instance.GetRoleName()))
+		return r.pruneClusterRoles(instance, "")
+	}
+
+	name := getTeamRoleName(instance)
+	if len(instance.Spec.TeamRules) == 0 {
+		name = ""
+	}
+	// Drops the objects of a previous team or role name, or all of them without team rules
+	if err := r.pruneClusterRoles(instance, name); err != nil {
+		return r.failed(status, err)
+	}
+	if name == "" {
+		status.SetCondition(tenantv1alpha1.TeamReady, corev1.ConditionTrue, "Reconciled", "")
+		return nil
+	}
+
+	if err := r.createClusterRole(instance, name); err != nil {
+		return r.failed(status, err)
+	}
+	if err := r.createClusterRoleBinding(instance, name); err != nil {
+		return r.failed(status, err)
+	}
+	status.SetCondition(tenantv1alpha1.TeamReady, corev1.ConditionTrue, "Reconciled", "")
+	return nil
+}
+
+func (r *TeamRoleReconciler) failed(status *tenantv1alpha1.TeamRoleStatus, err error) error {
+	status.SetCondition(tenantv1alpha1.TeamReady, corev1.ConditionFalse, "ReconcileFailed", err.Error())
+	return err
+}
+
+func (r *TeamRoleReconciler) createClusterRole(instance *tenantv1alpha1.TeamRole, name string) error {
+	role := &rbac.ClusterRole{}
+	role.Name = name
+	role.Labels = getLabels(instance)
+	role.Rules = tenantv1alpha1.RenderRules(instance.Spec.TeamRules, instance.Spec.Team)
+	if err := controllerutil.SetControllerReference(instance, role, r.Scheme); err != nil {
+		return err
+	}
+
+	found := &rbac.ClusterRole{}
+	err := r.Get(context.TODO(), types.NamespacedName{Name: name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		log.Info("Creating team role", "teamrole", instance.Name, "name", name)
+		return r.Create(context.TODO(), role)
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(found, instance) {
+		return fmt.Errorf("cluster role %s already exists and is not controlled by the team role", name)
+	}
+	if !reflect.DeepEqual(role.Rules, found.Rules) || !reflect.DeepEqual(role.Labels, found.Labels) {
+		found.Rules = role.Rules
+		found.Labels = role.Labels
+		log.Info("Updating team role", "teamrole", instance.Name, "name", name)
+		return r.Update(context.TODO(), found)
+	}
+	return nil
+}
+
+func (r *TeamRoleReconciler) createClusterRoleBinding(instance *tenantv1alpha1.TeamRole, name string) error {
+	binding := &rbac.ClusterRoleBinding{}
+	binding.Name = name
+	binding.Labels = getLabels(instance)
+	binding.RoleRef = rbac.RoleRef{APIGroup: rbac.GroupName, Kind: "ClusterRole", Name: name}
+	binding.Subjects = teamutil.Subjects(teamutil.WithGroup(instance.Spec.Members, instance.Spec.Team, instance.GetRoleName()))
+	if err := controllerutil.SetControllerReference(instance, binding, r.Scheme); err != nil {
+		return err
+	}
+
+	found := &rbac.ClusterRoleBinding{}
+	err := r.Get(context.TODO(), types.NamespacedName{Name: name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		log.Info("Creating team role binding", "teamrole", instance.Name, "name", name)
+		return r.Create(context.TODO(), binding)
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(found, instance) {
+		return fmt.Errorf("cluster role binding %s already exists and is not controlled by the team role", name)
+	}
+	if !reflect.DeepEqual(binding.RoleRef, found.RoleRef) {
+		// The role ref is immutable, the binding is created again on the next reconcile.
+		log.Info("Deleting conflict team role binding", "teamrole", instance.Name, "name", name)
+		if err := r.Delete(context.TODO(), found); err != nil {
+			return err
+		}
+		return fmt.Errorf("conflict role binding %s, deleted", name)
+	}
+	if !teamutil.EqualSubjects(binding.Subjects, found.Subjects) || !reflect.DeepEqual(binding.Labels, found.Labels) {
+		found.Subjects = binding.Subjects
+		found.Labels = binding.Labels
+		log.Info("Updating team role binding", "teamrole", instance.Name, "name", name)
+		return r.Update(context.TODO(), found)
+	}
+	return nil
+}
+
+// pruneClusterRoles deletes the ClusterRoles and ClusterRoleBindings controlled by the TeamRole except the ones named keep
+func (r *TeamRoleReconciler) pruneClusterRoles(instance *tenantv1alpha1.TeamRole, keep string) error {
+	selector := client.MatchingLabels{constants.TeamRoleLabelKey: instance.Name}
+	roles := &rbac.ClusterRoleList{}
+	if err := r.List(context.TODO(), roles, selector); err != nil {
+		return err
+	}
+	bindings := &rbac.ClusterRoleBindingList{}
+	if err := r.List(context.TODO(), bindings, selector); err != nil {
+		return err
+	}
+	var stale []ownedObject
+	for i := range bindings.Items {
+		stale = append(stale, &bindings.Items[i])
+	}
+	for i := range roles.Items {
+		stale = append(stale, &roles.Items[i])
+	}
+	for _, obj := range stale {
+		if obj.GetName() == keep || !metav1.IsControlledBy(obj, instance) {
+			continue
+		}
+		log.Info("Deleting stale team role object", "teamrole", instance.Name, "name", obj.GetName())
+		if err := r.Delete(context.TODO(), obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownedObject is a ClusterRole or ClusterRoleBinding controlled by a TeamRole
+type ownedObject interface {
+	runtime.Object
+	metav1.Object
+}
+
+func (r *TeamRoleReconciler) updateStatus(instance *tenantv1alpha1.TeamRole, status *tenantv1alpha1.TeamRoleStatus) error {
+	if reflect.DeepEqual(&instance.Status, status) {
+		return nil
+	}
+	instance.Status = *status
+	return r.Status().Update(context.TODO(), instance)
+}
+
+func (r *TeamRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&tenantv1alpha1.TeamRole{}).
+		Owns(&rbac.ClusterRole{}).
+		Owns(&rbac.ClusterRoleBinding{}).
+		Watches(&source.Kind{Type: &tenantv1alpha1.Team{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: &teamRoleMapper{Client: mgr.GetClient()},
+		}).
+		Complete(r)
+}
+
+// teamRoleMapper maps a Team to its TeamRoles, which wait for the team to exist
+type teamRoleMapper struct {
+	client.Client
+}
+
+func (m *teamRoleMapper) Map(obj handler.MapObject) []reconcile.Request {
+	teamRoles := &tenantv1alpha1.TeamRoleList{}
+	if err := m.List(context.TODO(), teamRoles); err != nil {
+		log.Error(err, "Unable to list team roles", "team", obj.Meta.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, teamRole := range teamRoles.Items {
+		if teamRole.Spec.Team == obj.Meta.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: teamRole.Name}})
+		}
+	}
+	return requests
+}
+
+func getLabels(instance *tenantv1alpha1.TeamRole) map[string]string {
+	labels := teamutil.Labels(instance.Spec.Team)
+	labels[constants.TeamRoleLabelKey] = instance.Name
+	return labels
+}
+
+func getTeamRoleName(instance *tenantv1alpha1.TeamRole) string {
+	return fmt.Sprintf("team:%s:%s", instance.Spec.Team, instance.GetRoleName())
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package teamrole
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newTestReconciler(objects ...runtime.Object) *TeamRoleReconciler {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	return &TeamRoleReconciler{Client: fake.NewFakeClientWithScheme(scheme, objects...), Scheme: scheme}
+}
+
+func reconcileTeamRole(t *testing.T, r *TeamRoleReconciler, name string) *tenantv1alpha1.TeamRole {
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name}}); err != nil {
+		t.Fatal(err)
+	}
+	teamRole := &tenantv1alpha1.TeamRole{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: name}, teamRole); err != nil {
+		t.Fatal(err)
+	}
+	return teamRole
+}
+
+func TestReconcileTeamRules(t *testing.T) {
+	teamRole := &tenantv1alpha1.TeamRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "nebula-deployer", UID: "uid-deployer"},
+		Spec: tenantv1alpha1.TeamRoleSpec{
+			Team:     "nebula",
+			RoleName: "deployer",
+			TeamRules: []rbac.PolicyRule{{
+				Verbs:         []string{"get"},
+				APIGroups:     []string{"tenant.kubenebula.io"},
+				Resources:     []string{"teams"},
+				ResourceNames: []string{tenantv1alpha1.TeamNamePlaceholder},
+			}},
+			Members: []rbac.Subject{{Kind: rbac.UserKind, Name: "lisi"}},
+		},
+	}
+	r := newTestReconciler(&tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}}, teamRole)
+
+	teamRole = reconcileTeamRole(t, r, "nebula-deployer")
+	if c := teamRole.Status.GetCondition(tenantv1alpha1.TeamReady); c == nil || c.Status != corev1.ConditionTrue {
+		t.Errorf("ready condition = %+v", c)
+	}
+	role := &rbac.ClusterRole{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "team:nebula:deployer"}, role); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(role.Rules[0].ResourceNames, []string{"nebula"}) || !metav1.IsControlledBy(role, teamRole) {
+		t.Errorf("cluster role = %+v", role)
+	}
+	binding := &rbac.ClusterRoleBinding{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "team:nebula:deployer"}, binding); err != nil {
+		t.Fatal(err)
+	}
+	if len(binding.Subjects) != 2 || binding.Subjects[0].Name != "lisi" || binding.Subjects[1].Name != "kubenebula:team:nebula:deployer" {
+		t.Errorf("subjects = %+v", binding.Subjects)
+	}
+
+	// Without team rules the cluster role and binding are removed
+	teamRole.Spec.TeamRules = nil
+	if err := r.Update(context.TODO(), teamRole); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTeamRole(t, r, "nebula-deployer")
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "team:nebula:deployer"}, &rbac.ClusterRole{}); !errors.IsNotFound(err) {
+		t.Errorf("cluster role not deleted: %v", err)
+	}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "team:nebula:deployer"}, &rbac.ClusterRoleBinding{}); !errors.IsNotFound(err) {
+		t.Errorf("cluster role binding not deleted: %v", err)
+	}
+}
+
+func TestReconcileReservedRoleName(t *testing.T) {
+	teamRole := &tenantv1alpha1.TeamRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "admin"},
+		Spec: tenantv1alpha1.TeamRoleSpec{
+			Team:      "nebula",
+			TeamRules: []rbac.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}}},
+		},
+	}
+	r := newTestReconciler(&tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}}, teamRole)
+
+	teamRole = reconcileTeamRole(t, r, "admin")
+	if c := teamRole.Status.GetCondition(tenantv1alpha1.TeamReady); c == nil || c.Reason != "ReservedRoleName" {
+		t.Errorf("ready condition = %+v", c)
+	}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "team:nebula:admin"}, &rbac.ClusterRole{}); !errors.IsNotFound(err) {
+		t.Errorf("built-in cluster role taken over: %v", err)
+	}
+}
@@ -0,0 +1,363 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/authentication"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/tenant"
+	"kubenebula.io/kubenebula/users"
+	"kubenebula.io/kubenebula/utils/httputil"
+)
+
+// authenticatedGroup is added to every user, as the kube-apiserver does for authenticated requests
+const authenticatedGroup = "system:authenticated"
+
+// listResult is the response body of list operations
+type listResult struct {
+	Items      interface{} `json:"items"`
+	TotalItems int         `json:"totalItems"`
+}
+
+// newListResult returns the list result of a slice of items, nil slices are written as empty lists
+func newListResult(items interface{}) *listResult {
+	value := reflect.ValueOf(items)
+	if value.IsNil() {
+		items = reflect.MakeSlice(value.Type(), 0, 0).Interface()
+	}
+	return &listResult{Items: items, TotalItems: value.Len()}
+}
+
+type handler struct {
+	service        *tenant.Service
+	authz          tenant.Authorizer
+	trustedProxies []*net.IPNet
+	tokens         *authentication.Verifier
+}
+
+func newHandler(s *Server) http.Handler {
+	h := &handler{service: s.Service, authz: s.Authorizer, trustedProxies: s.TrustedProxies, tokens: s.Tokens}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/apidocs.json", func(w http.ResponseWriter, r *http.Request) {
+		httputil.WriteJSON(w, http.StatusOK, openAPI())
+	})
+	mux.HandleFunc(APIPrefix+"/", h.authenticated(h.serve))
+	if s.Proxy != nil {
+		for _, pattern := range []string{"/api", "/api/", "/apis", "/apis/"} {
+			mux.HandleFunc(pattern, h.authenticated(s.Proxy.serve))
+		}
+	}
+	if s.Login != nil {
+		mux.Handle(LoginPath, s.Login)
+	}
+	return mux
+}
+
+// authenticate returns the user of the bearer token of the request or the user named by the front proxy,
+// the user name header of requests from other addresses is not trusted
+func (h *handler) authenticate(r *http.Request) (tenant.User, bool) {
+	if auth := r.Header.Get("Authorization"); h.tokens != nil && strings.HasPrefix(auth, "Bearer ") {
+		return h.authenticateToken(r, strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")))
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return tenant.User{}, false
+	}
+	trusted := false
+	for _, network := range h.trustedProxies {
+		if network.Contains(ip) {
+			trusted = true
+			break
+		}
+	}
+	name := strings.TrimSpace(r.Header.Get(constants.UserNameHeader))
+	if !trusted || name == "" {
+		return tenant.User{}, false
+	}
+	return tenant.User{Name: name, Groups: []string{authenticatedGroup}}, true
+}
+
+// authenticateToken returns the user of a valid token, disabled built-in users are rejected
+func (h *handler) authenticateToken(r *http.Request, token string) (tenant.User, bool) {
+	claims, err := h.tokens.Verify(token)
+	if err != nil {
+		log.V(1).Info("Rejected token", "reason", err.Error())
+		return tenant.User{}, false
+	}
+	disabled, err := users.Disabled(r.Context(), h.service, claims.Subject)
+	if err != nil {
+		log.Error(err, "unable to get user", "user", claims.Subject)
+		return tenant.User{}, false
+	}
+	if disabled {
+		return tenant.User{}, false
+	}
+	return tenant.User{Name: claims.Subject, Groups: append([]string{authenticatedGroup}, authentication.ClaimedGroups(claims)...)}, true
+}
+
+// authenticated rejects requests that were not sent by a trusted proxy or with a valid token
+func (h *handler) authenticated(next func(http.ResponseWriter, *http.Request, tenant.User)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := h.authenticate(r)
+		if !ok {
+			message := fmt.Sprintf("the request must be sent by a trusted proxy with the %s header", constants.UserNameHeader)
+			if h.tokens != nil {
+				message += " or carry a valid bearer token"
+			}
+			httputil.WriteError(w, errors.NewUnauthorized(message))
+			return
+		}
+		next(w, r, user)
+	}
+}
+
+// serve dispatches the request to the matching route
+func (h *handler) serve(w http.ResponseWriter, r *http.Request, user tenant.User) {
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, APIPrefix), "/")
+	methodAllowed := false
+	for i := range routes {
+		rt := &routes[i]
+		if params, ok := rt.match(r.Method, path); ok {
+			rt.handle(h, w, r, user, params)
+			return
+		}
+		if _, ok := rt.match(rt.method, path); ok {
+			methodAllowed = true
+		}
+	}
+	if methodAllowed {
+		httputil.WriteError(w, errors.NewMethodNotSupported(schema.GroupResource{Group: tenantv1alpha1.GroupVersion.Group}, r.Method))
+		return
+	}
+	httputil.WriteError(w, errors.NewGenericServerResponse(http.StatusNotFound, r.Method,
+		schema.GroupResource{Group: tenantv1alpha1.GroupVersion.Group}, "", "", 0, false))
+}
+
+// authorize writes a forbidden error and returns false unless the user is allowed the attributes
+func (h *handler) authorize(w http.ResponseWriter, r *http.Request, user tenant.User, attributes authorizationv1.ResourceAttributes) bool {
+	allowed, reason, err := h.authz.Authorize(r.Context(), user, attributes)
+	if err != nil {
+		httputil.WriteError(w, err)
+		return false
+	}
+	if !allowed {
+		httputil.WriteError(w, forbidden(user, attributes, reason))
+	}
+	return allowed
+}
+
+func forbidden(user tenant.User, attributes authorizationv1.ResourceAttributes, reason string) error {
+	resource := attributes.Resource
+	if attributes.Subresource != "" {
+		resource += "/" + attributes.Subresource
+	}
+	message := fmt.Sprintf("user %q cannot %s %s", user.Name, attributes.Verb, resource)
+	if attributes.Name != "" {
+		message += fmt.Sprintf(" of %q", attributes.Name)
+	}
+	if reason != "" {
+		message += ": " + reason
+	}
+	return errors.NewForbidden(schema.GroupResource{Group: attributes.Group, Resource: resource}, attributes.Name, fmt.Errorf("%s", message))
+}
+
+func (h *handler) listTeams(w http.ResponseWriter, r *http.Request, user tenant.User, _ map[string]string) {
+	allowed, _, err := h.authz.Authorize(r.Context(), user, tenant.TeamAttributes("list", "", ""))
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	teams, err := h.service.ListTeams(r.Context())
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	if !allowed {
+		memberships, err := h.service.Memberships(r.Context(), user)
+		if err != nil {
+			httputil.WriteError(w, err)
+			return
+		}
+		member := map[string]bool{}
+		for _, membership := range memberships {
+			member[membership.Team] = true
+		}
+		visible := teams[:0]
+		for _, team := range teams {
+			if member[team.Name] {
+				visible = append(visible, team)
+			}
+		}
+		teams = visible
+	}
+	for i := range teams {
+		teams[i].TypeMeta = metav1.TypeMeta{APIVersion: tenantv1alpha1.GroupVersion.String(), Kind: "Team"}
+	}
+	httputil.WriteJSON(w, http.StatusOK, newListResult(teams))
+}
+
+func (h *handler) getTeam(w http.ResponseWriter, r *http.Request, user tenant.User, params map[string]string) {
+	if !h.authorize(w, r, user, tenant.TeamAttributes("get", params["team"], "")) {
+		return
+	}
+	team, err := h.service.GetTeam(r.Context(), params["team"])
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	team.TypeMeta = metav1.TypeMeta{APIVersion: tenantv1alpha1.GroupVersion.String(), Kind: "Team"}
+	httputil.WriteJSON(w, http.StatusOK, team)
+}
+
+func (h *handler) listMembers(w http.ResponseWriter, r *http.Request, user tenant.User, params map[string]string) {
+	if !h.authorize(w, r, user, tenant.TeamAttributes("get", params["team"], "members")) {
+		return
+	}
+	members, err := h.service.ListMembers(r.Context(), params["team"])
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, newListResult(members))
+}
+
+func (h *handler) addMember(w http.ResponseWriter, r *http.Request, user tenant.User, params map[string]string) {
+	if !h.authorize(w, r, user, tenant.TeamAttributes("create", params["team"], "members")) {
+		return
+	}
+	member := tenant.Member{}
+	if err := httputil.ReadBody(r, &member); err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	added, err := h.service.AddMember(r.Context(), params["team"], member)
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	log.Info("Added team member", "team", params["team"], "role", added.Role, "kind", added.Kind, "name", added.Name, "user", user.Name)
+	httputil.WriteJSON(w, http.StatusCreated, &added)
+}
+
+func (h *handler) removeMember(w http.ResponseWriter, r *http.Request, user tenant.User, params map[string]string) {
+	if !h.authorize(w, r, user, tenant.TeamAttributes("delete", params["team"], "members")) {
+		return
+	}
+	member := tenant.Member{
+		Role:    params["role"],
+		Subject: rbac.Subject{Kind: params["kind"], Name: params["name"], Namespace: r.URL.Query().Get("namespace")},
+	}
+	if err := h.service.RemoveMember(r.Context(), params["team"], member); err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	log.Info("Removed team member", "team", params["team"], "role", member.Role, "kind", member.Kind, "name", member.Name, "user", user.Name)
+	httputil.WriteJSON(w, http.StatusOK, &metav1.Status{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+		Status:   metav1.StatusSuccess,
+	})
+}
+
+// listNamespaces lists all namespaces of the team for users allowed to get teams/namespaces,
+// and otherwise the namespaces the user holds a namespace role in.
+func (h *handler) listNamespaces(w http.ResponseWriter, r *http.Request, user tenant.User, params map[string]string) {
+	teamAttributes := tenant.TeamAttributes("get", params["team"], "namespaces")
+	allowed, reason, err := h.authz.Authorize(r.Context(), user, teamAttributes)
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	namespaces, err := h.service.ListNamespaces(r.Context(), params["team"])
+	if err != nil {
+		if !allowed && errors.IsNotFound(err) {
+			// do not reveal which teams exist
+			err = forbidden(user, teamAttributes, reason)
+		}
+		httputil.WriteError(w, err)
+		return
+	}
+	if !allowed {
+		visible := make([]corev1.Namespace, 0, len(namespaces))
+		for _, namespace := range namespaces {
+			ok, _, err := h.authz.Authorize(r.Context(), user, tenant.NamespaceAttributes("get", namespace.Name))
+			if err != nil {
+				httputil.WriteError(w, err)
+				return
+			}
+			if ok {
+				visible = append(visible, namespace)
+			}
+		}
+		if len(visible) == 0 {
+			httputil.WriteError(w, forbidden(user, teamAttributes, reason))
+			return
+		}
+		namespaces = visible
+	}
+	for i := range namespaces {
+		namespaces[i].TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"}
+	}
+	httputil.WriteJSON(w, http.StatusOK, newListResult(namespaces))
+}
+
+func (h *handler) createNamespace(w http.ResponseWriter, r *http.Request, user tenant.User, params map[string]string) {
+	if !h.authorize(w, r, user, tenant.TeamAttributes("create", params["team"], "namespaces")) {
+		return
+	}
+	namespace := &corev1.Namespace{}
+	if err := httputil.ReadBody(r, namespace); err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	created, err := h.service.CreateNamespace(r.Context(), params["team"], namespace, user.Name)
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	log.Info("Created team namespace", "team", params["team"], "namespace", created.Name, "user", user.Name)
+	created.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"}
+	httputil.WriteJSON(w, http.StatusCreated, created)
+}
+
+func (h *handler) listMemberships(w http.ResponseWriter, r *http.Request, user tenant.User, _ map[string]string) {
+	memberships, err := h.service.Memberships(r.Context(), user)
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, newListResult(memberships))
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/tenant"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeAuthorizer allows the requests listed as "<user> <verb> <resource>/<subresource> <name>"
+type fakeAuthorizer map[string]bool
+
+func (a fakeAuthorizer) Authorize(ctx context.Context, user tenant.User, attributes authorizationv1.ResourceAttributes) (bool, string, error) {
+	return a[user.Name+" "+attributes.Verb+" "+attributes.Resource+"/"+attributes.Subresource+" "+attributes.Name], "", nil
+}
+
+func newTestService(objects ...runtime.Object) *tenant.Service {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	return &tenant.Service{Client: fake.NewFakeClientWithScheme(scheme, objects...)}
+}
+
+func TestHandler(t *testing.T) {
+	service := newTestService(
+		&tenantv1alpha1.Team{
+			ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+			Spec: tenantv1alpha1.TeamSpec{
+				Manager:  "alice",
+				Regulars: []rbac.Subject{{Kind: rbac.UserKind, Name: "bob"}},
+			},
+		},
+		&tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "comet"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-prod", Labels: teamutil.Labels("nebula")}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-test", Labels: teamutil.Labels("nebula")}},
+	)
+	authz := fakeAuthorizer{
+		"root list teams/ ":                  true,
+		"alice get teams/ nebula":            true,
+		"alice get teams/members nebula":     true,
+		"alice create teams/members nebula":  true,
+		"alice delete teams/members nebula":  true,
+		"alice get teams/namespaces nebula":  true,
+		"bob create teams/namespaces nebula": true,
+		"carol get namespaces/ nebula-test":  true,
+	}
+	_, trusted, _ := net.ParseCIDR("192.0.2.0/24")
+	h := newHandler(&Server{Service: service, Authorizer: authz, TrustedProxies: []*net.IPNet{trusted}})
+
+	tests := []struct {
+		name       string
+		user       string
+		remoteAddr string
+		method     string
+		path       string
+		body       string
+		code       int
+		contains   string
+	}{
+		{"unauthenticated", "", "", "GET", "/teams", "", http.StatusUnauthorized, ""},
+		{"untrusted proxy", "alice", "198.51.100.1:4321", "GET", "/teams", "", http.StatusUnauthorized, ""},
+		{"all teams", "root", "", "GET", "/teams", "", http.StatusOK, `"totalItems":2`},
+		{"own teams", "bob", "", "GET", "/teams", "", http.StatusOK, `"totalItems":1`},
+		{"no teams", "carol", "", "GET", "/teams", "", http.StatusOK, `{"items":[],"totalItems":0}`},
+		{"get team", "alice", "", "GET", "/teams/nebula", "", http.StatusOK, `"kind":"Team"`},
+		{"get team forbidden", "bob", "", "GET", "/teams/nebula", "", http.StatusForbidden, `cannot get teams of \"nebula\"`},
+		{"memberships", "bob", "", "GET", "/memberships", "", http.StatusOK, `{"team":"nebula","roles":["regular"]}`},
+		{"create namespace", "bob", "", "POST", "/teams/nebula/namespaces", "metadata:\n  name: nebula-dev\n", http.StatusCreated, `"kubenebula.io/creator":"bob"`},
+		{"all namespaces", "alice", "", "GET", "/teams/nebula/namespaces", "", http.StatusOK, `"totalItems":3`},
+		{"namespace role", "carol", "", "GET", "/teams/nebula/namespaces", "", http.StatusOK, `"totalItems":1`},
+		{"no namespace role", "dave", "", "GET", "/teams/nebula/namespaces", "", http.StatusForbidden, ""},
+		{"hidden team", "dave", "", "GET", "/teams/missing/namespaces", "", http.StatusForbidden, ""},
+		{"forbidden", "bob", "", "GET", "/teams/nebula/members", "", http.StatusForbidden, `cannot get teams/members of \"nebula\"`},
+		{"add member", "alice", "", "POST", "/teams/nebula/members", `{"role":"viewer","kind":"Group","name":"auditors"}`, http.StatusCreated, `"apiGroup":"rbac.authorization.k8s.io"`},
+		{"list members", "alice", "", "GET", "/teams/nebula/members", "", http.StatusOK, `"name":"auditors"`},
+		{"remove member", "alice", "", "DELETE", "/teams/nebula/members/regular/User/bob", "", http.StatusOK, `"Success"`},
+		{"remove missing member", "alice", "", "DELETE", "/teams/nebula/members/regular/User/bob", "", http.StatusNotFound, ""},
+		{"unsupported method", "alice", "", "DELETE", "/teams/nebula/namespaces", "", http.StatusMethodNotAllowed, ""},
+		{"unknown path", "alice", "", "GET", "/projects", "", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, APIPrefix+test.path, strings.NewReader(test.body))
+			if test.remoteAddr != "" {
+				r.RemoteAddr = test.remoteAddr
+			}
+			if test.user != "" {
+				r.Header.Set(constants.UserNameHeader, test.user)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != test.code || !strings.Contains(w.Body.String(), test.contains) {
+				t.Fatalf("%s %s = %d %s, expected %d containing %s", test.method, test.path, w.Code, w.Body, test.code, test.contains)
+			}
+			if w.Code >= 400 {
+				status := &metav1.Status{}
+				if err := json.Unmarshal(w.Body.Bytes(), status); err != nil || status.Kind != "Status" || int(status.Code) != test.code {
+					t.Errorf("expected a Status, got %s", w.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	h := newHandler(&Server{Service: &tenant.Service{}, Authorizer: fakeAuthorizer{}})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/apidocs.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /apidocs.json = %d %s", w.Code, w.Body)
+	}
+
+	doc := struct {
+		BasePath    string                                        `json:"basePath"`
+		Paths       map[string]map[string]struct{ Tags []string } `json:"paths"`
+		Definitions map[string]interface{}                        `json:"definitions"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.BasePath != "/kapis/tenant.kubenebula.io/v1alpha1" {
+		t.Errorf("basePath = %q", doc.BasePath)
+	}
+	for _, rt := range routes {
+		operation, ok := doc.Paths[rt.path][strings.ToLower(rt.method)]
+		if !ok || len(operation.Tags) != 1 || operation.Tags[0] != rt.tag {
+			t.Errorf("%s %s not documented with tag %q: %+v", rt.method, rt.path, rt.tag, operation)
+		}
+		for _, definition := range []string{rt.body, rt.response} {
+			if _, ok := doc.Definitions[definition]; definition != "" && !ok {
+				t.Errorf("%s %s refers to missing definition %q", rt.method, rt.path, definition)
+			}
+		}
+	}
+	if doc.Paths["/teams/{team}/namespaces"]["get"].Tags[0] != constants.NamespaceResourcesTag {
+		t.Errorf("namespaces not grouped under %q", constants.NamespaceResourcesTag)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	networks, err := ParseTrustedProxies("127.0.0.1, ::1,10.0.0.0/8,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 3 || networks[0].String() != "127.0.0.1/32" || networks[1].String() != "::1/128" || networks[2].String() != "10.0.0.0/8" {
+		t.Errorf("ParseTrustedProxies() = %v", networks)
+	}
+	if _, err := ParseTrustedProxies("localhost"); err == nil {
+		t.Error("expected an error for a host name")
+	}
+}
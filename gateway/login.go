@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/authentication"
+	"kubenebula.io/kubenebula/users"
+	"kubenebula.io/kubenebula/utils/httputil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LoginPath is the path of the login endpoint, served without authentication
+const LoginPath = "/login"
+
+// loginRequest is the body of login requests
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// loginResponse is the token issued on login, sent as a bearer token to the gateway and the kube-apiserver
+type loginResponse struct {
+	Token     string      `json:"token"`
+	ExpiresAt metav1.Time `json:"expiresAt"`
+}
+
+// Login issues tokens to the built-in users for their name and password
+type Login struct {
+	// Reader reads the users and their password Secrets, usually the API reader of the manager.
+	Reader client.Reader
+	// Client records the login time in the status of the users.
+	Client client.Client
+	// Signer issues the tokens.
+	Signer *authentication.Signer
+}
+
+func (l *Login) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httputil.WriteError(w, errors.NewMethodNotSupported(schema.GroupResource{Group: tenantv1alpha1.GroupVersion.Group}, r.Method))
+		return
+	}
+	request := &loginRequest{}
+	if err := httputil.ReadBody(r, request); err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	user, err := users.Authenticate(r.Context(), l.Reader, request.Username, request.Password)
+	if err == users.ErrInvalidCredentials {
+		log.Info("Rejected login", "user", request.Username, "remoteAddr", r.RemoteAddr)
+		httputil.WriteError(w, errors.NewUnauthorized(err.Error()))
+		return
+	} else if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	token, expiresAt, err := l.Signer.Sign(users.Username(user.Name))
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+
+	now := metav1.Now()
+	user.Status.LastLoginTime = &now
+	if err := l.Client.Status().Update(r.Context(), user); err != nil {
+		log.Error(err, "unable to record the login time", "user", user.Name)
+	}
+	log.Info("User logged in", "user", user.Name)
+	httputil.WriteJSON(w, http.StatusOK, &loginResponse{Token: token, ExpiresAt: metav1.NewTime(expiresAt)})
+}
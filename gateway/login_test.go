@@ -0,0 +1,127 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/authentication"
+	"kubenebula.io/kubenebula/users"
+)
+
+func TestLogin(t *testing.T) {
+	alice := &tenantv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "alice"}}
+	mallory := &tenantv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "mallory"}}
+	service := newTestService(
+		&tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}, Spec: tenantv1alpha1.TeamSpec{Manager: users.Username("alice")}},
+		alice, mallory,
+	)
+	for _, user := range []*tenantv1alpha1.User{alice, mallory} {
+		if err := users.SetPassword(context.TODO(), service, user, "correct horse"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	h := newHandler(&Server{
+		Service:    service,
+		Authorizer: fakeAuthorizer{},
+		Tokens:     &authentication.Verifier{Keys: []authentication.Key{{PublicKey: key.Public(), Local: true}}},
+		Login:      &Login{Reader: service, Client: service, Signer: &authentication.Signer{Key: key, TTL: time.Hour}},
+	})
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	login := func(username, password string) (string, int) {
+		w := do("POST", LoginPath, "", `{"username":"`+username+`","password":"`+password+`"}`)
+		response := &loginResponse{}
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), response); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return response.Token, w.Code
+	}
+
+	for _, test := range []struct {
+		name, username, password string
+		code                     int
+	}{
+		{"wrong password", "alice", "incorrect horse", http.StatusUnauthorized},
+		{"unknown user", "eve", "correct horse", http.StatusUnauthorized},
+	} {
+		if _, code := login(test.username, test.password); code != test.code {
+			t.Errorf("%s: POST %s = %d, expected %d", test.name, LoginPath, code, test.code)
+		}
+	}
+	if w := do("GET", LoginPath, "", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET %s = %d", LoginPath, w.Code)
+	}
+
+	token, code := login("alice", "correct horse")
+	if code != http.StatusOK {
+		t.Fatalf("POST %s = %d", LoginPath, code)
+	}
+	if w := do("GET", APIPrefix+"/memberships", token, ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"team":"nebula"`) {
+		t.Errorf("GET /memberships = %d %s", w.Code, w.Body)
+	}
+	if w := do("GET", APIPrefix+"/memberships", "token", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /memberships with an invalid token = %d", w.Code)
+	}
+	system, _, err := (&authentication.Signer{Key: key, TTL: time.Hour}).Sign("system:admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := do("GET", APIPrefix+"/memberships", system, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /memberships with a system: token = %d", w.Code)
+	}
+	if err := service.Get(context.TODO(), types.NamespacedName{Name: "alice"}, alice); err != nil || alice.Status.LastLoginTime == nil {
+		t.Errorf("login time not recorded: %v", err)
+	}
+
+	token, code = login("mallory", "correct horse")
+	if code != http.StatusOK {
+		t.Fatalf("POST %s = %d", LoginPath, code)
+	}
+	mallory.Spec.Disabled = true
+	if err := service.Update(context.TODO(), mallory); err != nil {
+		t.Fatal(err)
+	}
+	if _, code := login("mallory", "correct horse"); code != http.StatusUnauthorized {
+		t.Errorf("disabled user logged in: %d", code)
+	}
+	if w := do("GET", APIPrefix+"/memberships", token, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /memberships of a disabled user = %d", w.Code)
+	}
+}
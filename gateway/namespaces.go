@@ -0,0 +1,268 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	toolscache "k8s.io/client-go/tools/cache"
+	"kubenebula.io/kubenebula/utils/httputil"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// watchBufferSize is the number of events a namespace watch may fall behind before it is closed
+const watchBufferSize = 100
+
+// namespaceEvent is a change of a namespace in the manager cache, old is only set on updates
+type namespaceEvent struct {
+	old, namespace *corev1.Namespace
+	deleted        bool
+}
+
+// watchEvent is a namespace watch event as written to the client
+type watchEvent struct {
+	Type   string            `json:"type"`
+	Object *corev1.Namespace `json:"object"`
+}
+
+// namespaceWatcher receives the namespace events of a watch
+type namespaceWatcher struct {
+	events chan namespaceEvent
+}
+
+// namespaceSource answers the namespace lists and watches of users not allowed to list namespaces from the
+// manager cache, so their selectors never reach the kube-apiserver with the credentials of the manager
+type namespaceSource struct {
+	reader client.Reader
+
+	mu       sync.Mutex
+	watchers map[*namespaceWatcher]bool
+}
+
+// newNamespaceSource returns a namespaceSource reading namespaces from reader and receiving their changes from informer
+func newNamespaceSource(reader client.Reader, informer cache.Informer) *namespaceSource {
+	s := &namespaceSource{reader: reader, watchers: map[*namespaceWatcher]bool{}}
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			s.broadcast(namespaceEvent{namespace: toNamespace(obj)})
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			s.broadcast(namespaceEvent{old: toNamespace(oldObj), namespace: toNamespace(newObj)})
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			s.broadcast(namespaceEvent{namespace: toNamespace(obj), deleted: true})
+		},
+	})
+	return s
+}
+
+func toNamespace(obj interface{}) *corev1.Namespace {
+	namespace, _ := obj.(*corev1.Namespace)
+	return namespace
+}
+
+// broadcast sends event to every watch, watches too slow to keep up are closed and re-established by their clients
+func (s *namespaceSource) broadcast(event namespaceEvent) {
+	if event.namespace == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for watcher := range s.watchers {
+		select {
+		case watcher.events <- event:
+		default:
+			close(watcher.events)
+			delete(s.watchers, watcher)
+		}
+	}
+}
+
+func (s *namespaceSource) subscribe() *namespaceWatcher {
+	watcher := &namespaceWatcher{events: make(chan namespaceEvent, watchBufferSize)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchers[watcher] = true
+	return watcher
+}
+
+func (s *namespaceSource) unsubscribe(watcher *namespaceWatcher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watchers[watcher] {
+		close(watcher.events)
+		delete(s.watchers, watcher)
+	}
+}
+
+// namespaceFilter selects the namespaces of the teams of a user matching the selectors of the request
+type namespaceFilter struct {
+	teams  map[string]bool
+	labels labels.Selector
+	fields fields.Selector
+}
+
+func newNamespaceFilter(r *http.Request, teams map[string]bool) (*namespaceFilter, error) {
+	query := r.URL.Query()
+	labelSelector, err := labels.Parse(query.Get("labelSelector"))
+	if err != nil {
+		return nil, errors.NewBadRequest(err.Error())
+	}
+	fieldSelector, err := fields.ParseSelector(query.Get("fieldSelector"))
+	if err != nil {
+		return nil, errors.NewBadRequest(err.Error())
+	}
+	return &namespaceFilter{teams: teams, labels: labelSelector, fields: fieldSelector}, nil
+}
+
+func (f *namespaceFilter) matches(namespace *corev1.Namespace) bool {
+	return f.teams[teamutil.TeamName(namespace)] &&
+		f.labels.Matches(labels.Set(namespace.Labels)) &&
+		f.fields.Matches(fields.Set{"metadata.name": namespace.Name, "status.phase": string(namespace.Status.Phase)})
+}
+
+// serve lists or watches the namespaces of teams
+func (s *namespaceSource) serve(w http.ResponseWriter, r *http.Request, teams map[string]bool, watch bool) {
+	filter, err := newNamespaceFilter(r, teams)
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	if watch {
+		s.watch(w, r, filter)
+		return
+	}
+	list, err := s.list(r, filter)
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, list)
+}
+
+func (s *namespaceSource) list(r *http.Request, filter *namespaceFilter) (*corev1.NamespaceList, error) {
+	namespaces := &corev1.NamespaceList{}
+	if err := s.reader.List(r.Context(), namespaces); err != nil {
+		return nil, err
+	}
+	result := &corev1.NamespaceList{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "NamespaceList"}, Items: []corev1.Namespace{}}
+	for i := range namespaces.Items {
+		if filter.matches(&namespaces.Items[i]) {
+			result.Items = append(result.Items, namespaces.Items[i])
+		}
+	}
+	return result, nil
+}
+
+// watch streams the events of the namespaces selected by filter, starting with the existing namespaces
+// unless the request resumes from a resource version
+func (s *namespaceSource) watch(w http.ResponseWriter, r *http.Request, filter *namespaceFilter) {
+	watcher := s.subscribe()
+	defer s.unsubscribe(watcher)
+
+	var initial []corev1.Namespace
+	if resourceVersion := r.URL.Query().Get("resourceVersion"); resourceVersion == "" || resourceVersion == "0" {
+		list, err := s.list(r, filter)
+		if err != nil {
+			httputil.WriteError(w, err)
+			return
+		}
+		initial = list.Items
+	}
+	var timeout <-chan time.Time
+	if seconds, err := strconv.Atoi(r.URL.Query().Get("timeoutSeconds")); err == nil && seconds > 0 {
+		timer := time.NewTimer(time.Duration(seconds) * time.Second)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	send := func(eventType string, namespace *corev1.Namespace) bool {
+		namespace = namespace.DeepCopy()
+		namespace.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"}
+		if err := encoder.Encode(&watchEvent{Type: eventType, Object: namespace}); err != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+	for i := range initial {
+		if !send("ADDED", &initial[i]) {
+			return
+		}
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timeout:
+			return
+		case event, ok := <-watcher.events:
+			if !ok {
+				return
+			}
+			if eventType := filter.eventType(event); eventType != "" && !send(eventType, event.namespace) {
+				return
+			}
+		}
+	}
+}
+
+// eventType returns the type of the watch event of event as seen through the filter, empty when it is not visible.
+// Namespaces entering or leaving the filter are added or deleted.
+func (f *namespaceFilter) eventType(event namespaceEvent) string {
+	matches := f.matches(event.namespace)
+	switch {
+	case event.deleted:
+		if matches {
+			return "DELETED"
+		}
+	case event.old == nil:
+		if matches {
+			return "ADDED"
+		}
+	case f.matches(event.old) && matches:
+		return "MODIFIED"
+	case matches:
+		return "ADDED"
+	case f.matches(event.old):
+		return "DELETED"
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"kubenebula.io/kubenebula/constants"
+)
+
+// object is a JSON object of the OpenAPI document
+type object map[string]interface{}
+
+// tags are the OpenAPI tags grouping the routes
+var tags = []object{
+	{"name": constants.TenantResourcesTag, "description": "Teams, their members and the teams of the user"},
+	{"name": constants.NamespaceResourcesTag, "description": "Namespaces of teams"},
+}
+
+func schemaRef(definition string) object {
+	return object{"$ref": "#/definitions/" + definition}
+}
+
+func stringProperty(description string) object {
+	return object{"type": "string", "description": description}
+}
+
+func listDefinition(item string) object {
+	return object{
+		"type":     "object",
+		"required": []string{"items", "totalItems"},
+		"properties": object{
+			"items":      object{"type": "array", "items": schemaRef(item)},
+			"totalItems": object{"type": "integer", "format": "int32"},
+		},
+	}
+}
+
+// resourceDefinition describes a Kubernetes resource, its fields are documented by the resource itself
+func resourceDefinition(description string) object {
+	return object{
+		"type":        "object",
+		"description": description,
+		"properties": object{
+			"apiVersion": stringProperty("API version of the resource"),
+			"kind":       stringProperty("kind of the resource"),
+			"metadata":   object{"type": "object", "description": "standard object metadata"},
+			"spec":       object{"type": "object"},
+			"status":     object{"type": "object"},
+		},
+	}
+}
+
+var definitions = object{
+	"Team":          resourceDefinition("a Team of tenant.kubenebula.io/" + constants.APIVersion),
+	"TeamList":      listDefinition("Team"),
+	"Namespace":     resourceDefinition("a v1 Namespace, labeled and annotated with its team"),
+	"NamespaceList": listDefinition("Namespace"),
+	"Member": object{
+		"type":     "object",
+		"required": []string{"role", "kind", "name"},
+		"properties": object{
+			"role":      stringProperty("admin, regular or viewer"),
+			"kind":      stringProperty("User, Group or ServiceAccount"),
+			"apiGroup":  stringProperty("rbac.authorization.k8s.io for users and groups, defaulted"),
+			"name":      stringProperty("name of the user, group or service account"),
+			"namespace": stringProperty("namespace of the service account"),
+		},
+	},
+	"MemberList": listDefinition("Member"),
+	"Membership": object{
+		"type":     "object",
+		"required": []string{"team", "roles"},
+		"properties": object{
+			"team":  stringProperty("name of the team"),
+			"roles": object{"type": "array", "items": object{"type": "string"}, "description": "built-in and custom team roles of the user"},
+		},
+	},
+	"MembershipList": listDefinition("Membership"),
+	"Status": object{
+		"type":        "object",
+		"description": "a v1 Status, returned on success of deletions and on errors",
+		"properties": object{
+			"apiVersion": stringProperty("v1"),
+			"kind":       stringProperty("Status"),
+			"status":     stringProperty("Success or Failure"),
+			"message":    stringProperty("description of the error"),
+			"reason":     stringProperty("machine readable reason of the error"),
+			"code":       object{"type": "integer", "format": "int32"},
+		},
+	},
+}
+
+// openAPI returns the Swagger 2.0 document of the routes
+func openAPI() object {
+	paths := object{}
+	for _, rt := range routes {
+		parameters := []object{{
+			"name":        constants.UserNameHeader,
+			"in":          "header",
+			"required":    false,
+			"type":        "string",
+			"description": "name of the user, set by the front proxy, requests without it send a bearer token issued by " + LoginPath,
+		}}
+		for _, p := range rt.parameters {
+			in := "path"
+			if p.query {
+				in = "query"
+			}
+			parameters = append(parameters, object{
+				"name": p.name, "in": in, "required": p.required, "type": "string", "description": p.description,
+			})
+		}
+		if rt.body != "" {
+			parameters = append(parameters, object{"name": "body", "in": "body", "required": true, "schema": schemaRef(rt.body)})
+		}
+		operation := object{
+			"tags":        []string{rt.tag},
+			"summary":     rt.summary,
+			"operationId": rt.operationID,
+			"consumes":    []string{"application/json", "application/yaml"},
+			"produces":    []string{"application/json"},
+			"parameters":  parameters,
+			"responses": object{
+				strconv.Itoa(rt.status): object{"description": http.StatusText(rt.status), "schema": schemaRef(rt.response)},
+				"default":               object{"description": "error", "schema": schemaRef("Status")},
+			},
+		}
+		item, ok := paths[rt.path].(object)
+		if !ok {
+			item = object{}
+			paths[rt.path] = item
+		}
+		item[strings.ToLower(rt.method)] = operation
+	}
+	return object{
+		"swagger": "2.0",
+		"info": object{
+			"title":   "KubeNebula",
+			"version": constants.APIVersion,
+		},
+		"basePath":    APIPrefix,
+		"tags":        tags,
+		"paths":       paths,
+		"definitions": definitions,
+	}
+}
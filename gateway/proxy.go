@@ -0,0 +1,168 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"net/http"
+	proxyutil "net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/tenant"
+	"kubenebula.io/kubenebula/utils/httputil"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// +kubebuilder:rbac:groups="",resources=users;groups,verbs=impersonate
+
+// Proxy forwards Kubernetes API requests to the kube-apiserver impersonating the user, who is added
+// to the kubenebula:team:<team>:<role> group of each team role held.
+//
+// Namespace lists and watches of users not allowed to list namespaces are answered from the cache of the
+// manager with the namespaces of the teams of the user, they are never sent to the kube-apiserver.
+type Proxy struct {
+	service    *tenant.Service
+	authz      tenant.Authorizer
+	namespaces *namespaceSource
+	target     *url.URL
+	transport  http.RoundTripper
+	// upgradeTransport speaks HTTP/1.1, which the SPDY and websocket upgrades of exec, attach and port-forward require.
+	upgradeTransport http.RoundTripper
+}
+
+// NewProxy returns a Proxy to the kube-apiserver of config, whose user must be allowed to impersonate.
+// The namespace informer and the client of service serve the namespaces of the teams of users.
+func NewProxy(config *rest.Config, service *tenant.Service, authz tenant.Authorizer, namespaceInformer cache.Informer) (*Proxy, error) {
+	target, _, err := rest.DefaultServerURL(config.Host, "", schema.GroupVersion{}, rest.IsConfigTransportTLS(*config))
+	if err != nil {
+		return nil, err
+	}
+	transport, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := rest.TLSConfigFor(config)
+	if err != nil {
+		return nil, err
+	}
+	upgradeTransport, err := rest.HTTPWrappersForConfig(config, &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Proxy{
+		service:          service,
+		authz:            authz,
+		namespaces:       newNamespaceSource(service, namespaceInformer),
+		target:           target,
+		transport:        transport,
+		upgradeTransport: upgradeTransport,
+	}, nil
+}
+
+// impersonationHeaders are removed from the proxied requests, the user is only identified by the front proxy
+var impersonationHeaders = []string{"Authorization", "Impersonate-User", "Impersonate-Group", "Impersonate-Uid", constants.UserNameHeader}
+
+func (p *Proxy) serve(w http.ResponseWriter, r *http.Request, user tenant.User) {
+	memberships, err := p.service.Memberships(r.Context(), user)
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+
+	filtered, watch, err := p.filtered(r, user)
+	if err != nil {
+		httputil.WriteError(w, err)
+		return
+	}
+	if filtered {
+		teams := map[string]bool{}
+		for _, membership := range memberships {
+			teams[membership.Team] = true
+		}
+		p.namespaces.serve(w, r, teams, watch)
+		return
+	}
+
+	r = r.Clone(r.Context())
+	for name := range r.Header {
+		if strings.HasPrefix(name, "Impersonate-Extra-") {
+			r.Header.Del(name)
+		}
+	}
+	for _, name := range impersonationHeaders {
+		r.Header.Del(name)
+	}
+
+	reverseProxy := &proxyutil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL.Scheme = p.target.Scheme
+			r.URL.Host = p.target.Host
+			r.URL.Path = strings.TrimSuffix(p.target.Path, "/") + r.URL.Path
+			r.Host = p.target.Host
+		},
+		Transport: p.transport,
+		// flush immediately, watches and followed logs stream their responses
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Error(err, "unable to proxy request", "method", r.Method, "path", r.URL.Path)
+			httputil.WriteError(w, errors.NewServiceUnavailable(err.Error()))
+		},
+	}
+	if isUpgrade(r) {
+		reverseProxy.Transport = p.upgradeTransport
+	}
+
+	r.Header.Set("Impersonate-User", user.Name)
+	for _, group := range append(user.Groups, tenant.Groups(memberships)...) {
+		r.Header.Add("Impersonate-Group", group)
+	}
+	reverseProxy.ServeHTTP(w, r)
+}
+
+// filtered returns whether r lists or watches namespaces on behalf of a user not allowed to, and whether it watches
+func (p *Proxy) filtered(r *http.Request, user tenant.User) (filtered bool, watch bool, err error) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	if r.Method != http.MethodGet || (path != "/api/v1/namespaces" && path != "/api/v1/watch/namespaces") {
+		return false, false, nil
+	}
+	verb := "list"
+	watch, _ = strconv.ParseBool(r.URL.Query().Get("watch"))
+	if watch = watch || path == "/api/v1/watch/namespaces"; watch {
+		verb = "watch"
+	}
+	allowed, _, err := p.authz.Authorize(r.Context(), user, tenant.NamespaceAttributes(verb, ""))
+	return !allowed, watch, err
+}
+
+func isUpgrade(r *http.Request) bool {
+	for _, value := range r.Header["Connection"] {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/teamutil"
+)
+
+// fakeInformer hands the namespace events of the tests to the event handlers
+type fakeInformer struct {
+	handlers []toolscache.ResourceEventHandler
+}
+
+func (i *fakeInformer) AddEventHandler(handler toolscache.ResourceEventHandler) {
+	i.handlers = append(i.handlers, handler)
+}
+
+func (i *fakeInformer) AddEventHandlerWithResyncPeriod(handler toolscache.ResourceEventHandler, _ time.Duration) {
+	i.AddEventHandler(handler)
+}
+
+func (i *fakeInformer) AddIndexers(toolscache.Indexers) error { return nil }
+
+func (i *fakeInformer) HasSynced() bool { return true }
+
+func teamNamespace(name, team string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: teamutil.Labels(team)}}
+}
+
+func TestProxy(t *testing.T) {
+	var upstreamRequest *http.Request
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamRequest = r
+		fmt.Fprint(w, `{"kind":"PodList","apiVersion":"v1","metadata":{},"items":[]}`)
+	}))
+	defer upstream.Close()
+
+	service := newTestService(
+		&tenantv1alpha1.Team{
+			ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+			Spec: tenantv1alpha1.TeamSpec{
+				Manager:  "alice",
+				Regulars: []rbac.Subject{{Kind: rbac.UserKind, Name: "bob"}},
+			},
+		},
+		teamNamespace("nebula-prod", "nebula"),
+		teamNamespace("nebula-test", "nebula"),
+		teamNamespace("comet-prod", "comet"),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+	)
+	authz := fakeAuthorizer{"root list namespaces/ ": true}
+	proxy, err := NewProxy(&rest.Config{Host: upstream.URL}, service, authz, &fakeInformer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, trusted, _ := net.ParseCIDR("192.0.2.0/24")
+	h := newHandler(&Server{Service: service, Authorizer: authz, TrustedProxies: []*net.IPNet{trusted}, Proxy: proxy})
+
+	tests := []struct {
+		name         string
+		user         string
+		path         string
+		code         int
+		impersonated bool
+		groups       []string
+		contains     []string
+		excludes     []string
+	}{
+		{
+			name: "impersonation", user: "bob", path: "/api/v1/namespaces/nebula-prod/pods",
+			impersonated: true, groups: []string{"system:authenticated", "kubenebula:team:nebula:regular"},
+		},
+		{
+			name: "cluster namespace list", user: "root", path: "/api/v1/namespaces?labelSelector=team",
+			impersonated: true, groups: []string{"system:authenticated"},
+		},
+		{
+			name: "team namespace list", user: "bob", path: "/api/v1/namespaces",
+			contains: []string{`"kind":"NamespaceList"`, "nebula-prod", "nebula-test"}, excludes: []string{"comet-prod", "default"},
+		},
+		{
+			name: "label selector", user: "bob", path: "/api/v1/namespaces?labelSelector=kubenebula.io/team%3Dcomet",
+			contains: []string{`"items":[]`},
+		},
+		{
+			name: "field selector", user: "alice", path: "/api/v1/namespaces?fieldSelector=metadata.name%3Dnebula-test",
+			contains: []string{"nebula-test"}, excludes: []string{"nebula-prod"},
+		},
+		{name: "invalid selector", user: "alice", path: "/api/v1/namespaces?labelSelector=%3D%3D", code: http.StatusBadRequest},
+		{name: "no team namespaces", user: "carol", path: "/api/v1/namespaces", contains: []string{`"items":[]`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			upstreamRequest = nil
+			r := httptest.NewRequest("GET", test.path, nil)
+			r.Header.Set(constants.UserNameHeader, test.user)
+			r.Header.Set("Authorization", "Bearer stolen")
+			r.Header.Set("Impersonate-User", "system:admin")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			expected := test.code
+			if expected == 0 {
+				expected = http.StatusOK
+			}
+			if w.Code != expected {
+				t.Fatalf("GET %s = %d %s, expected %d", test.path, w.Code, w.Body, expected)
+			}
+
+			if !test.impersonated {
+				if upstreamRequest != nil {
+					t.Fatalf("namespace request of %s sent to the kube-apiserver", test.user)
+				}
+			} else {
+				if upstreamRequest.Header.Get("Authorization") != "" {
+					t.Errorf("Authorization header forwarded")
+				}
+				user, groups := upstreamRequest.Header.Get("Impersonate-User"), upstreamRequest.Header["Impersonate-Group"]
+				if user != test.user || strings.Join(groups, ",") != strings.Join(test.groups, ",") {
+					t.Errorf("impersonated %q %v, expected %q %v", user, groups, test.user, test.groups)
+				}
+			}
+
+			for _, s := range test.contains {
+				if !strings.Contains(w.Body.String(), s) {
+					t.Errorf("response %s does not contain %s", w.Body, s)
+				}
+			}
+			for _, s := range test.excludes {
+				if strings.Contains(w.Body.String(), s) {
+					t.Errorf("response %s contains %s", w.Body, s)
+				}
+			}
+		})
+	}
+}
+
+func TestProxyNamespaceWatch(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("namespace watch sent to the kube-apiserver: %s", r.URL)
+	}))
+	defer upstream.Close()
+
+	nebulaProd := teamNamespace("nebula-prod", "nebula")
+	service := newTestService(
+		&tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}, Spec: tenantv1alpha1.TeamSpec{Manager: "alice"}},
+		nebulaProd, teamNamespace("comet-prod", "comet"),
+	)
+	informer := &fakeInformer{}
+	proxy, err := NewProxy(&rest.Config{Host: upstream.URL}, service, fakeAuthorizer{}, informer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newHandler(&Server{
+		Service: service, Authorizer: fakeAuthorizer{}, TrustedProxies: []*net.IPNet{{IP: net.IPv4(127, 0, 0, 1), Mask: net.CIDRMask(32, 32)}}, Proxy: proxy,
+	}))
+	defer server.Close()
+
+	r, _ := http.NewRequest("GET", server.URL+"/api/v1/namespaces?watch=true", nil)
+	r.Header.Set(constants.UserNameHeader, "alice")
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+	expect := func(eventType, name string) {
+		event := &watchEvent{}
+		line, err := events.ReadBytes('\n')
+		if err == nil {
+			err = json.Unmarshal(line, event)
+		}
+		if err != nil || event.Type != eventType || event.Object.Name != name {
+			t.Fatalf("read %s, %v, expected %s %s", line, err, eventType, name)
+		}
+	}
+	expect("ADDED", "nebula-prod")
+
+	send := func(f func(toolscache.ResourceEventHandler)) {
+		for _, handler := range informer.handlers {
+			f(handler)
+		}
+	}
+	send(func(h toolscache.ResourceEventHandler) { h.OnAdd(teamNamespace("comet-test", "comet")) })
+	send(func(h toolscache.ResourceEventHandler) { h.OnAdd(teamNamespace("nebula-test", "nebula")) })
+	expect("ADDED", "nebula-test")
+	moved := teamNamespace("nebula-prod", "comet")
+	send(func(h toolscache.ResourceEventHandler) { h.OnUpdate(nebulaProd, moved) })
+	expect("DELETED", "nebula-prod")
+	send(func(h toolscache.ResourceEventHandler) { h.OnDelete(teamNamespace("nebula-test", "nebula")) })
+	expect("DELETED", "nebula-test")
+}
+
+func TestProxyStreaming(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "first line")
+		w.(http.Flusher).Flush()
+		<-release
+		fmt.Fprintln(w, "second line")
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	service := newTestService()
+	proxy, err := NewProxy(&rest.Config{Host: upstream.URL}, service, fakeAuthorizer{}, &fakeInformer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newHandler(&Server{
+		Service: service, Authorizer: fakeAuthorizer{}, TrustedProxies: []*net.IPNet{{IP: net.IPv4(127, 0, 0, 1), Mask: net.CIDRMask(32, 32)}}, Proxy: proxy,
+	}))
+	defer server.Close()
+
+	r, _ := http.NewRequest("GET", server.URL+"/api/v1/namespaces/nebula-prod/pods/web/log?follow=true", nil)
+	r.Header.Set(constants.UserNameHeader, "bob")
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	// the first line arrives while the upstream response is still open
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != "first line\n" {
+		t.Errorf("read %q, %v", line, err)
+	}
+}
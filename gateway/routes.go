@@ -0,0 +1,130 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"net/http"
+	"strings"
+
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/tenant"
+)
+
+// APIPrefix is the path prefix of the REST API
+var APIPrefix = "/kapis/" + tenantv1alpha1.GroupVersion.Group + "/" + constants.APIVersion
+
+// parameter is a path or query parameter of a route
+type parameter struct {
+	name        string
+	description string
+	query       bool
+	required    bool
+}
+
+// route is an operation of the REST API, the routes serve the requests and are published in the OpenAPI document
+type route struct {
+	method string
+	// path is relative to APIPrefix, {name} segments are path parameters.
+	path        string
+	tag         string
+	operationID string
+	summary     string
+	parameters  []parameter
+	// body and response name the definitions of the request and response bodies.
+	body     string
+	response string
+	status   int
+	handle   func(h *handler, w http.ResponseWriter, r *http.Request, user tenant.User, params map[string]string)
+}
+
+var (
+	teamParameter      = parameter{name: "team", description: "name of the team", required: true}
+	roleParameter      = parameter{name: "role", description: "admin, regular or viewer", required: true}
+	kindParameter      = parameter{name: "kind", description: "User, Group or ServiceAccount", required: true}
+	nameParameter      = parameter{name: "name", description: "name of the member", required: true}
+	namespaceParameter = parameter{name: "namespace", description: "namespace of a ServiceAccount member", query: true}
+)
+
+var routes = []route{
+	{
+		method: http.MethodGet, path: "/teams", tag: constants.TenantResourcesTag,
+		operationID: "listTeams", summary: "List the teams visible to the user",
+		response: "TeamList", status: http.StatusOK, handle: (*handler).listTeams,
+	},
+	{
+		method: http.MethodGet, path: "/teams/{team}", tag: constants.TenantResourcesTag,
+		operationID: "getTeam", summary: "Get a team",
+		parameters: []parameter{teamParameter}, response: "Team", status: http.StatusOK, handle: (*handler).getTeam,
+	},
+	{
+		method: http.MethodGet, path: "/teams/{team}/members", tag: constants.TenantResourcesTag,
+		operationID: "listMembers", summary: "List the members of a team, the manager is the first admin",
+		parameters: []parameter{teamParameter}, response: "MemberList", status: http.StatusOK, handle: (*handler).listMembers,
+	},
+	{
+		method: http.MethodPost, path: "/teams/{team}/members", tag: constants.TenantResourcesTag,
+		operationID: "addMember", summary: "Add a member to a team",
+		parameters: []parameter{teamParameter}, body: "Member", response: "Member", status: http.StatusCreated,
+		handle: (*handler).addMember,
+	},
+	{
+		method: http.MethodDelete, path: "/teams/{team}/members/{role}/{kind}/{name}", tag: constants.TenantResourcesTag,
+		operationID: "removeMember", summary: "Remove a member from a team",
+		parameters: []parameter{teamParameter, roleParameter, kindParameter, nameParameter, namespaceParameter},
+		response:   "Status", status: http.StatusOK, handle: (*handler).removeMember,
+	},
+	{
+		method: http.MethodGet, path: "/teams/{team}/namespaces", tag: constants.NamespaceResourcesTag,
+		operationID: "listNamespaces", summary: "List the namespaces of a team visible to the user",
+		parameters: []parameter{teamParameter}, response: "NamespaceList", status: http.StatusOK, handle: (*handler).listNamespaces,
+	},
+	{
+		method: http.MethodPost, path: "/teams/{team}/namespaces", tag: constants.NamespaceResourcesTag,
+		operationID: "createNamespace", summary: "Create a namespace for a team",
+		parameters: []parameter{teamParameter}, body: "Namespace", response: "Namespace", status: http.StatusCreated,
+		handle: (*handler).createNamespace,
+	},
+	{
+		method: http.MethodGet, path: "/memberships", tag: constants.TenantResourcesTag,
+		operationID: "listMemberships", summary: "List the teams of the user and the roles held in them",
+		response: "MembershipList", status: http.StatusOK, handle: (*handler).listMemberships,
+	},
+}
+
+// match returns the path parameters if path matches the route
+func (rt *route) match(method, path string) (map[string]string, bool) {
+	if method != rt.method {
+		return nil, false
+	}
+	patterns, segments := strings.Split(rt.path, "/"), strings.Split(path, "/")
+	if len(patterns) != len(segments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, pattern := range patterns {
+		if strings.HasPrefix(pattern, "{") && strings.HasSuffix(pattern, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[pattern[1:len(pattern)-1]] = segments[i]
+		} else if pattern != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gateway serves the tenant REST API under /kapis/tenant.kubenebula.io/v1alpha1 for portals sitting
+// behind a trusted front proxy, which authenticates users and passes their name in the X-Token-Username header.
+// Users may also send the bearer tokens issued to the built-in users by the login endpoint.
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"kubenebula.io/kubenebula/authentication"
+	"kubenebula.io/kubenebula/tenant"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("gateway")
+
+// Server is a manager Runnable serving the REST API over plain HTTP
+type Server struct {
+	// Service implements the team operations.
+	Service *tenant.Service
+	// Authorizer authorizes the requests with the team and namespace roles of the user.
+	Authorizer tenant.Authorizer
+	// Addr the server listens on.
+	Addr string
+	// TrustedProxies are the networks allowed to set the user name header.
+	TrustedProxies []*net.IPNet
+	// Proxy serves the Kubernetes API under /api and /apis, nil disables it.
+	Proxy *Proxy
+	// Tokens verifies the bearer tokens of requests sent without the front proxy, nil accepts only the
+	// user name header.
+	Tokens *authentication.Verifier
+	// Login issues tokens to the built-in users on POST /login, nil disables it.
+	Login *Login
+}
+
+// NeedLeaderElection implements the LeaderElectionRunnable interface, every replica serves the API.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the API until stop is closed.
+func (s *Server) Start(stop <-chan struct{}) error {
+	srv := &http.Server{Addr: s.Addr, Handler: newHandler(s)}
+	idleConnsClosed := make(chan struct{})
+	go func() {
+		<-stop
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Error(err, "error shutting down the gateway")
+		}
+		close(idleConnsClosed)
+	}()
+
+	log.Info("Serving tenant REST API", "addr", s.Addr, "prefix", APIPrefix)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	<-idleConnsClosed
+	return nil
+}
+
+// ParseTrustedProxies parses comma separated CIDRs or IP addresses
+func ParseTrustedProxies(value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
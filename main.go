@@ -18,14 +18,29 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"kubenebula.io/kubenebula/apiserver"
+	"kubenebula.io/kubenebula/authentication"
+	"kubenebula.io/kubenebula/authorization"
 	"kubenebula.io/kubenebula/controllers/namespace"
+	"kubenebula.io/kubenebula/controllers/namespaceclaim"
 	"kubenebula.io/kubenebula/controllers/team"
+	"kubenebula.io/kubenebula/controllers/teamrole"
+	"kubenebula.io/kubenebula/gateway"
+	"kubenebula.io/kubenebula/webhooks"
 	"os"
+	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/tenant"
+	"kubenebula.io/kubenebula/users"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	// +kubebuilder:scaffold:imports
@@ -44,13 +59,93 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[1], err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
+	var enableWebhooks bool
+	var webhookOptions webhooks.Options
+	var networkSystemNamespaces string
+	var enableAPIServer bool
+	var apiServerPort int
+	var apiServerCertDir string
+	var enableGateway bool
+	var gatewayAddr string
+	var gatewayTrustedProxies string
+	var enableGatewayProxy bool
+	var tokenOptions authentication.Options
+	var tokenAudiences string
+	var enableAuthorizationWebhook bool
+	var bootstrapAdmin bool
+	var enableLogin bool
+	var tokenTTL time.Duration
+	namespaceScope := bindScopeFlags(flag.CommandLine)
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8081", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the admission webhooks on port 9443. Requires a serving certificate in the webhook server cert dir.")
+	flag.BoolVar(&webhookOptions.RequireTeamManager, "require-team-manager", false,
+		"Reject teams without spec.manager.")
+	flag.BoolVar(&webhookOptions.ValidateUsers, "validate-team-users", false,
+		"Reject teams whose manager or User members are not built-in users named "+constants.BuiltinUserPrefix+"<name>.")
+	flag.StringVar(&networkSystemNamespaces, "network-system-namespaces",
+		strings.Join([]string{constants.KubeSystemNamespace, constants.KubeNebulaNamespace}, ","),
+		"Comma separated namespaces allowed to reach the namespaces of teams with network isolation.")
+	flag.BoolVar(&enableAPIServer, "enable-api-server", false,
+		"Serve the api.kubenebula.io aggregated API. Requires an APIService and the front proxy configuration in kube-system.")
+	flag.IntVar(&apiServerPort, "api-server-port", 8443, "The port the aggregated API server binds to.")
+	flag.StringVar(&apiServerCertDir, "api-server-cert-dir", "/tmp/k8s-webhook-server/serving-certs",
+		"The directory containing the tls.crt and tls.key serving certificate of the aggregated API server.")
+	flag.BoolVar(&enableGateway, "enable-gateway", false,
+		"Serve the tenant REST API for portals behind a front proxy setting the "+constants.UserNameHeader+" header.")
+	flag.StringVar(&gatewayAddr, "gateway-addr", ":8090", "The address the tenant REST API binds to.")
+	flag.StringVar(&gatewayTrustedProxies, "gateway-trusted-proxies", "127.0.0.1/32,::1/128",
+		"Comma separated addresses or CIDRs of the front proxies allowed to set the "+constants.UserNameHeader+" header.")
+	flag.BoolVar(&enableGatewayProxy, "enable-gateway-proxy", false,
+		"Proxy the Kubernetes API under /api and /apis of the gateway, impersonating the users with their team groups.")
+	flag.StringVar(&tokenOptions.JWKSFile, "token-jwks-file", "",
+		"JSON Web Key Set verifying the tokens of the TokenReview webhook, which is served when this or --token-signing-key-file is set.")
+	flag.StringVar(&tokenOptions.SigningKeyFile, "token-signing-key-file", "",
+		"PEM RSA or EC key verifying the tokens of the TokenReview webhook and the gateway, a private key also signs the tokens issued by --enable-login.")
+	flag.StringVar(&tokenOptions.Issuer, "token-issuer", "", "The iss claim required in tokens, empty accepts any issuer.")
+	flag.StringVar(&tokenAudiences, "token-audiences", "", "Comma separated aud claims accepted in tokens, empty accepts any audience.")
+	flag.BoolVar(&enableAuthorizationWebhook, "enable-authorization-webhook", false,
+		"Serve the SubjectAccessReview webhook granting the team roles in the team namespaces.")
+	flag.BoolVar(&bootstrapAdmin, "bootstrap-admin", false,
+		"Create the "+constants.AdminUserName+" user bound to cluster-admin with a generated password when it does not exist. Requires --enable-login.")
+	flag.BoolVar(&enableLogin, "enable-login", false,
+		"Serve POST /login on the gateway, issuing tokens signed with --token-signing-key-file to the built-in users.")
+	flag.DurationVar(&tokenTTL, "token-ttl", time.Hour, "The lifetime of the tokens issued by --enable-login.")
 	flag.Parse()
 
+	scope, err := namespaceScope.scope()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid namespace scope: %s\n", err)
+		os.Exit(1)
+	}
+
+	trustedProxies, err := gateway.ParseTrustedProxies(gatewayTrustedProxies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid gateway trusted proxies: %s\n", err)
+		os.Exit(1)
+	}
+	if enableLogin && (!enableGateway || tokenOptions.SigningKeyFile == "") {
+		fmt.Fprintln(os.Stderr, "--enable-login requires --enable-gateway and --token-signing-key-file")
+		os.Exit(1)
+	}
+	if bootstrapAdmin && !enableLogin {
+		fmt.Fprintln(os.Stderr, "--bootstrap-admin requires --enable-login")
+		os.Exit(1)
+	}
+	tokenOptions.Audiences = splitNames(tokenAudiences)
+
 	ctrl.SetLogger(zap.New(func(o *zap.Options) {
 		o.Development = true
 	}))
@@ -65,7 +160,7 @@ func main() {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
-	err = namespace.Add(mgr)
+	err = namespace.Add(mgr, scope, splitNames(networkSystemNamespaces))
 	if err != nil {
 		setupLog.Error(err, "unable to add namespace manager")
 		os.Exit(1)
@@ -78,6 +173,107 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Team")
 		os.Exit(1)
 	}
+	if err = (&teamrole.TeamRoleReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("TeamRole"),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "TeamRole")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		// the claim requester is recorded by the webhooks, without them any user could claim for any team
+		if err = (&namespaceclaim.NamespaceClaimReconciler{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName("NamespaceClaim"),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "NamespaceClaim")
+			os.Exit(1)
+		}
+		if err = webhooks.Add(mgr, webhookOptions); err != nil {
+			setupLog.Error(err, "unable to add webhooks")
+			os.Exit(1)
+		}
+	}
+	if enableAPIServer {
+		if err = mgr.Add(&apiserver.Server{
+			Service:    &tenant.Service{Client: mgr.GetClient()},
+			Authorizer: &tenant.SubjectAccessReviewer{Client: mgr.GetClient()},
+			Reader:     mgr.GetAPIReader(),
+			Port:       apiServerPort,
+			CertDir:    apiServerCertDir,
+		}); err != nil {
+			setupLog.Error(err, "unable to add aggregated API server")
+			os.Exit(1)
+		}
+	}
+	if tokenOptions.JWKSFile != "" || tokenOptions.SigningKeyFile != "" {
+		if err = authentication.Add(mgr, tokenOptions); err != nil {
+			setupLog.Error(err, "unable to add token authentication webhook")
+			os.Exit(1)
+		}
+	}
+	if enableAuthorizationWebhook {
+		if err = authorization.Add(mgr, scope); err != nil {
+			setupLog.Error(err, "unable to add authorization webhook")
+			os.Exit(1)
+		}
+	}
+	if bootstrapAdmin {
+		if err = mgr.Add(&users.Bootstrap{Client: mgr.GetClient(), Reader: mgr.GetAPIReader()}); err != nil {
+			setupLog.Error(err, "unable to add admin bootstrap")
+			os.Exit(1)
+		}
+	}
+	if enableGateway {
+		service := &tenant.Service{Client: mgr.GetClient()}
+		authorizer := &tenant.SubjectAccessReviewer{Client: mgr.GetClient()}
+		var proxy *gateway.Proxy
+		if enableGatewayProxy {
+			namespaceInformer, err := mgr.GetCache().GetInformer(&corev1.Namespace{})
+			if err != nil {
+				setupLog.Error(err, "unable to get namespace informer")
+				os.Exit(1)
+			}
+			if proxy, err = gateway.NewProxy(mgr.GetConfig(), service, authorizer, namespaceInformer); err != nil {
+				setupLog.Error(err, "unable to create Kubernetes API proxy")
+				os.Exit(1)
+			}
+		}
+		var tokens *authentication.Verifier
+		if tokenOptions.JWKSFile != "" || tokenOptions.SigningKeyFile != "" {
+			if tokens, err = authentication.NewVerifier(tokenOptions); err != nil {
+				setupLog.Error(err, "unable to load token keys")
+				os.Exit(1)
+			}
+		}
+		var login *gateway.Login
+		if enableLogin {
+			key, err := authentication.LoadSigningKey(tokenOptions.SigningKeyFile)
+			if err != nil {
+				setupLog.Error(err, "unable to load token signing key")
+				os.Exit(1)
+			}
+			login = &gateway.Login{
+				Reader: mgr.GetAPIReader(),
+				Client: mgr.GetClient(),
+				Signer: &authentication.Signer{Key: key, Issuer: tokenOptions.Issuer, Audiences: tokenOptions.Audiences, TTL: tokenTTL},
+			}
+		}
+		if err = mgr.Add(&gateway.Server{
+			Service:        service,
+			Authorizer:     authorizer,
+			Addr:           gatewayAddr,
+			TrustedProxies: trustedProxies,
+			Proxy:          proxy,
+			Tokens:         tokens,
+			Login:          login,
+		}); err != nil {
+			setupLog.Error(err, "unable to add gateway")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -86,3 +282,37 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// scopeFlags are the flags selecting the namespaces managed by the namespace controller
+type scopeFlags struct {
+	excluded string
+	selector string
+}
+
+func bindScopeFlags(fs *flag.FlagSet) *scopeFlags {
+	f := &scopeFlags{}
+	fs.StringVar(&f.excluded, "excluded-namespaces", strings.Join(constants.SystemNamespaces, ","),
+		"Comma separated namespaces the namespace controller never manages.")
+	fs.StringVar(&f.selector, "namespace-selector", "",
+		"Label selector restricting the namespaces the namespace controller manages, empty selects all namespaces.")
+	return f
+}
+
+func (f *scopeFlags) scope() (namespace.Scope, error) {
+	selector, err := labels.Parse(f.selector)
+	if err != nil {
+		return namespace.Scope{}, err
+	}
+	return namespace.Scope{ExcludedNamespaces: splitNames(f.excluded), Selector: selector}, nil
+}
+
+// splitNames splits a comma separated list of names, dropping empty ones
+func splitNames(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenance
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/sliceutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PruneNamespaceFinalizers removes the namespace controller finalizer from the namespaces for which inScope
+// returns false. Those namespaces are no longer reconciled, so the finalizer would block their deletion forever.
+func PruneNamespaceFinalizers(c client.Client, inScope func(metav1.Object) bool, dryRun bool) (*Report, error) {
+	report := &Report{DryRun: dryRun}
+
+	nsList := &corev1.NamespaceList{}
+	if err := c.List(context.TODO(), nsList); err != nil {
+		return nil, err
+	}
+
+	for i := range nsList.Items {
+		namespace := &nsList.Items[i]
+		if !sliceutil.HasString(namespace.Finalizers, constants.NamespaceFinalizer) {
+			continue
+		}
+		if inScope(namespace) {
+			report.skipped("namespace %s: in scope", namespace.Name)
+			continue
+		}
+
+		if err := removeFinalizer(c, namespace, constants.NamespaceFinalizer, "namespace "+namespace.Name, report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenance
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"kubenebula.io/kubenebula/constants"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPruneNamespaceFinalizers(t *testing.T) {
+	namespace := func(name string, finalizers ...string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Finalizers: finalizers}}
+	}
+	c := fake.NewFakeClientWithScheme(newScheme(),
+		namespace("kube-system", constants.NamespaceFinalizer, "other"),
+		namespace("dev", constants.NamespaceFinalizer),
+		namespace("plain"),
+	)
+	inScope := func(obj metav1.Object) bool { return obj.GetName() != "kube-system" }
+
+	report, err := PruneNamespaceFinalizers(c, inScope, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changed) != 1 || len(report.Skipped) != 1 {
+		t.Fatalf("dry run report = %+v", report)
+	}
+	found := &corev1.Namespace{}
+	_ = c.Get(context.TODO(), types.NamespacedName{Name: "kube-system"}, found)
+	if len(found.Finalizers) != 2 {
+		t.Errorf("dry run changed finalizers: %v", found.Finalizers)
+	}
+
+	if _, err := PruneNamespaceFinalizers(c, inScope, false); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string][]string{"kube-system": {"other"}, "dev": {constants.NamespaceFinalizer}} {
+		found := &corev1.Namespace{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: name}, found); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(found.Finalizers, want) {
+			t.Errorf("namespace %s finalizers = %v, want %v", name, found.Finalizers, want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package maintenance contains one-shot operations run through the manager subcommands.
+package maintenance
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Report lists the objects changed by a maintenance operation and the ones it had to skip.
+type Report struct {
+	DryRun  bool
+	Changed []string
+	Skipped []string
+}
+
+func (r *Report) changed(format string, args ...interface{}) {
+	r.Changed = append(r.Changed, fmt.Sprintf(format, args...))
+}
+
+func (r *Report) skipped(format string, args ...interface{}) {
+	r.Skipped = append(r.Skipped, fmt.Sprintf(format, args...))
+}
+
+// Print writes the report in a human readable form.
+func (r *Report) Print(w io.Writer) {
+	action := "changed"
+	if r.DryRun {
+		action = "would change (dry run)"
+	}
+	fmt.Fprintf(w, "%d %s:\n", len(r.Changed), action)
+	for _, line := range r.Changed {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+	fmt.Fprintf(w, "%d skipped:\n", len(r.Skipped))
+	for _, line := range r.Skipped {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+}
+
+// MigrateTeamLabels relabels namespaces written by earlier releases to the encoding described in teamutil.
+// The team is resolved from the team annotation, then the legacy base64 label and then a raw team name label,
+// namespaces whose team cannot be resolved to an existing Team are reported as skipped and left untouched.
+func MigrateTeamLabels(c client.Client, dryRun bool) (*Report, error) {
+	report := &Report{DryRun: dryRun}
+
+	nsList := &corev1.NamespaceList{}
+	if err := c.List(context.TODO(), nsList); err != nil {
+		return nil, err
+	}
+
+	for i := range nsList.Items {
+		namespace := &nsList.Items[i]
+		legacy, hasLegacy := namespace.Labels[constants.LegacyTeamLabelKey]
+		team := teamutil.TeamName(namespace)
+		if team == "" && !hasLegacy {
+			continue
+		}
+		if team == "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(legacy)
+			if err != nil {
+				// TeamReconciler used to look up the raw team name under this label
+				decoded = []byte(legacy)
+			}
+			team = string(decoded)
+		}
+
+		exists, err := teamExists(c, team)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			report.skipped("namespace %s: team %q not found", namespace.Name, team)
+			continue
+		}
+
+		if !hasLegacy && teamutil.HasLabels(namespace.Labels, team) && namespace.Annotations[constants.TeamAnnotationKey] == team {
+			continue
+		}
+
+		namespace.Labels = teamutil.SetLabels(namespace.Labels, team)
+		if namespace.Annotations == nil {
+			namespace.Annotations = make(map[string]string)
+		}
+		namespace.Annotations[constants.TeamAnnotationKey] = team
+		if !dryRun {
+			if err := c.Update(context.TODO(), namespace); err != nil {
+				report.skipped("namespace %s: %s", namespace.Name, err)
+				continue
+			}
+		}
+		report.changed("namespace %s: labelled for team %q", namespace.Name, team)
+	}
+
+	return report, nil
+}
+
+func teamExists(c client.Client, name string) (bool, error) {
+	if name == "" {
+		return false, nil
+	}
+	err := c.Get(context.TODO(), types.NamespacedName{Name: name}, &tenantv1alpha1.Team{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
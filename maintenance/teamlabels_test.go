@@ -0,0 +1,90 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenance
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	return scheme
+}
+
+func TestMigrateTeamLabels(t *testing.T) {
+	objects := []runtime.Object{
+		&tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "base64",
+			Labels:      map[string]string{constants.LegacyTeamLabelKey: "bmVidWxh"},
+			Annotations: map[string]string{constants.TeamAnnotationKey: "nebula"},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "legacy-only",
+			Labels: map[string]string{constants.LegacyTeamLabelKey: "bmVidWxh"},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "unknown",
+			Labels: map[string]string{constants.LegacyTeamLabelKey: "Z2hvc3Q"},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "plain"}},
+	}
+	c := fake.NewFakeClientWithScheme(newScheme(), objects...)
+
+	report, err := MigrateTeamLabels(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changed) != 2 || len(report.Skipped) != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	expected := map[string]string{constants.TeamLabelKey: "nebula"}
+	for _, name := range []string{"base64", "legacy-only"} {
+		namespace := &corev1.Namespace{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: name}, namespace); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(namespace.Labels, expected) {
+			t.Errorf("namespace %s labels = %v, expected %v", name, namespace.Labels, expected)
+		}
+		if namespace.Annotations[constants.TeamAnnotationKey] != "nebula" {
+			t.Errorf("namespace %s annotations = %v", name, namespace.Annotations)
+		}
+	}
+
+	unknown := &corev1.Namespace{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "unknown"}, unknown); err != nil {
+		t.Fatal(err)
+	}
+	if unknown.Labels[constants.LegacyTeamLabelKey] != "Z2hvc3Q" {
+		t.Errorf("unresolved namespace was relabelled: %v", unknown.Labels)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenance
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/sliceutil"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// UninstallOptions configures Uninstall.
+type UninstallOptions struct {
+	DryRun bool
+	// DeleteGenerated also deletes the ClusterRoles, ClusterRoleBindings, Roles and RoleBindings
+	// generated by the controllers
+	DeleteGenerated bool
+}
+
+// Uninstall strips the controller finalizers from Teams and namespaces so they can be deleted once the
+// manager is gone, and optionally deletes the generated RBAC objects. The manager must be stopped first,
+// otherwise it adds the finalizers back.
+func Uninstall(c client.Client, options UninstallOptions) (*Report, error) {
+	report := &Report{DryRun: options.DryRun}
+
+	teamList := &tenantv1alpha1.TeamList{}
+	if err := c.List(context.TODO(), teamList); err != nil {
+		return nil, err
+	}
+	for i := range teamList.Items {
+		team := &teamList.Items[i]
+		if err := removeFinalizer(c, team, constants.TeamFinalizer, "team "+team.Name, report); err != nil {
+			return report, err
+		}
+	}
+
+	nsList := &corev1.NamespaceList{}
+	if err := c.List(context.TODO(), nsList); err != nil {
+		return nil, err
+	}
+	for i := range nsList.Items {
+		namespace := &nsList.Items[i]
+		if err := removeFinalizer(c, namespace, constants.NamespaceFinalizer, "namespace "+namespace.Name, report); err != nil {
+			return report, err
+		}
+	}
+
+	if !options.DeleteGenerated {
+		return report, nil
+	}
+
+	clusterRoles := &rbac.ClusterRoleList{}
+	if err := c.List(context.TODO(), clusterRoles); err != nil {
+		return nil, err
+	}
+	for i := range clusterRoles.Items {
+		role := &clusterRoles.Items[i]
+		if isTeamGenerated(role) {
+			if err := deleteGenerated(c, role, "clusterrole "+role.Name, report); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	clusterRoleBindings := &rbac.ClusterRoleBindingList{}
+	if err := c.List(context.TODO(), clusterRoleBindings); err != nil {
+		return nil, err
+	}
+	for i := range clusterRoleBindings.Items {
+		binding := &clusterRoleBindings.Items[i]
+		if isTeamGenerated(binding) {
+			if err := deleteGenerated(c, binding, "clusterrolebinding "+binding.Name, report); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	roles := &rbac.RoleList{}
+	if err := c.List(context.TODO(), roles, client.MatchingLabels{constants.ResourceLabel: constants.ResourceRole}); err != nil {
+		return nil, err
+	}
+	for i := range roles.Items {
+		role := &roles.Items[i]
+		if isNamespaceGenerated(role) {
+			if err := deleteGenerated(c, role, "role "+role.Namespace+"/"+role.Name, report); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	roleBindings := &rbac.RoleBindingList{}
+	if err := c.List(context.TODO(), roleBindings, client.MatchingLabels{constants.ResourceLabel: constants.ResourceRoleBinding}); err != nil {
+		return nil, err
+	}
+	for i := range roleBindings.Items {
+		binding := &roleBindings.Items[i]
+		if isNamespaceGenerated(binding) {
+			if err := deleteGenerated(c, binding, "rolebinding "+binding.Namespace+"/"+binding.Name, report); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, nil
+}
+
+// isTeamGenerated reports whether the cluster scoped object was generated by the team or team role controller,
+// which label it for its team and make the Team or TeamRole its controller
+func isTeamGenerated(obj metav1.Object) bool {
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil || (owner.Kind != "Team" && owner.Kind != "TeamRole") || owner.APIVersion != tenantv1alpha1.GroupVersion.String() {
+		return false
+	}
+	for _, key := range teamutil.TeamLabelKeys {
+		if _, ok := obj.GetLabels()[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isNamespaceGenerated reports whether the Role or RoleBinding was generated by the namespace controller,
+// either for the built-in roles or for a TeamRole
+func isNamespaceGenerated(obj metav1.Object) bool {
+	if _, ok := obj.GetLabels()[constants.TeamRoleLabelKey]; ok {
+		return true
+	}
+	return obj.GetAnnotations()[constants.CreatorAnnotationKey] == constants.System
+}
+
+// object is an API object with metadata, such as a Team or a Namespace
+type object interface {
+	runtime.Object
+	metav1.Object
+}
+
+func removeFinalizer(c client.Client, obj object, finalizer, description string, report *Report) error {
+	if !sliceutil.HasString(obj.GetFinalizers(), finalizer) {
+		return nil
+	}
+	report.changed("%s: remove finalizer %s", description, finalizer)
+	if report.DryRun {
+		return nil
+	}
+	obj.SetFinalizers(sliceutil.RemoveString(obj.GetFinalizers(), func(item string) bool {
+		return item == finalizer
+	}))
+	return c.Update(context.TODO(), obj)
+}
+
+func deleteGenerated(c client.Client, obj runtime.Object, description string, report *Report) error {
+	report.changed("%s: delete", description)
+	if report.DryRun {
+		return nil
+	}
+	if err := c.Delete(context.TODO(), obj); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenance
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestUninstall(t *testing.T) {
+	controller := true
+	owner := []metav1.OwnerReference{{
+		APIVersion: tenantv1alpha1.GroupVersion.String(),
+		Kind:       "Team",
+		Name:       "nebula",
+		UID:        "uid-nebula",
+		Controller: &controller,
+	}}
+	generated := map[string]string{constants.CreatorAnnotationKey: constants.System}
+	objects := []runtime.Object{
+		&tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula", Finalizers: []string{constants.TeamFinalizer}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dev", Finalizers: []string{constants.NamespaceFinalizer}}},
+		&rbac.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "team:nebula:admin", Labels: teamutil.Labels("nebula"), OwnerReferences: owner}},
+		&rbac.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"}},
+		&rbac.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "team:nebula:admin", Labels: teamutil.Labels("nebula"), OwnerReferences: owner}},
+		&rbac.Role{ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "dev",
+			Labels: map[string]string{constants.ResourceLabel: constants.ResourceRole}, Annotations: generated}},
+		&rbac.Role{ObjectMeta: metav1.ObjectMeta{Name: "custom", Namespace: "dev"}},
+		&rbac.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "dev",
+			Labels: map[string]string{constants.ResourceLabel: constants.ResourceRoleBinding}, Annotations: generated}},
+	}
+
+	report, err := Uninstall(fake.NewFakeClientWithScheme(newScheme(), objects...), UninstallOptions{DryRun: true, DeleteGenerated: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changed) != 6 {
+		t.Errorf("dry run report = %v, want 6 changes", report.Changed)
+	}
+
+	c := fake.NewFakeClientWithScheme(newScheme(), objects...)
+	if _, err := Uninstall(c, UninstallOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	team := &tenantv1alpha1.Team{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "nebula"}, team); err != nil || len(team.Finalizers) != 0 {
+		t.Errorf("team finalizers = %v, %v", team.Finalizers, err)
+	}
+	namespace := &corev1.Namespace{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "dev"}, namespace); err != nil || len(namespace.Finalizers) != 0 {
+		t.Errorf("namespace finalizers = %v, %v", namespace.Finalizers, err)
+	}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "team:nebula:admin"}, &rbac.ClusterRole{}); err != nil {
+		t.Errorf("generated cluster role deleted without DeleteGenerated: %v", err)
+	}
+
+	if _, err := Uninstall(c, UninstallOptions{DeleteGenerated: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		key     types.NamespacedName
+		obj     runtime.Object
+		deleted bool
+	}{
+		{types.NamespacedName{Name: "team:nebula:admin"}, &rbac.ClusterRole{}, true},
+		{types.NamespacedName{Name: "cluster-admin"}, &rbac.ClusterRole{}, false},
+		{types.NamespacedName{Name: "team:nebula:admin"}, &rbac.ClusterRoleBinding{}, true},
+		{types.NamespacedName{Namespace: "dev", Name: "admin"}, &rbac.Role{}, true},
+		{types.NamespacedName{Namespace: "dev", Name: "custom"}, &rbac.Role{}, false},
+		{types.NamespacedName{Namespace: "dev", Name: "admin"}, &rbac.RoleBinding{}, true},
+	} {
+		err := c.Get(context.TODO(), tc.key, tc.obj)
+		if deleted := errors.IsNotFound(err); deleted != tc.deleted {
+			t.Errorf("%T %s deleted = %v, want %v", tc.obj, tc.key, deleted, tc.deleted)
+		}
+	}
+}
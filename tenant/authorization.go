@@ -0,0 +1,77 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Authorizer decides whether user may act on the resource described by attributes
+type Authorizer interface {
+	Authorize(ctx context.Context, user User, attributes authorizationv1.ResourceAttributes) (allowed bool, reason string, err error)
+}
+
+// SubjectAccessReviewer authorizes with SubjectAccessReviews, so the RBAC roles generated for teams
+// and namespaces decide what users may do through the KubeNebula APIs
+type SubjectAccessReviewer struct {
+	Client client.Client
+}
+
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// Authorize implements Authorizer.
+func (a *SubjectAccessReviewer) Authorize(ctx context.Context, user User, attributes authorizationv1.ResourceAttributes) (bool, string, error) {
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:               user.Name,
+			Groups:             user.Groups,
+			ResourceAttributes: &attributes,
+		},
+	}
+	if err := a.Client.Create(ctx, review); err != nil {
+		return false, "", err
+	}
+	return review.Status.Allowed, review.Status.Reason, nil
+}
+
+// TeamAttributes returns the attributes of verb on a subresource of team in tenant.kubenebula.io,
+// an empty subresource is the team itself and an empty team the collection of teams.
+func TeamAttributes(verb, team, subresource string) authorizationv1.ResourceAttributes {
+	return authorizationv1.ResourceAttributes{
+		Verb:        verb,
+		Group:       tenantv1alpha1.GroupVersion.Group,
+		Version:     tenantv1alpha1.GroupVersion.Version,
+		Resource:    "teams",
+		Subresource: subresource,
+		Name:        team,
+	}
+}
+
+// NamespaceAttributes returns the attributes of verb on namespace, granted by the roles bound in the namespace.
+func NamespaceAttributes(verb, namespace string) authorizationv1.ResourceAttributes {
+	return authorizationv1.ResourceAttributes{
+		Verb:      verb,
+		Version:   "v1",
+		Resource:  "namespaces",
+		Namespace: namespace,
+		Name:      namespace,
+	}
+}
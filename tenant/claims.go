@@ -0,0 +1,55 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"encoding/json"
+
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+)
+
+// ClaimRequester returns the requester recorded on a NamespaceClaim by the admission webhook,
+// ok is false when the annotations are missing or malformed
+func ClaimRequester(claim *tenantv1alpha1.NamespaceClaim) (user User, ok bool) {
+	name := claim.Annotations[constants.RequesterAnnotationKey]
+	if name == "" {
+		return User{}, false
+	}
+	var groups []string
+	if raw, found := claim.Annotations[constants.RequesterGroupsAnnotationKey]; found {
+		if err := json.Unmarshal([]byte(raw), &groups); err != nil {
+			return User{}, false
+		}
+	}
+	return User{Name: name, Groups: groups}, true
+}
+
+// SetClaimRequester records user as the requester of a NamespaceClaim
+func SetClaimRequester(claim *tenantv1alpha1.NamespaceClaim, user User) {
+	if claim.Annotations == nil {
+		claim.Annotations = make(map[string]string)
+	}
+	groups := user.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+	// marshaling a slice of strings can not fail
+	data, _ := json.Marshal(groups)
+	claim.Annotations[constants.RequesterAnnotationKey] = user.Name
+	claim.Annotations[constants.RequesterGroupsAnnotationKey] = string(data)
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+	"fmt"
+
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/util/retry"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/utils/teamutil"
+)
+
+// MemberRoles are the built-in team roles members can be added to
+var MemberRoles = []string{tenantv1alpha1.TeamAdminTemplate, tenantv1alpha1.TeamRegularTemplate, tenantv1alpha1.TeamViewerTemplate}
+
+// Member is a subject holding a built-in team role
+type Member struct {
+	// Role is admin, regular or viewer.
+	Role         string `json:"role"`
+	rbac.Subject `json:",inline"`
+}
+
+// members returns the member list of the team holding role, nil for unknown roles
+func members(team *tenantv1alpha1.Team, role string) *[]rbac.Subject {
+	switch role {
+	case tenantv1alpha1.TeamAdminTemplate:
+		return &team.Spec.Admins
+	case tenantv1alpha1.TeamRegularTemplate:
+		return &team.Spec.Regulars
+	case tenantv1alpha1.TeamViewerTemplate:
+		return &team.Spec.Viewers
+	}
+	return nil
+}
+
+// ListMembers returns the members of the team by role, the manager is the first admin.
+// Members inherited from ancestors are not included.
+func (s *Service) ListMembers(ctx context.Context, teamName string) ([]Member, error) {
+	team, err := s.GetTeam(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	var result []Member
+	for _, role := range MemberRoles {
+		subjects := *members(team, role)
+		if role == tenantv1alpha1.TeamAdminTemplate {
+			subjects = teamutil.Admins(team)
+		}
+		for _, subject := range teamutil.Subjects(subjects) {
+			result = append(result, Member{Role: role, Subject: subject})
+		}
+	}
+	return result, nil
+}
+
+// AddMember gives the subject of member the role of member in the team, adding an existing member does nothing.
+func (s *Service) AddMember(ctx context.Context, teamName string, member Member) (Member, error) {
+	subject, err := validateMember(teamName, member)
+	if err != nil {
+		return Member{}, err
+	}
+	member.Subject = subject
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		team, err := s.GetTeam(ctx, teamName)
+		if err != nil {
+			return err
+		}
+		list := members(team, member.Role)
+		if teamutil.HasSubject(teamutil.Subjects(*list), subject) {
+			return nil
+		}
+		*list = append(*list, subject)
+		return s.Update(ctx, team)
+	})
+	return member, err
+}
+
+// RemoveMember takes the role of member away from its subject. The manager can only be replaced through the team.
+func (s *Service) RemoveMember(ctx context.Context, teamName string, member Member) error {
+	subject, err := validateMember(teamName, member)
+	if err != nil {
+		return err
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		team, err := s.GetTeam(ctx, teamName)
+		if err != nil {
+			return err
+		}
+		if member.Role == tenantv1alpha1.TeamAdminTemplate && subject.Kind == rbac.UserKind && subject.Name == team.Spec.Manager {
+			return errors.NewForbidden(teamsResource, teamName, fmt.Errorf("the manager %s can not be removed, change spec.manager instead", subject.Name))
+		}
+		list := members(team, member.Role)
+		remaining := make([]rbac.Subject, 0, len(*list))
+		for _, existing := range *list {
+			if normalized := teamutil.Subjects([]rbac.Subject{existing}); len(normalized) == 1 && normalized[0] == subject {
+				continue
+			}
+			remaining = append(remaining, existing)
+		}
+		if len(remaining) == len(*list) {
+			return errors.NewNotFound(membersResource, fmt.Sprintf("%s:%s", member.Role, subject.Name))
+		}
+		*list = remaining
+		return s.Update(ctx, team)
+	})
+}
+
+// validateMember checks the role of member and returns its normalized subject
+func validateMember(teamName string, member Member) (rbac.Subject, error) {
+	var errs field.ErrorList
+	if members(&tenantv1alpha1.Team{}, member.Role) == nil {
+		errs = append(errs, field.NotSupported(field.NewPath("role"), member.Role, MemberRoles))
+	}
+	subjects := teamutil.Subjects([]rbac.Subject{member.Subject})
+	if len(subjects) == 0 {
+		errs = append(errs, field.Invalid(field.NewPath("kind"), member.Kind, "must be a User, Group or ServiceAccount with a name"))
+	}
+	if len(errs) > 0 {
+		return rbac.Subject{}, errors.NewInvalid(tenantv1alpha1.GroupVersion.WithKind("Team").GroupKind(), teamName, errs)
+	}
+	return subjects[0], nil
+}
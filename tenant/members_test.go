@@ -0,0 +1,85 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+)
+
+func TestMembers(t *testing.T) {
+	s := newTestService(&tenantv1alpha1.Team{
+		ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+		Spec: tenantv1alpha1.TeamSpec{
+			Manager:  "alice",
+			Regulars: []rbac.Subject{{Kind: rbac.UserKind, Name: "bob"}},
+		},
+	})
+	ctx := context.TODO()
+	user := func(name string) rbac.Subject {
+		return rbac.Subject{APIGroup: rbac.GroupName, Kind: rbac.UserKind, Name: name}
+	}
+
+	if _, err := s.AddMember(ctx, "nebula", Member{Role: "owner", Subject: user("carol")}); !errors.IsInvalid(err) {
+		t.Errorf("AddMember() with unknown role = %v, expected invalid", err)
+	}
+	added, err := s.AddMember(ctx, "nebula", Member{Role: tenantv1alpha1.TeamViewerTemplate, Subject: rbac.Subject{Kind: rbac.GroupKind, Name: "auditors"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.APIGroup != rbac.GroupName {
+		t.Errorf("AddMember() = %v, expected a normalized subject", added)
+	}
+	if _, err := s.AddMember(ctx, "nebula", Member{Role: tenantv1alpha1.TeamRegularTemplate, Subject: user("bob")}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Member{
+		{Role: tenantv1alpha1.TeamAdminTemplate, Subject: user("alice")},
+		{Role: tenantv1alpha1.TeamRegularTemplate, Subject: user("bob")},
+		{Role: tenantv1alpha1.TeamViewerTemplate, Subject: rbac.Subject{APIGroup: rbac.GroupName, Kind: rbac.GroupKind, Name: "auditors"}},
+	}
+	members, err := s.ListMembers(ctx, "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(members, expected) {
+		t.Errorf("ListMembers() = %v, expected %v", members, expected)
+	}
+
+	if err := s.RemoveMember(ctx, "nebula", Member{Role: tenantv1alpha1.TeamAdminTemplate, Subject: user("alice")}); !errors.IsForbidden(err) {
+		t.Errorf("RemoveMember() of the manager = %v, expected forbidden", err)
+	}
+	if err := s.RemoveMember(ctx, "nebula", Member{Role: tenantv1alpha1.TeamViewerTemplate, Subject: user("bob")}); !errors.IsNotFound(err) {
+		t.Errorf("RemoveMember() of a missing member = %v, expected not found", err)
+	}
+	if err := s.RemoveMember(ctx, "nebula", Member{Role: tenantv1alpha1.TeamRegularTemplate, Subject: rbac.Subject{Kind: rbac.UserKind, Name: "bob"}}); err != nil {
+		t.Fatal(err)
+	}
+	if members, _ = s.ListMembers(ctx, "nebula"); len(members) != 2 {
+		t.Errorf("ListMembers() after removal = %v", members)
+	}
+	if _, err := s.ListMembers(ctx, "missing"); !errors.IsNotFound(err) {
+		t.Errorf("ListMembers() of a missing team = %v, expected not found", err)
+	}
+}
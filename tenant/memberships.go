@@ -0,0 +1,90 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+	"sort"
+
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/utils/teamutil"
+)
+
+// Membership is a team the user belongs to and the roles held in it
+type Membership struct {
+	Team  string   `json:"team"`
+	Roles []string `json:"roles"`
+}
+
+// Roles returns the roles user holds in team: the built-in admin, regular and viewer roles, with admin and viewer
+// inherited from the ancestors, followed by the role names of the TeamRoles of the team listing user as a member.
+func Roles(team *tenantv1alpha1.Team, ancestors []tenantv1alpha1.Team, teamRoles []tenantv1alpha1.TeamRole, user User) []string {
+	var roles []string
+	if teamutil.HasUser(teamutil.InheritedAdmins(team, ancestors), user.Name, user.Groups) {
+		roles = append(roles, tenantv1alpha1.TeamAdminTemplate)
+	}
+	if teamutil.HasUser(team.Spec.Regulars, user.Name, user.Groups) {
+		roles = append(roles, tenantv1alpha1.TeamRegularTemplate)
+	}
+	if teamutil.HasUser(teamutil.InheritedViewers(team, ancestors), user.Name, user.Groups) {
+		roles = append(roles, tenantv1alpha1.TeamViewerTemplate)
+	}
+	for i := range teamRoles {
+		if teamRoles[i].Spec.Team == team.Name && teamutil.HasUser(teamRoles[i].Spec.Members, user.Name, user.Groups) {
+			roles = append(roles, teamRoles[i].GetRoleName())
+		}
+	}
+	return roles
+}
+
+// Memberships returns the teams user holds a role in, sorted by team name
+func (s *Service) Memberships(ctx context.Context, user User) ([]Membership, error) {
+	teams := &tenantv1alpha1.TeamList{}
+	if err := s.List(ctx, teams); err != nil {
+		return nil, err
+	}
+	teamRoles := &tenantv1alpha1.TeamRoleList{}
+	if err := s.List(ctx, teamRoles); err != nil {
+		return nil, err
+	}
+
+	var memberships []Membership
+	for i := range teams.Items {
+		team := &teams.Items[i]
+		ancestors, err := teamutil.InheritedAncestors(s, team)
+		if err != nil {
+			return nil, err
+		}
+		if roles := Roles(team, ancestors, teamRoles.Items, user); len(roles) > 0 {
+			memberships = append(memberships, Membership{Team: team.Name, Roles: roles})
+		}
+	}
+	sort.Slice(memberships, func(i, j int) bool { return memberships[i].Team < memberships[j].Team })
+	return memberships, nil
+}
+
+// Groups returns the kubenebula:team:<team>:<role> groups of the roles held in memberships, the groups
+// are bound to the roles besides the members.
+func Groups(memberships []Membership) []string {
+	var groups []string
+	for _, membership := range memberships {
+		for _, role := range membership.Roles {
+			groups = append(groups, teamutil.GroupName(membership.Team, role))
+		}
+	}
+	return groups
+}
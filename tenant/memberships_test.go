@@ -0,0 +1,88 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	rbac "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+)
+
+func TestMemberships(t *testing.T) {
+	s := newTestService(
+		&tenantv1alpha1.Team{
+			ObjectMeta: metav1.ObjectMeta{Name: "platform"},
+			Spec: tenantv1alpha1.TeamSpec{
+				Manager: "carol",
+				Viewers: []rbac.Subject{{Kind: rbac.GroupKind, Name: "auditors"}},
+			},
+		},
+		&tenantv1alpha1.Team{
+			ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+			Spec: tenantv1alpha1.TeamSpec{
+				Manager:  "alice",
+				Parent:   "platform",
+				Regulars: []rbac.Subject{{Kind: rbac.GroupKind, Name: "nebula-devs"}},
+			},
+		},
+		&tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "other"}, Spec: tenantv1alpha1.TeamSpec{Manager: "dave"}},
+		&tenantv1alpha1.TeamRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "nebula-deployer"},
+			Spec: tenantv1alpha1.TeamRoleSpec{
+				Team:     "nebula",
+				RoleName: "deployer",
+				Members:  []rbac.Subject{{Kind: rbac.UserKind, Name: "bob"}},
+			},
+		},
+	)
+
+	tests := []struct {
+		user     User
+		expected []Membership
+	}{
+		{User{Name: "carol"}, []Membership{{Team: "nebula", Roles: []string{"admin"}}, {Team: "platform", Roles: []string{"admin"}}}},
+		{User{Name: "bob", Groups: []string{"nebula-devs", "auditors"}}, []Membership{
+			{Team: "nebula", Roles: []string{"regular", "viewer", "deployer"}},
+			{Team: "platform", Roles: []string{"viewer"}},
+		}},
+		{User{Name: "eve"}, nil},
+	}
+	for _, test := range tests {
+		memberships, err := s.Memberships(context.TODO(), test.user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(memberships, test.expected) {
+			t.Errorf("Memberships(%v) = %v, expected %v", test.user, memberships, test.expected)
+		}
+	}
+}
+
+func TestGroups(t *testing.T) {
+	groups := Groups([]Membership{
+		{Team: "nebula", Roles: []string{"regular", "deployer"}},
+		{Team: "platform", Roles: []string{"viewer"}},
+	})
+	expected := []string{"kubenebula:team:nebula:regular", "kubenebula:team:nebula:deployer", "kubenebula:team:platform:viewer"}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Groups() = %v, expected %v", groups, expected)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/quotautil"
+	"kubenebula.io/kubenebula/utils/sliceutil"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reservedNamespacePrefix is the prefix of the namespaces reserved for Kubernetes
+const reservedNamespacePrefix = "kube-"
+
+// ValidateNamespaceName returns why name can not be used for a team namespace:
+// it must be a DNS label that is not reserved for the system.
+func ValidateNamespaceName(name string) []string {
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return errs
+	}
+	if strings.HasPrefix(name, reservedNamespacePrefix) || sliceutil.HasString(constants.SystemNamespaces, name) {
+		return []string{"the name is reserved for the system"}
+	}
+	return nil
+}
+
+// NewNamespace returns the namespace name of team, labelled for the team and annotated with its creator.
+func NewNamespace(name, team, creator string) *corev1.Namespace {
+	namespace := &corev1.Namespace{}
+	namespace.Name = name
+	namespace.Labels = teamutil.Labels(team)
+	namespace.Annotations = map[string]string{
+		constants.TeamAnnotationKey:    team,
+		constants.CreatorAnnotationKey: creator,
+	}
+	return namespace
+}
+
+// CheckNamespaceLimit returns a Forbidden error when the quota of the team does not allow another namespace.
+func CheckNamespaceLimit(ctx context.Context, c client.Client, team *tenantv1alpha1.Team) error {
+	if team.Spec.Quota == nil {
+		return nil
+	}
+	limit, ok := team.Spec.Quota.Hard[tenantv1alpha1.ResourceNamespaces]
+	if !ok {
+		return nil
+	}
+	nsList := &corev1.NamespaceList{}
+	if err := c.List(ctx, nsList, client.MatchingLabelsSelector{Selector: teamutil.Selector(team.Name)}); err != nil {
+		return err
+	}
+	hard := corev1.ResourceList{tenantv1alpha1.ResourceNamespaces: limit}
+	used := corev1.ResourceList{tenantv1alpha1.ResourceNamespaces: *resource.NewQuantity(int64(len(nsList.Items)), resource.DecimalSI)}
+	request := corev1.ResourceList{tenantv1alpha1.ResourceNamespaces: *resource.NewQuantity(1, resource.DecimalSI)}
+	if len(quotautil.Exceeded(hard, used, request)) > 0 {
+		return errors.NewForbidden(teamsResource, team.Name,
+			fmt.Errorf("exceeded quota, the team already has %d namespaces, limited to %s", len(nsList.Items), &limit))
+	}
+	return nil
+}
+
+// ListNamespaces returns the namespaces of the team sorted by name.
+func (s *Service) ListNamespaces(ctx context.Context, teamName string) ([]corev1.Namespace, error) {
+	if _, err := s.GetTeam(ctx, teamName); err != nil {
+		return nil, err
+	}
+	nsList := &corev1.NamespaceList{}
+	if err := s.List(ctx, nsList, client.MatchingLabelsSelector{Selector: teamutil.Selector(teamName)}); err != nil {
+		return nil, err
+	}
+	sort.Slice(nsList.Items, func(i, j int) bool { return nsList.Items[i].Name < nsList.Items[j].Name })
+	return nsList.Items, nil
+}
+
+// CreateNamespace creates namespace for the team on behalf of creator. The name must pass ValidateNamespaceName,
+// the quota preset annotation must name an existing QuotaPreset and the team quota must allow another namespace.
+// The team labels and the team and creator annotations are always set, other labels and annotations are kept.
+func (s *Service) CreateNamespace(ctx context.Context, teamName string, namespace *corev1.Namespace, creator string) (*corev1.Namespace, error) {
+	if errs := ValidateNamespaceName(namespace.Name); len(errs) > 0 {
+		return nil, errors.NewInvalid(corev1.SchemeGroupVersion.WithKind("Namespace").GroupKind(), namespace.Name, field.ErrorList{
+			field.Invalid(field.NewPath("metadata", "name"), namespace.Name, strings.Join(errs, ", ")),
+		})
+	}
+	team, err := s.GetTeam(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	if !team.DeletionTimestamp.IsZero() {
+		return nil, errors.NewConflict(teamsResource, teamName, fmt.Errorf("the team is being deleted"))
+	}
+	if presetName := namespace.Annotations[constants.QuotaPresetAnnotationKey]; presetName != "" {
+		preset := &tenantv1alpha1.QuotaPreset{}
+		if err := s.Get(ctx, types.NamespacedName{Name: presetName}, preset); err != nil {
+			if !errors.IsNotFound(err) {
+				return nil, err
+			}
+			return nil, errors.NewInvalid(corev1.SchemeGroupVersion.WithKind("Namespace").GroupKind(), namespace.Name, field.ErrorList{
+				field.NotFound(field.NewPath("metadata", "annotations").Key(constants.QuotaPresetAnnotationKey), presetName),
+			})
+		}
+	}
+	if err := CheckNamespaceLimit(ctx, s, team); err != nil {
+		return nil, err
+	}
+
+	created := NewNamespace(namespace.Name, team.Name, creator)
+	for key, value := range namespace.Annotations {
+		if _, ok := created.Annotations[key]; !ok {
+			created.Annotations[key] = value
+		}
+	}
+	created.Labels = teamutil.SetLabels(copyStrings(namespace.Labels), team.Name)
+	if err := s.Create(ctx, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func copyStrings(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in))
+	for key, value := range in {
+		out[key] = value
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/utils/teamutil"
+)
+
+func TestValidateNamespaceName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"nebula-dev":  true,
+		"Nebula":      false,
+		"nebula.dev":  false,
+		"kube-nebula": false,
+		"kube-system": false,
+		"default":     true,
+	} {
+		if errs := ValidateNamespaceName(name); (len(errs) == 0) != valid {
+			t.Errorf("ValidateNamespaceName(%s) = %v, expected valid %v", name, errs, valid)
+		}
+	}
+}
+
+func TestCreateNamespace(t *testing.T) {
+	team := &tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}}
+	limited := team.DeepCopy()
+	limited.Spec.Quota = &tenantv1alpha1.TeamQuota{Hard: corev1.ResourceList{tenantv1alpha1.ResourceNamespaces: resource.MustParse("1")}}
+	existing := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-prod", Labels: teamutil.Labels("nebula")}}
+	preset := &tenantv1alpha1.QuotaPreset{ObjectMeta: metav1.ObjectMeta{Name: "small"}}
+	newNamespace := func(name, preset string) *corev1.Namespace {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{constants.TeamLabelKey: "other", "env": "dev"},
+			Annotations: map[string]string{constants.CreatorAnnotationKey: "mallory", constants.DescriptionAnnotationKey: "development"},
+		}}
+		if preset != "" {
+			namespace.Annotations[constants.QuotaPresetAnnotationKey] = preset
+		}
+		return namespace
+	}
+
+	tests := []struct {
+		name      string
+		objects   []runtime.Object
+		namespace *corev1.Namespace
+		check     func(error) bool
+	}{
+		{"created", []runtime.Object{team, existing, preset}, newNamespace("nebula-dev", "small"), nil},
+		{"invalid name", []runtime.Object{team}, newNamespace("kube-nebula", ""), errors.IsInvalid},
+		{"missing team", nil, newNamespace("nebula-dev", ""), errors.IsNotFound},
+		{"missing preset", []runtime.Object{team}, newNamespace("nebula-dev", "huge"), errors.IsInvalid},
+		{"namespace limit", []runtime.Object{limited, existing}, newNamespace("nebula-dev", ""), errors.IsForbidden},
+		{"already exists", []runtime.Object{team, existing}, newNamespace("nebula-prod", ""), errors.IsAlreadyExists},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestService(test.objects...)
+			created, err := s.CreateNamespace(context.TODO(), "nebula", test.namespace, "alice")
+			if test.check != nil {
+				if !test.check(err) {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !teamutil.HasLabels(created.Labels, "nebula") || created.Labels["env"] != "dev" {
+				t.Errorf("labels = %v", created.Labels)
+			}
+			if created.Annotations[constants.TeamAnnotationKey] != "nebula" ||
+				created.Annotations[constants.CreatorAnnotationKey] != "alice" ||
+				created.Annotations[constants.DescriptionAnnotationKey] != "development" {
+				t.Errorf("annotations = %v", created.Annotations)
+			}
+
+			namespaces, err := s.ListNamespaces(context.TODO(), "nebula")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(namespaces) != 2 || namespaces[0].Name != "nebula-dev" || namespaces[1].Name != "nebula-prod" {
+				t.Errorf("ListNamespaces() = %v", namespaces)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tenant implements the team operations served by the KubeNebula APIs.
+// Callers authenticate and authorize the requests, the service enforces the rules of the tenancy model
+// and reports failures as Kubernetes API status errors.
+package tenant
+
+import (
+	"context"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/types"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	teamsResource   = tenantv1alpha1.GroupVersion.WithResource("teams").GroupResource()
+	membersResource = tenantv1alpha1.GroupVersion.WithResource("teams/members").GroupResource()
+)
+
+// User is an authenticated user
+type User struct {
+	Name   string
+	Groups []string
+}
+
+// Service implements the team operations on top of a client, usually the cached client of the manager
+type Service struct {
+	client.Client
+}
+
+// GetTeam returns the team with the given name.
+func (s *Service) GetTeam(ctx context.Context, name string) (*tenantv1alpha1.Team, error) {
+	team := &tenantv1alpha1.Team{}
+	if err := s.Get(ctx, types.NamespacedName{Name: name}, team); err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+// ListTeams returns all teams sorted by name.
+func (s *Service) ListTeams(ctx context.Context) ([]tenantv1alpha1.Team, error) {
+	teams := &tenantv1alpha1.TeamList{}
+	if err := s.List(ctx, teams); err != nil {
+		return nil, err
+	}
+	sort.Slice(teams.Items, func(i, j int) bool { return teams.Items[i].Name < teams.Items[j].Name })
+	return teams.Items, nil
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"reflect"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("users")
+
+// AdminClusterRoleBindingName is the name of the ClusterRoleBinding of the admin user to cluster-admin
+const AdminClusterRoleBindingName = "kubenebula:admin"
+
+const (
+	// bootstrapRetryPeriod is the delay before retrying a failed bootstrap, doubled after each failure
+	bootstrapRetryPeriod = time.Second
+	// maxBootstrapRetryPeriod caps the delay between retries
+	maxBootstrapRetryPeriod = 5 * time.Minute
+)
+
+// Bootstrap is a manager Runnable creating the admin user, its password and its binding to cluster-admin when
+// they do not exist. The generated password is kept in the initialPassword key of the password Secret until
+// the password is set.
+type Bootstrap struct {
+	// Client creates the objects.
+	Client client.Client
+	// Reader reads the objects, usually the API reader of the manager since the cache does not hold Secrets.
+	Reader client.Reader
+}
+
+// Start bootstraps the admin user once, retrying until it succeeds or stop is closed. Failures are logged
+// instead of returned, so a missing kubenebula-system namespace or an unavailable API server does not
+// stop the manager.
+func (b *Bootstrap) Start(stop <-chan struct{}) error {
+	delay := bootstrapRetryPeriod
+	for {
+		err := b.Run(context.Background())
+		if err == nil {
+			return nil
+		}
+		log.Error(err, "unable to bootstrap the admin user, retrying", "after", delay.String())
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxBootstrapRetryPeriod {
+			delay = maxBootstrapRetryPeriod
+		}
+	}
+}
+
+// Run creates the missing admin user objects.
+func (b *Bootstrap) Run(ctx context.Context) error {
+	user := &tenantv1alpha1.User{}
+	if err := b.Reader.Get(ctx, types.NamespacedName{Name: constants.AdminUserName}, user); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		user.Name = constants.AdminUserName
+		user.Spec.DisplayName = "Administrator"
+		if err := b.Client.Create(ctx, user); err != nil {
+			return err
+		}
+		log.Info("Created the admin user", "user", user.Name)
+	}
+
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: constants.KubeNebulaNamespace, Name: PasswordSecretName(user.Name)}
+	if err := b.Reader.Get(ctx, key, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		password, err := generatePassword()
+		if err != nil {
+			return err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		secret = newPasswordSecret(user, map[string][]byte{PasswordKey: hash, InitialPasswordKey: []byte(password)})
+		if err := b.Client.Create(ctx, secret); err != nil {
+			return err
+		}
+		log.Info("Generated the initial password of the admin user", "secret", key.String(), "key", InitialPasswordKey)
+	}
+
+	return b.bindAdmin(ctx, user)
+}
+
+// bindAdmin binds the admin user to cluster-admin. A binding to another role is recreated since the role
+// of a binding can not be changed, and a binding to any other subject, such as the unprefixed admin name,
+// is reset since it grants cluster-admin to whoever the name belongs to.
+func (b *Bootstrap) bindAdmin(ctx context.Context, user *tenantv1alpha1.User) error {
+	expected := &rbac.ClusterRoleBinding{}
+	expected.Name = AdminClusterRoleBindingName
+	expected.Annotations = map[string]string{constants.CreatorAnnotationKey: constants.System}
+	expected.RoleRef = rbac.RoleRef{APIGroup: rbac.GroupName, Kind: "ClusterRole", Name: constants.ClusterAdmin}
+	expected.Subjects = []rbac.Subject{{APIGroup: rbac.GroupName, Kind: rbac.UserKind, Name: Username(user.Name)}}
+
+	binding := &rbac.ClusterRoleBinding{}
+	if err := b.Reader.Get(ctx, types.NamespacedName{Name: AdminClusterRoleBindingName}, binding); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		if err := b.Client.Create(ctx, expected); err != nil {
+			return err
+		}
+		log.Info("Bound the admin user to cluster-admin", "clusterrolebinding", expected.Name)
+		return nil
+	}
+
+	if !reflect.DeepEqual(binding.RoleRef, expected.RoleRef) {
+		if err := b.Client.Delete(ctx, binding); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if err := b.Client.Create(ctx, expected); err != nil {
+			return err
+		}
+		log.Info("Recreated the admin binding bound to another role", "clusterrolebinding", expected.Name, "role", binding.RoleRef.Name)
+		return nil
+	}
+
+	if !reflect.DeepEqual(binding.Subjects, expected.Subjects) {
+		binding.Subjects = expected.Subjects
+		if err := b.Client.Update(ctx, binding); err != nil {
+			return err
+		}
+		log.Info("Reset the subjects of the admin binding", "clusterrolebinding", binding.Name)
+	}
+	return nil
+}
+
+// generatePassword returns a random password of 128 bits
+func generatePassword() (string, error) {
+	data := make([]byte, 16)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package users
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestBootstrap(t *testing.T) {
+	ctx := context.TODO()
+	c := newTestClient()
+	bootstrap := &Bootstrap{Client: c, Reader: c}
+	if err := bootstrap.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: constants.KubeNebulaNamespace, Name: "user-admin"}, secret); err != nil {
+		t.Fatal(err)
+	}
+	password := string(secret.Data[InitialPasswordKey])
+	if _, err := Authenticate(ctx, c, constants.AdminUserName, password); err != nil {
+		t.Errorf("initial password %q rejected: %v", password, err)
+	}
+	binding := &rbac.ClusterRoleBinding{}
+	if err := c.Get(ctx, types.NamespacedName{Name: AdminClusterRoleBindingName}, binding); err != nil {
+		t.Fatal(err)
+	}
+	if binding.RoleRef.Name != constants.ClusterAdmin || len(binding.Subjects) != 1 || binding.Subjects[0].Name != Username(constants.AdminUserName) {
+		t.Errorf("binding = %+v", binding)
+	}
+
+	// setting the password removes the initial one, which a second run does not bring back
+	admin := &tenantv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: constants.AdminUserName}}
+	if err := c.Get(ctx, types.NamespacedName{Name: admin.Name}, admin); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetPassword(ctx, c, admin, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bootstrap.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Authenticate(ctx, c, constants.AdminUserName, "correct horse"); err != nil {
+		t.Errorf("password reset by bootstrap: %v", err)
+	}
+	if _, err := Authenticate(ctx, c, constants.AdminUserName, password); err != ErrInvalidCredentials {
+		t.Errorf("initial password still accepted: %v", err)
+	}
+}
+
+func TestBootstrapBindingSubjects(t *testing.T) {
+	ctx := context.TODO()
+	// a binding to the unprefixed name would grant cluster-admin to an external user named admin
+	c := newTestClient(&rbac.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: AdminClusterRoleBindingName},
+		RoleRef:    rbac.RoleRef{APIGroup: rbac.GroupName, Kind: "ClusterRole", Name: constants.ClusterAdmin},
+		Subjects:   []rbac.Subject{{APIGroup: rbac.GroupName, Kind: rbac.UserKind, Name: constants.AdminUserName}},
+	})
+	if err := (&Bootstrap{Client: c, Reader: c}).Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	binding := &rbac.ClusterRoleBinding{}
+	if err := c.Get(ctx, types.NamespacedName{Name: AdminClusterRoleBindingName}, binding); err != nil {
+		t.Fatal(err)
+	}
+	if len(binding.Subjects) != 1 || binding.Subjects[0].Name != Username(constants.AdminUserName) {
+		t.Errorf("subjects = %+v", binding.Subjects)
+	}
+}
+
+func TestBootstrapBindingRoleRef(t *testing.T) {
+	ctx := context.TODO()
+	c := newTestClient(&rbac.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: AdminClusterRoleBindingName},
+		RoleRef:    rbac.RoleRef{APIGroup: rbac.GroupName, Kind: "ClusterRole", Name: "view"},
+		Subjects:   []rbac.Subject{{APIGroup: rbac.GroupName, Kind: rbac.UserKind, Name: Username(constants.AdminUserName)}},
+	})
+	if err := (&Bootstrap{Client: c, Reader: c}).Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	binding := &rbac.ClusterRoleBinding{}
+	if err := c.Get(ctx, types.NamespacedName{Name: AdminClusterRoleBindingName}, binding); err != nil {
+		t.Fatal(err)
+	}
+	if binding.RoleRef.Name != constants.ClusterAdmin || len(binding.Subjects) != 1 || binding.Subjects[0].Name != Username(constants.AdminUserName) {
+		t.Errorf("binding = %+v", binding)
+	}
+}
+
+func TestBootstrapStartFailure(t *testing.T) {
+	// the client does not know Users, so every run fails
+	c := fake.NewFakeClientWithScheme(runtime.NewScheme())
+	stop := make(chan struct{})
+	close(stop)
+	if err := (&Bootstrap{Client: c, Reader: c}).Start(stop); err != nil {
+		t.Errorf("Start() = %v, expected the failure to be logged", err)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package users manages the built-in user accounts: the cluster-scoped User objects and the bcrypt hashes
+// of their passwords, kept in the user-<name> Secrets of the kubenebula-system namespace.
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// PasswordKey is the key of the bcrypt hash of the password in the password Secret
+	PasswordKey = "password"
+	// InitialPasswordKey is the key of the generated password of the admin user, removed once the password is set
+	InitialPasswordKey = "initialPassword"
+
+	// MinPasswordLength is the length of the shortest password accepted
+	MinPasswordLength = 8
+	// maxPasswordLength is the length bcrypt hashes passwords up to
+	maxPasswordLength = 72
+
+	// dummyHash is compared against when the user or its password does not exist, so a failed login takes
+	// as long for unknown users as for wrong passwords
+	dummyHash = "$2a$10$1ARf7ND2CGm/WQGrYRYiae/kZGLNzF3ehI.Ihd9C1cB1zn6.yc8.."
+)
+
+// ErrInvalidCredentials is returned for unknown users, wrong passwords and disabled users alike, so logins
+// do not tell which users exist
+var ErrInvalidCredentials = errors.New("invalid user name or password")
+
+// +kubebuilder:rbac:groups=tenant.kubenebula.io,resources=users,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=tenant.kubenebula.io,resources=users/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",namespace=kubenebula-system,resources=secrets,verbs=get;create;update
+
+// Username returns the username of the built-in user name in tokens and RBAC subjects, the prefix keeps
+// external identities from colliding with the built-in users
+func Username(name string) string {
+	return constants.BuiltinUserPrefix + name
+}
+
+// Name returns the name of the User of a built-in username, ok is false for other usernames
+func Name(username string) (name string, ok bool) {
+	if !strings.HasPrefix(username, constants.BuiltinUserPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(username, constants.BuiltinUserPrefix), true
+}
+
+// PasswordSecretName returns the name of the Secret holding the password of user
+func PasswordSecretName(user string) string {
+	return "user-" + user
+}
+
+// Authenticate returns the user named name if password is its password and it is not disabled.
+// c should read from the API server, the cache of the manager does not hold Secrets.
+func Authenticate(ctx context.Context, c client.Reader, name, password string) (*tenantv1alpha1.User, error) {
+	user := &tenantv1alpha1.User{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, user); err != nil {
+		if apierrors.IsNotFound(err) {
+			_ = bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(password))
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: constants.KubeNebulaNamespace, Name: PasswordSecretName(name)}, secret)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	hash, ok := secret.Data[PasswordKey]
+	if !ok {
+		hash = []byte(dummyHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || !ok || user.Spec.Disabled {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// Disabled returns whether username is the username of a disabled User, other usernames are not disabled
+func Disabled(ctx context.Context, c client.Reader, username string) (bool, error) {
+	name, ok := Name(username)
+	if !ok {
+		return false, nil
+	}
+	user := &tenantv1alpha1.User{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, user); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return user.Spec.Disabled, nil
+}
+
+// ValidatePassword rejects passwords bcrypt can not hash in full and passwords too short to be safe
+func ValidatePassword(password string) error {
+	switch {
+	case len(password) < MinPasswordLength:
+		return fmt.Errorf("the password must be at least %d characters long", MinPasswordLength)
+	case len(password) > maxPasswordLength:
+		return fmt.Errorf("the password must be at most %d bytes long", maxPasswordLength)
+	}
+	return nil
+}
+
+// SetPassword stores the hash of password in the password Secret of user, which is owned by the user
+// and deleted with it. The initial password of the admin user is removed. c should not be a cached client.
+func SetPassword(ctx context.Context, c client.Client, user *tenantv1alpha1.User, password string) error {
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: constants.KubeNebulaNamespace, Name: PasswordSecretName(user.Name)}
+	if err := c.Get(ctx, key, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		return c.Create(ctx, newPasswordSecret(user, map[string][]byte{PasswordKey: hash}))
+	}
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data[PasswordKey] = hash
+	delete(secret.Data, InitialPasswordKey)
+	return c.Update(ctx, secret)
+}
+
+// newPasswordSecret returns the password Secret of user holding data
+func newPasswordSecret(user *tenantv1alpha1.User, data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       constants.KubeNebulaNamespace,
+			Name:            PasswordSecretName(user.Name),
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(user, tenantv1alpha1.GroupVersion.WithKind("User"))},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package users
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestClient(objects ...runtime.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	return fake.NewFakeClientWithScheme(scheme, objects...)
+}
+
+func TestPassword(t *testing.T) {
+	ctx := context.TODO()
+	alice := &tenantv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "alice"}}
+	mallory := &tenantv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "mallory"}, Spec: tenantv1alpha1.UserSpec{Disabled: true}}
+	c := newTestClient(alice, mallory, &tenantv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "bob"}})
+
+	for _, password := range []string{"short", string(make([]byte, maxPasswordLength+1))} {
+		if err := SetPassword(ctx, c, alice, password); err == nil {
+			t.Errorf("SetPassword(%q) succeeded", password)
+		}
+	}
+	for _, user := range []*tenantv1alpha1.User{alice, mallory} {
+		if err := SetPassword(ctx, c, user, "correct horse"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: constants.KubeNebulaNamespace, Name: "user-alice"}, secret); err != nil {
+		t.Fatal(err)
+	}
+	if owner := metav1.GetControllerOf(secret); owner == nil || owner.Kind != "User" || owner.Name != "alice" {
+		t.Errorf("password Secret owned by %v", owner)
+	}
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		valid    bool
+	}{
+		{"valid", "alice", "correct horse", true},
+		{"wrong password", "alice", "incorrect horse", false},
+		{"unknown user", "eve", "correct horse", false},
+		{"no password", "bob", "", false},
+		{"disabled", "mallory", "correct horse", false},
+	}
+	for _, test := range tests {
+		user, err := Authenticate(ctx, c, test.user, test.password)
+		if test.valid && (err != nil || user.Name != test.user) {
+			t.Errorf("%s: Authenticate() = %v, %v", test.name, user, err)
+		}
+		if !test.valid && err != ErrInvalidCredentials {
+			t.Errorf("%s: Authenticate() = %v, %v, expected invalid credentials", test.name, user, err)
+		}
+	}
+
+	if err := SetPassword(ctx, c, alice, "battery staple"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Authenticate(ctx, c, "alice", "battery staple"); err != nil {
+		t.Errorf("changed password rejected: %v", err)
+	}
+	if _, err := Authenticate(ctx, c, "alice", "correct horse"); err != ErrInvalidCredentials {
+		t.Errorf("old password accepted: %v", err)
+	}
+
+	for name, expected := range map[string]bool{Username("alice"): false, Username("mallory"): true, Username("eve"): false, "mallory": false} {
+		if disabled, err := Disabled(ctx, c, name); err != nil || disabled != expected {
+			t.Errorf("Disabled(%q) = %v, %v", name, disabled, err)
+		}
+	}
+}
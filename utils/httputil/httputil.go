@@ -0,0 +1,74 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package httputil contains the request and response helpers shared by the KubeNebula HTTP servers.
+package httputil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+var log = logf.Log.WithName("httputil")
+
+// MaxBodyBytes limits the size of request bodies
+const MaxBodyBytes = 1 << 20
+
+// ReadBody decodes a JSON or YAML request body into obj, failures are bad request status errors
+func ReadBody(r *http.Request, obj interface{}) error {
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxBodyBytes+1))
+	if err != nil {
+		return errors.NewBadRequest(err.Error())
+	}
+	if len(data) > MaxBodyBytes {
+		return errors.NewRequestEntityTooLargeError(fmt.Sprintf("limit is %d bytes", MaxBodyBytes))
+	}
+	if err := yaml.Unmarshal(data, obj); err != nil {
+		return errors.NewBadRequest(fmt.Sprintf("invalid request body: %s", err))
+	}
+	return nil
+}
+
+// WriteJSON writes obj with the status code
+func WriteJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		log.Error(err, "unable to write response")
+	}
+}
+
+// WriteError writes err as a Status, errors other than status errors are internal errors
+func WriteError(w http.ResponseWriter, err error) {
+	status, ok := err.(errors.APIStatus)
+	if !ok {
+		status = errors.NewInternalError(err)
+	}
+	result := status.Status()
+	result.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Status"}
+	if result.Code == 0 {
+		result.Code = http.StatusInternalServerError
+	}
+	WriteJSON(w, int(result.Code), &result)
+}
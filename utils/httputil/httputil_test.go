@@ -0,0 +1,71 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package httputil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestReadBody(t *testing.T) {
+	var obj struct {
+		Name string `json:"name"`
+	}
+	for _, body := range []string{`{"name":"nebula"}`, "name: nebula\n"} {
+		obj.Name = ""
+		if err := ReadBody(httptest.NewRequest("POST", "/", strings.NewReader(body)), &obj); err != nil || obj.Name != "nebula" {
+			t.Errorf("ReadBody(%q) = %v, %q", body, err, obj.Name)
+		}
+	}
+	if err := ReadBody(httptest.NewRequest("POST", "/", strings.NewReader("{")), &obj); !errors.IsBadRequest(err) {
+		t.Errorf("ReadBody() of a malformed body = %v, expected bad request", err)
+	}
+	large := strings.NewReader(`"` + strings.Repeat("a", MaxBodyBytes) + `"`)
+	if err := ReadBody(httptest.NewRequest("POST", "/", large), &obj); !errors.IsRequestEntityTooLargeError(err) {
+		t.Errorf("ReadBody() of a large body = %v, expected request entity too large", err)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{errors.NewNotFound(schema.GroupResource{Resource: "teams"}, "nebula"), http.StatusNotFound},
+		{errors.NewForbidden(schema.GroupResource{Resource: "teams"}, "nebula", fmt.Errorf("denied")), http.StatusForbidden},
+		{fmt.Errorf("boom"), http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		WriteError(w, test.err)
+		status := &metav1.Status{}
+		if err := json.Unmarshal(w.Body.Bytes(), status); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != test.code || int(status.Code) != test.code || status.Kind != "Status" {
+			t.Errorf("WriteError(%v) = %d %s, expected %d", test.err, w.Code, w.Body, test.code)
+		}
+	}
+}
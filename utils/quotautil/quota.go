@@ -0,0 +1,180 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package quotautil computes the usage limited by a team quota.
+package quotautil
+
+import (
+	"context"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Resources are the resource names a team quota can limit
+var Resources = []corev1.ResourceName{
+	tenantv1alpha1.ResourceNamespaces,
+	corev1.ResourcePods,
+	corev1.ResourceServices,
+	corev1.ResourcePersistentVolumeClaims,
+	corev1.ResourceRequestsStorage,
+	corev1.ResourceCPU,
+	corev1.ResourceMemory,
+	corev1.ResourceRequestsCPU,
+	corev1.ResourceRequestsMemory,
+	corev1.ResourceLimitsCPU,
+	corev1.ResourceLimitsMemory,
+}
+
+// PodUsage returns the usage of a pod, terminated pods use nothing.
+// Requests and limits are the larger of the sum of the containers and any init container,
+// as the ResourceQuota admission counts them.
+func PodUsage(pod *corev1.Pod) corev1.ResourceList {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return corev1.ResourceList{}
+	}
+	requests, limits := corev1.ResourceList{}, corev1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		requests = Add(requests, container.Resources.Requests)
+		limits = Add(limits, container.Resources.Limits)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		requests = Max(requests, container.Resources.Requests)
+		limits = Max(limits, container.Resources.Limits)
+	}
+
+	usage := corev1.ResourceList{corev1.ResourcePods: *resource.NewQuantity(1, resource.DecimalSI)}
+	for name, requestName := range map[corev1.ResourceName]corev1.ResourceName{
+		corev1.ResourceCPU:    corev1.ResourceRequestsCPU,
+		corev1.ResourceMemory: corev1.ResourceRequestsMemory,
+	} {
+		if quantity, ok := requests[name]; ok {
+			usage[name] = quantity.DeepCopy()
+			usage[requestName] = quantity.DeepCopy()
+		}
+	}
+	if quantity, ok := limits[corev1.ResourceCPU]; ok {
+		usage[corev1.ResourceLimitsCPU] = quantity.DeepCopy()
+	}
+	if quantity, ok := limits[corev1.ResourceMemory]; ok {
+		usage[corev1.ResourceLimitsMemory] = quantity.DeepCopy()
+	}
+	return usage
+}
+
+// PersistentVolumeClaimUsage returns the usage of a persistent volume claim
+func PersistentVolumeClaimUsage(pvc *corev1.PersistentVolumeClaim) corev1.ResourceList {
+	usage := corev1.ResourceList{corev1.ResourcePersistentVolumeClaims: *resource.NewQuantity(1, resource.DecimalSI)}
+	if quantity, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+		usage[corev1.ResourceRequestsStorage] = quantity.DeepCopy()
+	}
+	return usage
+}
+
+// NamespacesUsage sums the usage of the namespaces, including their number
+func NamespacesUsage(c client.Client, namespaces []string) (corev1.ResourceList, error) {
+	usage := corev1.ResourceList{
+		tenantv1alpha1.ResourceNamespaces: *resource.NewQuantity(int64(len(namespaces)), resource.DecimalSI),
+	}
+	for _, namespace := range namespaces {
+		pods := &corev1.PodList{}
+		if err := c.List(context.TODO(), pods, client.InNamespace(namespace)); err != nil {
+			return nil, err
+		}
+		for i := range pods.Items {
+			usage = Add(usage, PodUsage(&pods.Items[i]))
+		}
+
+		pvcs := &corev1.PersistentVolumeClaimList{}
+		if err := c.List(context.TODO(), pvcs, client.InNamespace(namespace)); err != nil {
+			return nil, err
+		}
+		for i := range pvcs.Items {
+			usage = Add(usage, PersistentVolumeClaimUsage(&pvcs.Items[i]))
+		}
+
+		services := &corev1.ServiceList{}
+		if err := c.List(context.TODO(), services, client.InNamespace(namespace)); err != nil {
+			return nil, err
+		}
+		usage = Add(usage, corev1.ResourceList{
+			corev1.ResourceServices: *resource.NewQuantity(int64(len(services.Items)), resource.DecimalSI),
+		})
+	}
+	return usage, nil
+}
+
+// Add returns the sum of a and b
+func Add(a, b corev1.ResourceList) corev1.ResourceList {
+	sum := make(corev1.ResourceList, len(a))
+	for name, quantity := range a {
+		sum[name] = quantity.DeepCopy()
+	}
+	for name, quantity := range b {
+		total := sum[name]
+		total.Add(quantity)
+		sum[name] = total
+	}
+	return sum
+}
+
+// Max returns the larger quantity of a and b for every resource
+func Max(a, b corev1.ResourceList) corev1.ResourceList {
+	max := make(corev1.ResourceList, len(a))
+	for name, quantity := range a {
+		max[name] = quantity.DeepCopy()
+	}
+	for name, quantity := range b {
+		if current, ok := max[name]; !ok || quantity.Cmp(current) > 0 {
+			max[name] = quantity.DeepCopy()
+		}
+	}
+	return max
+}
+
+// Mask returns the resources of list that are limited by hard, unused ones are reported as zero
+func Mask(list, hard corev1.ResourceList) corev1.ResourceList {
+	masked := make(corev1.ResourceList, len(hard))
+	for name := range hard {
+		quantity, ok := list[name]
+		if !ok {
+			quantity = *resource.NewQuantity(0, hard[name].Format)
+		}
+		masked[name] = quantity.DeepCopy()
+	}
+	return masked
+}
+
+// Exceeded returns the sorted names of the resources that request adds to and that then go over hard
+func Exceeded(hard, used, request corev1.ResourceList) []corev1.ResourceName {
+	var exceeded []corev1.ResourceName
+	for name, quantity := range request {
+		limit, ok := hard[name]
+		if !ok || quantity.Sign() <= 0 {
+			continue
+		}
+		total := used[name].DeepCopy()
+		total.Add(quantity)
+		if total.Cmp(limit) > 0 {
+			exceeded = append(exceeded, name)
+		}
+	}
+	sort.Slice(exceeded, func(i, j int) bool { return exceeded[i] < exceeded[j] })
+	return exceeded
+}
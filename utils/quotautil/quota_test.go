@@ -0,0 +1,151 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quotautil
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func container(cpu, memory string) corev1.Container {
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse(cpu),
+		corev1.ResourceMemory: resource.MustParse(memory),
+	}
+	return corev1.Container{Resources: corev1.ResourceRequirements{Requests: resources, Limits: resources}}
+}
+
+func TestPodUsage(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "nebula-dev"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{container("2", "64Mi")},
+			Containers:     []corev1.Container{container("500m", "128Mi"), container("500m", "128Mi")},
+		},
+	}
+
+	usage := PodUsage(pod)
+	for name, want := range map[corev1.ResourceName]string{
+		corev1.ResourcePods:           "1",
+		corev1.ResourceCPU:            "2",
+		corev1.ResourceRequestsCPU:    "2",
+		corev1.ResourceLimitsCPU:      "2",
+		corev1.ResourceMemory:         "256Mi",
+		corev1.ResourceRequestsMemory: "256Mi",
+		corev1.ResourceLimitsMemory:   "256Mi",
+	} {
+		got := usage[name]
+		if got.Cmp(resource.MustParse(want)) != 0 {
+			t.Errorf("PodUsage()[%s] = %s, expected %s", name, got.String(), want)
+		}
+	}
+
+	pod.Status.Phase = corev1.PodSucceeded
+	if usage := PodUsage(pod); len(usage) != 0 {
+		t.Errorf("PodUsage() of a terminated pod = %v, expected empty", usage)
+	}
+}
+
+func TestNamespacesUsage(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	c := fake.NewFakeClientWithScheme(scheme,
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "nebula-dev"},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{container("1", "1Gi")}}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "nebula-test"},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{container("2", "1Gi")}}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other-dev"},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{container("8", "8Gi")}}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "nebula-test"},
+			Spec: corev1.PersistentVolumeClaimSpec{Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")}}}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "nebula-dev"}},
+	)
+
+	usage, err := NamespacesUsage(c, []string{"nebula-dev", "nebula-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[corev1.ResourceName]string{
+		tenantv1alpha1.ResourceNamespaces:     "2",
+		corev1.ResourcePods:                   "2",
+		corev1.ResourceServices:               "1",
+		corev1.ResourcePersistentVolumeClaims: "1",
+		corev1.ResourceRequestsStorage:        "10Gi",
+		corev1.ResourceRequestsCPU:            "3",
+		corev1.ResourceLimitsMemory:           "2Gi",
+	} {
+		got := usage[name]
+		if got.Cmp(resource.MustParse(want)) != 0 {
+			t.Errorf("NamespacesUsage()[%s] = %s, expected %s", name, got.String(), want)
+		}
+	}
+}
+
+func TestExceeded(t *testing.T) {
+	hard := corev1.ResourceList{
+		corev1.ResourcePods:        resource.MustParse("2"),
+		corev1.ResourceRequestsCPU: resource.MustParse("1"),
+		corev1.ResourceMemory:      resource.MustParse("1Gi"),
+	}
+	used := corev1.ResourceList{
+		corev1.ResourcePods:        resource.MustParse("2"),
+		corev1.ResourceRequestsCPU: resource.MustParse("500m"),
+	}
+	request := corev1.ResourceList{
+		corev1.ResourcePods:        resource.MustParse("1"),
+		corev1.ResourceRequestsCPU: resource.MustParse("500m"),
+		corev1.ResourceMemory:      resource.MustParse("2Gi"),
+		corev1.ResourceLimitsCPU:   resource.MustParse("4"),
+	}
+
+	expected := []corev1.ResourceName{corev1.ResourceMemory, corev1.ResourcePods}
+	if got := Exceeded(hard, used, request); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Exceeded() = %v, expected %v", got, expected)
+	}
+	if got := Exceeded(hard, used, corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("100m")}); len(got) != 0 {
+		t.Errorf("Exceeded() = %v, expected none", got)
+	}
+}
+
+func TestMask(t *testing.T) {
+	hard := corev1.ResourceList{
+		corev1.ResourcePods:     resource.MustParse("10"),
+		corev1.ResourceServices: resource.MustParse("5"),
+	}
+	used := corev1.ResourceList{
+		corev1.ResourcePods:        resource.MustParse("3"),
+		corev1.ResourceRequestsCPU: resource.MustParse("2"),
+	}
+
+	masked := Mask(used, hard)
+	if len(masked) != 2 {
+		t.Fatalf("Mask() = %v, expected only the limited resources", masked)
+	}
+	pods, services := masked[corev1.ResourcePods], masked[corev1.ResourceServices]
+	if pods.Value() != 3 || services.Value() != 0 {
+		t.Errorf("Mask() = %v, expected 3 pods and 0 services", masked)
+	}
+}
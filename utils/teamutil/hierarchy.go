@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package teamutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CycleError reports a chain of team parents that loops back on itself.
+type CycleError struct {
+	// Teams is the chain of teams, ending with the first team seen twice.
+	Teams []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("team parent cycle: %s", strings.Join(e.Teams, " -> "))
+}
+
+// IsCycle reports whether err is a CycleError.
+func IsCycle(err error) bool {
+	_, ok := err.(*CycleError)
+	return ok
+}
+
+// Ancestors returns the ancestors of the team, from its parent up to the root team. When the chain loops it
+// returns a CycleError, when an ancestor is missing the not found error, along with the ancestors found so far.
+func Ancestors(c client.Client, team *tenantv1alpha1.Team) ([]tenantv1alpha1.Team, error) {
+	var ancestors []tenantv1alpha1.Team
+	chain := []string{team.Name}
+	for parent := team.Spec.Parent; parent != ""; {
+		for _, name := range chain {
+			if name == parent {
+				return ancestors, &CycleError{Teams: append(chain, parent)}
+			}
+		}
+		chain = append(chain, parent)
+
+		ancestor := tenantv1alpha1.Team{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: parent}, &ancestor); err != nil {
+			return ancestors, err
+		}
+		ancestors = append(ancestors, ancestor)
+		parent = ancestor.Spec.Parent
+	}
+	return ancestors, nil
+}
+
+// InheritedAncestors returns the ancestors the team inherits members from. A missing ancestor ends the chain
+// and nothing is inherited through a cycle, only errors reading the teams are returned.
+func InheritedAncestors(c client.Client, team *tenantv1alpha1.Team) ([]tenantv1alpha1.Team, error) {
+	ancestors, err := Ancestors(c, team)
+	switch {
+	case err == nil || errors.IsNotFound(err):
+		return ancestors, nil
+	case IsCycle(err):
+		return nil, nil
+	}
+	return nil, err
+}
+
+// InheritedAdmins returns the admins of the team followed by the admins of its ancestors.
+func InheritedAdmins(team *tenantv1alpha1.Team, ancestors []tenantv1alpha1.Team) []rbac.Subject {
+	admins := append([]rbac.Subject(nil), Admins(team)...)
+	for i := range ancestors {
+		admins = append(admins, Admins(&ancestors[i])...)
+	}
+	return admins
+}
+
+// InheritedViewers returns the viewers of the team followed by the viewers of its ancestors.
+func InheritedViewers(team *tenantv1alpha1.Team, ancestors []tenantv1alpha1.Team) []rbac.Subject {
+	viewers := append([]rbac.Subject(nil), team.Spec.Viewers...)
+	for _, ancestor := range ancestors {
+		viewers = append(viewers, ancestor.Spec.Viewers...)
+	}
+	return viewers
+}
+
+// Descendants returns the sorted names of the teams below the named team, at any depth.
+func Descendants(teams []tenantv1alpha1.Team, name string) []string {
+	children := make(map[string][]string)
+	for _, team := range teams {
+		if team.Spec.Parent != "" {
+			children[team.Spec.Parent] = append(children[team.Spec.Parent], team.Name)
+		}
+	}
+
+	seen := map[string]bool{name: true}
+	var descendants []string
+	for queue := children[name]; len(queue) > 0; queue = queue[1:] {
+		if seen[queue[0]] {
+			continue
+		}
+		seen[queue[0]] = true
+		descendants = append(descendants, queue[0])
+		queue = append(queue, children[queue[0]]...)
+	}
+	sort.Strings(descendants)
+	return descendants
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package teamutil
+
+import (
+	"reflect"
+	"testing"
+
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTeam(name, parent, admin string) *tenantv1alpha1.Team {
+	return &tenantv1alpha1.Team{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: tenantv1alpha1.TeamSpec{
+			Parent: parent,
+			Admins: []rbac.Subject{{Kind: rbac.UserKind, Name: admin}},
+		},
+	}
+}
+
+func teamNames(teams []tenantv1alpha1.Team) []string {
+	var names []string
+	for _, team := range teams {
+		names = append(names, team.Name)
+	}
+	return names
+}
+
+func TestAncestors(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	c := fake.NewFakeClientWithScheme(scheme,
+		newTeam("company", "", "ceo"),
+		newTeam("department", "company", "head"),
+		newTeam("nebula", "department", "lead"),
+		newTeam("ping", "pong", "a"),
+		newTeam("pong", "ping", "b"),
+		newTeam("lost", "missing", "c"),
+	)
+
+	ancestors, err := Ancestors(c, newTeam("nebula", "department", "lead"))
+	if err != nil || !reflect.DeepEqual(teamNames(ancestors), []string{"department", "company"}) {
+		t.Errorf("Ancestors() = %v, %v, expected department and company", teamNames(ancestors), err)
+	}
+	if _, err := Ancestors(c, newTeam("ping", "pong", "a")); !IsCycle(err) {
+		t.Errorf("Ancestors() error = %v, expected a cycle", err)
+	}
+	if _, err := Ancestors(c, newTeam("lost", "missing", "c")); !errors.IsNotFound(err) {
+		t.Errorf("Ancestors() error = %v, expected not found", err)
+	}
+
+	if ancestors, err := InheritedAncestors(c, newTeam("ping", "pong", "a")); err != nil || len(ancestors) != 0 {
+		t.Errorf("InheritedAncestors() = %v, %v, expected nothing inherited through a cycle", teamNames(ancestors), err)
+	}
+}
+
+func TestInheritedAdmins(t *testing.T) {
+	team := newTeam("nebula", "department", "lead")
+	ancestors := []tenantv1alpha1.Team{*newTeam("department", "company", "head"), *newTeam("company", "", "ceo")}
+	ancestors[1].Spec.Manager = "founder"
+
+	var names []string
+	for _, subject := range InheritedAdmins(team, ancestors) {
+		names = append(names, subject.Name)
+	}
+	if expected := []string{"lead", "head", "founder", "ceo"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("InheritedAdmins() = %v, expected %v", names, expected)
+	}
+	if len(team.Spec.Admins) != 1 {
+		t.Errorf("InheritedAdmins() modified the team admins: %v", team.Spec.Admins)
+	}
+}
+
+func TestDescendants(t *testing.T) {
+	teams := []tenantv1alpha1.Team{
+		*newTeam("company", "", ""),
+		*newTeam("department", "company", ""),
+		*newTeam("nebula", "department", ""),
+		*newTeam("comet", "department", ""),
+		*newTeam("other", "", ""),
+		*newTeam("ping", "pong", ""),
+		*newTeam("pong", "ping", ""),
+	}
+	if got, expected := Descendants(teams, "company"), []string{"comet", "department", "nebula"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Descendants() = %v, expected %v", got, expected)
+	}
+	if got, expected := Descendants(teams, "ping"), []string{"pong"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Descendants() of a cycle = %v, expected %v", got, expected)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package teamutil
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"kubenebula.io/kubenebula/constants"
+)
+
+// Team label encoding
+//
+// The kubenebula.io/team annotation is the source of truth for the team a namespace belongs to.
+// Objects that must be selectable by team carry exactly one label derived from the team name:
+//
+//   kubenebula.io/team=<name>           when the name is a valid label value (at most 63 characters)
+//   kubenebula.io/team-hash=<sha224>    otherwise, the hex encoded SHA-224 of the name
+//
+// Labels returns that label and Selector selects it, every lookup by team must go through them.
+
+// TeamLabelKeys are the label keys managed by Labels, including the legacy base64 label.
+var TeamLabelKeys = []string{constants.TeamLabelKey, constants.TeamHashLabelKey, constants.LegacyTeamLabelKey}
+
+// Labels returns the labels identifying the team.
+func Labels(team string) map[string]string {
+	if len(validation.IsValidLabelValue(team)) == 0 {
+		return map[string]string{constants.TeamLabelKey: team}
+	}
+	return map[string]string{constants.TeamHashLabelKey: Hash(team)}
+}
+
+// Selector returns a selector matching objects labelled for the team.
+func Selector(team string) labels.Selector {
+	return labels.SelectorFromSet(Labels(team))
+}
+
+// Hash returns the value of the team hash label.
+func Hash(team string) string {
+	return fmt.Sprintf("%x", sha256.Sum224([]byte(team)))
+}
+
+// HasLabels reports whether objectLabels carry exactly the team labels of team.
+func HasLabels(objectLabels map[string]string, team string) bool {
+	expected := Labels(team)
+	for _, key := range TeamLabelKeys {
+		if objectLabels[key] != expected[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// SetLabels replaces the team labels in objectLabels with the labels of team,
+// an empty team removes them. It returns the updated labels.
+func SetLabels(objectLabels map[string]string, team string) map[string]string {
+	if objectLabels == nil {
+		objectLabels = make(map[string]string)
+	}
+	for _, key := range TeamLabelKeys {
+		delete(objectLabels, key)
+	}
+	if team == "" {
+		return objectLabels
+	}
+	for key, value := range Labels(team) {
+		objectLabels[key] = value
+	}
+	return objectLabels
+}
+
+// TeamName returns the team an object belongs to, read from the team annotation
+// and falling back to the team name label.
+func TeamName(object metav1.Object) string {
+	if team := object.GetAnnotations()[constants.TeamAnnotationKey]; team != "" {
+		return team
+	}
+	return object.GetLabels()[constants.TeamLabelKey]
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package teamutil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"kubenebula.io/kubenebula/constants"
+)
+
+func TestLabels(t *testing.T) {
+	long := strings.Repeat("a", 64)
+	tests := []struct {
+		team     string
+		expected map[string]string
+	}{
+		{"nebula", map[string]string{constants.TeamLabelKey: "nebula"}},
+		{"nebula.dev", map[string]string{constants.TeamLabelKey: "nebula.dev"}},
+		{long, map[string]string{constants.TeamHashLabelKey: Hash(long)}},
+	}
+	for _, test := range tests {
+		got := Labels(test.team)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Labels(%q) = %v, expected %v", test.team, got, test.expected)
+		}
+		if !Selector(test.team).Matches(labels.Set(got)) {
+			t.Errorf("Selector(%q) does not match %v", test.team, got)
+		}
+		if !HasLabels(got, test.team) {
+			t.Errorf("HasLabels(%v, %q) = false", got, test.team)
+		}
+	}
+}
+
+func TestSetLabels(t *testing.T) {
+	objectLabels := map[string]string{
+		"app":                        "web",
+		constants.LegacyTeamLabelKey: "bmVidWxh",
+		constants.TeamHashLabelKey:   Hash("other"),
+	}
+	got := SetLabels(objectLabels, "nebula")
+	expected := map[string]string{"app": "web", constants.TeamLabelKey: "nebula"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("SetLabels() = %v, expected %v", got, expected)
+	}
+	if got := SetLabels(got, ""); !reflect.DeepEqual(got, map[string]string{"app": "web"}) {
+		t.Errorf("SetLabels() with empty team = %v", got)
+	}
+	if HasLabels(map[string]string{constants.TeamLabelKey: "nebula", constants.LegacyTeamLabelKey: "x"}, "nebula") {
+		t.Errorf("HasLabels() accepted a legacy label")
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package teamutil
+
+import (
+	"reflect"
+
+	rbac "k8s.io/api/rbac/v1"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("teamutil")
+
+// Admins returns the admin members of the team with the manager first.
+func Admins(team *tenantv1alpha1.Team) []rbac.Subject {
+	if team.Spec.Manager == "" {
+		return team.Spec.Admins
+	}
+	manager := rbac.Subject{APIGroup: rbac.GroupName, Kind: rbac.UserKind, Name: team.Spec.Manager}
+	return append([]rbac.Subject{manager}, team.Spec.Admins...)
+}
+
+// Subjects normalizes the API group of team members and drops duplicates and unsupported kinds,
+// preserving the order in which members are declared.
+func Subjects(members []rbac.Subject) []rbac.Subject {
+	subjects := make([]rbac.Subject, 0, len(members))
+	for _, member := range members {
+		switch member.Kind {
+		case rbac.UserKind, rbac.GroupKind:
+			member.APIGroup = rbac.GroupName
+			member.Namespace = ""
+		case rbac.ServiceAccountKind:
+			member.APIGroup = ""
+			if member.Namespace == "" {
+				log.Info("Ignoring service account member without namespace", "name", member.Name)
+				continue
+			}
+		default:
+			log.Info("Ignoring team member of unsupported kind", "kind", member.Kind, "name", member.Name)
+			continue
+		}
+		if member.Name == "" || HasSubject(subjects, member) {
+			continue
+		}
+		subjects = append(subjects, member)
+	}
+	return subjects
+}
+
+// HasSubject reports whether subjects contains subject.
+func HasSubject(subjects []rbac.Subject, subject rbac.Subject) bool {
+	for _, s := range subjects {
+		if reflect.DeepEqual(s, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// EqualSubjects compares two subject lists, treating nil and empty as equal.
+func EqualSubjects(a, b []rbac.Subject) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// serviceAccountUsernamePrefix is the prefix of the user names of service accounts, system:serviceaccount:<namespace>:<name>
+const serviceAccountUsernamePrefix = "system:serviceaccount:"
+
+// HasUser reports whether one of subjects refers to the user with the given name and groups.
+func HasUser(subjects []rbac.Subject, username string, groups []string) bool {
+	for _, subject := range subjects {
+		switch subject.Kind {
+		case rbac.UserKind:
+			if subject.Name == username {
+				return true
+			}
+		case rbac.GroupKind:
+			for _, group := range groups {
+				if subject.Name == group {
+					return true
+				}
+			}
+		case rbac.ServiceAccountKind:
+			if serviceAccountUsernamePrefix+subject.Namespace+":"+subject.Name == username {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GroupName returns the name of the group of the users holding role in team, kubenebula:team:<team>:<role>.
+func GroupName(team, role string) string {
+	return constants.TeamGroupPrefix + team + ":" + role
+}
+
+// WithGroup returns a copy of members followed by the group of the users holding role in team, so
+// the binding of the role also applies to users authenticated with their team groups.
+func WithGroup(members []rbac.Subject, team, role string) []rbac.Subject {
+	subjects := make([]rbac.Subject, 0, len(members)+1)
+	subjects = append(subjects, members...)
+	return append(subjects, rbac.Subject{APIGroup: rbac.GroupName, Kind: rbac.GroupKind, Name: GroupName(team, role)})
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package teamutil
+
+import (
+	"reflect"
+	"testing"
+
+	rbac "k8s.io/api/rbac/v1"
+)
+
+func TestSubjects(t *testing.T) {
+	members := []rbac.Subject{
+		{Kind: rbac.UserKind, Name: "alice"},
+		{Kind: rbac.GroupKind, Name: "devs", Namespace: "ignored"},
+		{Kind: rbac.ServiceAccountKind, Name: "ci", Namespace: "tools"},
+		{Kind: rbac.ServiceAccountKind, Name: "orphan"},
+		{Kind: "Robot", Name: "r2d2"},
+		{APIGroup: rbac.GroupName, Kind: rbac.UserKind, Name: "alice"},
+		{Kind: rbac.UserKind},
+	}
+	expected := []rbac.Subject{
+		{APIGroup: rbac.GroupName, Kind: rbac.UserKind, Name: "alice"},
+		{APIGroup: rbac.GroupName, Kind: rbac.GroupKind, Name: "devs"},
+		{Kind: rbac.ServiceAccountKind, Name: "ci", Namespace: "tools"},
+	}
+	if got := Subjects(members); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Subjects() = %v, expected %v", got, expected)
+	}
+	if got := Subjects(nil); len(got) != 0 {
+		t.Errorf("Subjects(nil) = %v, expected empty", got)
+	}
+}
+
+func TestHasUser(t *testing.T) {
+	subjects := []rbac.Subject{
+		{Kind: rbac.UserKind, Name: "alice"},
+		{Kind: rbac.GroupKind, Name: "devs"},
+		{Kind: rbac.ServiceAccountKind, Name: "ci", Namespace: "tools"},
+	}
+	tests := []struct {
+		username string
+		groups   []string
+		expected bool
+	}{
+		{"alice", nil, true},
+		{"bob", nil, false},
+		{"bob", []string{"ops", "devs"}, true},
+		{"system:serviceaccount:tools:ci", nil, true},
+		{"system:serviceaccount:other:ci", nil, false},
+	}
+	for _, test := range tests {
+		if got := HasUser(subjects, test.username, test.groups); got != test.expected {
+			t.Errorf("HasUser(%s, %v) = %v, expected %v", test.username, test.groups, got, test.expected)
+		}
+	}
+}
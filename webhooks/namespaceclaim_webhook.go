@@ -0,0 +1,144 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/tenant"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	mutateNamespaceClaimPath   = "/mutate-tenant-kubenebula-io-v1alpha1-namespaceclaim"
+	validateNamespaceClaimPath = "/validate-tenant-kubenebula-io-v1alpha1-namespaceclaim"
+)
+
+// requesterAnnotations are the annotations recording the requester of a NamespaceClaim
+var requesterAnnotations = []string{constants.RequesterAnnotationKey, constants.RequesterGroupsAnnotationKey}
+
+// +kubebuilder:webhook:path=/mutate-tenant-kubenebula-io-v1alpha1-namespaceclaim,mutating=true,failurePolicy=fail,groups=tenant.kubenebula.io,resources=namespaceclaims,verbs=create;update,versions=v1alpha1,name=mnamespaceclaim.kubenebula.io
+
+// namespaceClaimDefaulter records the user who submitted a NamespaceClaim, the controller checks its team membership
+type namespaceClaimDefaulter struct {
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &namespaceClaimDefaulter{}
+
+func (d *namespaceClaimDefaulter) InjectDecoder(decoder *admission.Decoder) error {
+	d.decoder = decoder
+	return nil
+}
+
+func (d *namespaceClaimDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	claim := &tenantv1alpha1.NamespaceClaim{}
+	if err := d.decoder.Decode(req, claim); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if req.Operation == admissionv1beta1.Create {
+		tenant.SetClaimRequester(claim, requestUser(req.UserInfo))
+	} else {
+		old := &tenantv1alpha1.NamespaceClaim{}
+		if err := d.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		// the requester can not be changed once recorded
+		for _, key := range requesterAnnotations {
+			if value, ok := old.Annotations[key]; ok {
+				if claim.Annotations == nil {
+					claim.Annotations = make(map[string]string)
+				}
+				claim.Annotations[key] = value
+			} else {
+				delete(claim.Annotations, key)
+			}
+		}
+	}
+
+	marshaled, err := json.Marshal(claim)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// requestUser returns the user of an admission request
+func requestUser(userInfo authenticationv1.UserInfo) tenant.User {
+	return tenant.User{Name: userInfo.Username, Groups: append([]string(nil), userInfo.Groups...)}
+}
+
+// +kubebuilder:webhook:path=/validate-tenant-kubenebula-io-v1alpha1-namespaceclaim,mutating=false,failurePolicy=fail,groups=tenant.kubenebula.io,resources=namespaceclaims,verbs=create;update,versions=v1alpha1,name=vnamespaceclaim.kubenebula.io
+
+// namespaceClaimValidator rejects NamespaceClaims whose requester is not the user of the request that
+// created them, so the namespaceclaim controller can trust the requester annotations
+type namespaceClaimValidator struct {
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &namespaceClaimValidator{}
+
+func (v *namespaceClaimValidator) InjectDecoder(decoder *admission.Decoder) error {
+	v.decoder = decoder
+	return nil
+}
+
+func (v *namespaceClaimValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	claim := &tenantv1alpha1.NamespaceClaim{}
+	if err := v.decoder.Decode(req, claim); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if req.Operation == admissionv1beta1.Create {
+		requester, ok := tenant.ClaimRequester(claim)
+		if !ok || !reflect.DeepEqual(normalizeUser(requester), normalizeUser(requestUser(req.UserInfo))) {
+			return admission.Denied(fmt.Sprintf("the %s and %s annotations must record the user creating the claim",
+				constants.RequesterAnnotationKey, constants.RequesterGroupsAnnotationKey))
+		}
+		return admission.Allowed("")
+	}
+
+	old := &tenantv1alpha1.NamespaceClaim{}
+	if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	for _, key := range requesterAnnotations {
+		value, ok := claim.Annotations[key]
+		oldValue, oldOk := old.Annotations[key]
+		if value != oldValue || ok != oldOk {
+			return admission.Denied(fmt.Sprintf("the %s annotation is immutable", key))
+		}
+	}
+	return admission.Allowed("")
+}
+
+// normalizeUser makes users without groups compare equal regardless of a nil or empty slice
+func normalizeUser(user tenant.User) tenant.User {
+	if len(user.Groups) == 0 {
+		user.Groups = nil
+	}
+	return user
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/tenant"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestNamespaceClaimDefaulter(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaulter := &namespaceClaimDefaulter{decoder: decoder}
+
+	forged := &tenantv1alpha1.NamespaceClaim{
+		TypeMeta:   metav1.TypeMeta{APIVersion: tenantv1alpha1.GroupVersion.String(), Kind: "NamespaceClaim"},
+		ObjectMeta: metav1.ObjectMeta{Name: "nebula-dev"},
+		Spec: tenantv1alpha1.NamespaceClaimSpec{
+			Namespace: "nebula-dev",
+			Team:      "nebula",
+		},
+	}
+	recorded := forged.DeepCopy()
+	tenant.SetClaimRequester(forged, tenant.User{Name: "admin"})
+	tenant.SetClaimRequester(recorded, tenant.User{Name: "alice", Groups: []string{"devs"}})
+
+	tests := []struct {
+		name     string
+		request  admissionv1beta1.AdmissionRequest
+		patched  bool
+		username string
+	}{
+		{
+			name: "create records the requester",
+			request: admissionv1beta1.AdmissionRequest{
+				Operation: admissionv1beta1.Create,
+				Object:    rawClaim(forged),
+				UserInfo:  authenticationv1.UserInfo{Username: "alice", Groups: []string{"devs"}},
+			},
+			patched:  true,
+			username: "alice",
+		},
+		{
+			name: "update keeps the requester",
+			request: admissionv1beta1.AdmissionRequest{
+				Operation: admissionv1beta1.Update,
+				Object:    rawClaim(forged),
+				OldObject: rawClaim(recorded),
+				UserInfo:  authenticationv1.UserInfo{Username: "admin"},
+			},
+			patched:  true,
+			username: "alice",
+		},
+		{
+			name: "unchanged",
+			request: admissionv1beta1.AdmissionRequest{
+				Operation: admissionv1beta1.Update,
+				Object:    rawClaim(recorded),
+				OldObject: rawClaim(recorded),
+				UserInfo:  authenticationv1.UserInfo{Username: "admin"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := defaulter.Handle(context.TODO(), admission.Request{AdmissionRequest: test.request})
+			if !response.Allowed {
+				t.Fatalf("expected allowed, got %v", response.Result)
+			}
+			if patched := len(response.Patches) > 0; patched != test.patched {
+				t.Fatalf("patched = %v, expected %v: %v", patched, test.patched, response.Patches)
+			}
+			for _, patch := range response.Patches {
+				if patch.Path == "/metadata/annotations/kubenebula.io~1requester" && patch.Value != test.username {
+					t.Errorf("requester = %v, expected %s", patch.Value, test.username)
+				}
+			}
+		})
+	}
+}
+
+func TestNamespaceClaimValidator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator := &namespaceClaimValidator{decoder: decoder}
+
+	claim := &tenantv1alpha1.NamespaceClaim{
+		TypeMeta:   metav1.TypeMeta{APIVersion: tenantv1alpha1.GroupVersion.String(), Kind: "NamespaceClaim"},
+		ObjectMeta: metav1.ObjectMeta{Name: "nebula-dev"},
+		Spec:       tenantv1alpha1.NamespaceClaimSpec{Namespace: "nebula-dev", Team: "nebula"},
+	}
+	recorded := claim.DeepCopy()
+	tenant.SetClaimRequester(recorded, tenant.User{Name: "alice", Groups: []string{"devs"}})
+	forged := claim.DeepCopy()
+	tenant.SetClaimRequester(forged, tenant.User{Name: "admin"})
+	forgedGroups := recorded.DeepCopy()
+	forgedGroups.Annotations[constants.RequesterGroupsAnnotationKey] = `["system:masters"]`
+	alice := authenticationv1.UserInfo{Username: "alice", Groups: []string{"devs"}}
+
+	tests := []struct {
+		name    string
+		request admissionv1beta1.AdmissionRequest
+		allowed bool
+	}{
+		{"create", admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Create, Object: rawClaim(recorded), UserInfo: alice}, true},
+		{"create without requester", admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Create, Object: rawClaim(claim), UserInfo: alice}, false},
+		{"create as another user", admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Create, Object: rawClaim(forged), UserInfo: alice}, false},
+		{"create with other groups", admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Create, Object: rawClaim(forgedGroups), UserInfo: alice}, false},
+		{"update", admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Update, Object: rawClaim(recorded), OldObject: rawClaim(recorded)}, true},
+		{"update requester", admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Update, Object: rawClaim(forged), OldObject: rawClaim(recorded)}, false},
+		{"update groups", admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Update, Object: rawClaim(forgedGroups), OldObject: rawClaim(recorded)}, false},
+		{"remove requester", admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Update, Object: rawClaim(claim), OldObject: rawClaim(recorded)}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := validator.Handle(context.TODO(), admission.Request{AdmissionRequest: test.request})
+			if response.Allowed != test.allowed {
+				t.Errorf("allowed = %v, expected %v: %v", response.Allowed, test.allowed, response.Result)
+			}
+		})
+	}
+}
+
+func rawClaim(claim *tenantv1alpha1.NamespaceClaim) runtime.RawExtension {
+	data, _ := json.Marshal(claim)
+	return runtime.RawExtension{Raw: data}
+}
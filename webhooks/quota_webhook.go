@@ -0,0 +1,153 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/utils/quotautil"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	validatePodPath       = "/validate-core-v1-pod"
+	validateNamespacePath = "/validate-core-v1-namespace"
+)
+
+// The quota webhooks ignore failures, otherwise an unavailable manager would block every pod in the cluster,
+// including its own.
+
+// +kubebuilder:webhook:path=/validate-core-v1-pod,mutating=false,failurePolicy=ignore,groups="",resources=pods,verbs=create,versions=v1,name=vpod.kubenebula.io
+
+// podQuotaValidator rejects pods that would take their team over its quota
+type podQuotaValidator struct {
+	client  client.Client
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &podQuotaValidator{}
+
+func (v *podQuotaValidator) InjectDecoder(decoder *admission.Decoder) error {
+	v.decoder = decoder
+	return nil
+}
+
+func (v *podQuotaValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := v.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	namespace := &corev1.Namespace{}
+	if err := v.client.Get(ctx, types.NamespacedName{Name: req.Namespace}, namespace); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return checkTeamQuota(ctx, v.client, teamutil.TeamName(namespace), quotautil.PodUsage(pod))
+}
+
+// +kubebuilder:webhook:path=/validate-core-v1-namespace,mutating=false,failurePolicy=ignore,groups="",resources=namespaces,verbs=create;update,versions=v1,name=vnamespace.kubenebula.io
+
+// namespaceQuotaValidator rejects namespaces that would take the team they join over its namespace quota
+type namespaceQuotaValidator struct {
+	client  client.Client
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &namespaceQuotaValidator{}
+
+func (v *namespaceQuotaValidator) InjectDecoder(decoder *admission.Decoder) error {
+	v.decoder = decoder
+	return nil
+}
+
+func (v *namespaceQuotaValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	namespace := &corev1.Namespace{}
+	if err := v.decoder.Decode(req, namespace); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	teamName := teamutil.TeamName(namespace)
+	if teamName == "" {
+		return admission.Allowed("")
+	}
+	if len(req.OldObject.Raw) > 0 {
+		old := &corev1.Namespace{}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		// only namespaces joining the team are counted
+		if teamutil.TeamName(old) == teamName {
+			return admission.Allowed("")
+		}
+	}
+	return checkTeamQuota(ctx, v.client, teamName, corev1.ResourceList{
+		tenantv1alpha1.ResourceNamespaces: *resource.NewQuantity(1, resource.DecimalSI),
+	})
+}
+
+// checkTeamQuota denies the request if adding request to the usage of the team namespaces exceeds the team quota
+func checkTeamQuota(ctx context.Context, c client.Client, teamName string, request corev1.ResourceList) admission.Response {
+	if teamName == "" {
+		return admission.Allowed("")
+	}
+	team := &tenantv1alpha1.Team{}
+	if err := c.Get(ctx, types.NamespacedName{Name: teamName}, team); err != nil {
+		if errors.IsNotFound(err) {
+			return admission.Allowed("")
+		}
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if team.Spec.Quota == nil || len(team.Spec.Quota.Hard) == 0 {
+		return admission.Allowed("")
+	}
+
+	nsList := &corev1.NamespaceList{}
+	if err := c.List(ctx, nsList, client.MatchingLabelsSelector{Selector: teamutil.Selector(teamName)}); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	namespaces := make([]string, 0, len(nsList.Items))
+	for _, namespace := range nsList.Items {
+		namespaces = append(namespaces, namespace.Name)
+	}
+	used, err := quotautil.NamespacesUsage(c, namespaces)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	hard := team.Spec.Quota.Hard
+	if exceeded := quotautil.Exceeded(hard, used, request); len(exceeded) > 0 {
+		var message string
+		for i, name := range exceeded {
+			if i > 0 {
+				message += ", "
+			}
+			requested, used, limit := request[name], used[name], hard[name]
+			message += fmt.Sprintf("%s: requested %s, used %s, limited %s", name, &requested, &used, &limit)
+		}
+		log.Info("Rejecting over quota request", "team", teamName, "exceeded", message)
+		return admission.Denied(fmt.Sprintf("exceeded quota of team %s: %s", teamName, message))
+	}
+	return admission.Allowed("")
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCheckTeamQuota(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	c := fake.NewFakeClientWithScheme(scheme,
+		&tenantv1alpha1.Team{
+			ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+			Spec: tenantv1alpha1.TeamSpec{Quota: &tenantv1alpha1.TeamQuota{Hard: corev1.ResourceList{
+				tenantv1alpha1.ResourceNamespaces: resource.MustParse("1"),
+				corev1.ResourceRequestsCPU:        resource.MustParse("2"),
+			}}},
+		},
+		&tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "unlimited"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-dev", Labels: teamutil.Labels("nebula")}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "nebula-dev"},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1500m")},
+			}}}},
+		},
+	)
+
+	tests := []struct {
+		name    string
+		team    string
+		request corev1.ResourceList
+		allowed bool
+	}{
+		{"within quota", "nebula", corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("500m")}, true},
+		{"cpu exceeded", "nebula", corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("1")}, false},
+		{"namespaces exceeded", "nebula", corev1.ResourceList{tenantv1alpha1.ResourceNamespaces: resource.MustParse("1")}, false},
+		{"unlimited resource", "nebula", corev1.ResourceList{corev1.ResourceLimitsMemory: resource.MustParse("64Gi")}, true},
+		{"team without quota", "unlimited", corev1.ResourceList{tenantv1alpha1.ResourceNamespaces: resource.MustParse("1")}, true},
+		{"missing team", "missing", corev1.ResourceList{tenantv1alpha1.ResourceNamespaces: resource.MustParse("1")}, true},
+		{"no team", "", corev1.ResourceList{tenantv1alpha1.ResourceNamespaces: resource.MustParse("1")}, true},
+	}
+	for _, test := range tests {
+		response := checkTeamQuota(context.TODO(), c, test.team, test.request)
+		if response.Allowed != test.allowed {
+			t.Errorf("%s: allowed = %v, expected %v: %+v", test.name, response.Allowed, test.allowed, response.Result)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/validation/path"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/users"
+	"kubenebula.io/kubenebula/utils/quotautil"
+	"kubenebula.io/kubenebula/utils/sliceutil"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	mutateTeamPath   = "/mutate-tenant-kubenebula-io-v1alpha1-team"
+	validateTeamPath = "/validate-tenant-kubenebula-io-v1alpha1-team"
+
+	// maxResourceNameLength is the longest name accepted for ClusterRoles and ClusterRoleBindings
+	maxResourceNameLength = 253
+)
+
+// teamRoleSuffixes are the suffixes of the team:<name>:<role> ClusterRoles and ClusterRoleBindings
+var teamRoleSuffixes = []string{"admin", "regular", "viewer"}
+
+// +kubebuilder:webhook:path=/mutate-tenant-kubenebula-io-v1alpha1-team,mutating=true,failurePolicy=fail,groups=tenant.kubenebula.io,resources=teams,verbs=create;update,versions=v1alpha1,name=mteam.kubenebula.io
+
+// teamDefaulter records the creator of a team and defaults its members
+type teamDefaulter struct {
+	decoder       *admission.Decoder
+	validateUsers bool
+}
+
+var _ admission.DecoderInjector = &teamDefaulter{}
+
+func (d *teamDefaulter) InjectDecoder(decoder *admission.Decoder) error {
+	d.decoder = decoder
+	return nil
+}
+
+func (d *teamDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	team := &tenantv1alpha1.Team{}
+	if err := d.decoder.Decode(req, team); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if req.Operation == admissionv1beta1.Create {
+		defaultTeamCreator(team, req.UserInfo.Username)
+		defaultTeamManager(team, d.validateUsers)
+	} else {
+		old := &tenantv1alpha1.Team{}
+		if err := d.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		// the creator can not be changed once recorded, nor added to a team created without one
+		if creator, ok := old.Annotations[constants.CreatorAnnotationKey]; ok {
+			if team.Annotations == nil {
+				team.Annotations = make(map[string]string)
+			}
+			team.Annotations[constants.CreatorAnnotationKey] = creator
+		} else {
+			delete(team.Annotations, constants.CreatorAnnotationKey)
+		}
+	}
+	defaultTeam(team)
+
+	marshaled, err := json.Marshal(team)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+func defaultTeamCreator(team *tenantv1alpha1.Team, creator string) {
+	if creator == "" {
+		return
+	}
+	if team.Annotations == nil {
+		team.Annotations = make(map[string]string)
+	}
+	team.Annotations[constants.CreatorAnnotationKey] = creator
+}
+
+// defaultTeamManager makes the creator the manager of a new team without one. It only runs on create,
+// so the manager of an existing team can be cleared. When the users are validated only a built-in user
+// can be the manager, so other creators are left out instead of failing the validation.
+func defaultTeamManager(team *tenantv1alpha1.Team, validateUsers bool) {
+	creator := team.Annotations[constants.CreatorAnnotationKey]
+	if team.Spec.Manager != "" || creator == "" {
+		return
+	}
+	if _, builtin := users.Name(creator); validateUsers && !builtin {
+		return
+	}
+	team.Spec.Manager = creator
+}
+
+// defaultTeam defaults the role lists to empty lists, fills in the API group of members and the deletion policy
+func defaultTeam(team *tenantv1alpha1.Team) {
+	team.Spec.DeletionPolicy = team.GetDeletionPolicy()
+	for _, members := range []*[]rbac.Subject{&team.Spec.Admins, &team.Spec.Regulars, &team.Spec.Viewers} {
+		if *members == nil {
+			*members = []rbac.Subject{}
+		}
+	}
+	for _, members := range [][]rbac.Subject{team.Spec.Admins, team.Spec.Regulars, team.Spec.Viewers} {
+		for i := range members {
+			if members[i].APIGroup != "" {
+				continue
+			}
+			switch members[i].Kind {
+			case rbac.UserKind, rbac.GroupKind:
+				members[i].APIGroup = rbac.GroupName
+			}
+		}
+	}
+}
+
+// +kubebuilder:webhook:path=/validate-tenant-kubenebula-io-v1alpha1-team,mutating=false,failurePolicy=fail,groups=tenant.kubenebula.io,resources=teams,verbs=create;update;delete,versions=v1alpha1,name=vteam.kubenebula.io
+
+// teamValidator rejects teams that the team controller can not reconcile and deletions the team controller
+// would block
+type teamValidator struct {
+	client         client.Client
+	decoder        *admission.Decoder
+	requireManager bool
+	validateUsers  bool
+}
+
+var _ admission.DecoderInjector = &teamValidator{}
+
+func (v *teamValidator) InjectDecoder(decoder *admission.Decoder) error {
+	v.decoder = decoder
+	return nil
+}
+
+func (v *teamValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation == admissionv1beta1.Delete {
+		return v.handleDelete(ctx, req)
+	}
+	team := &tenantv1alpha1.Team{}
+	if err := v.decoder.Decode(req, team); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	errs := validateTeam(team, v.requireManager)
+	parentErrs, err := validateParent(v.client, team)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	errs = append(errs, parentErrs...)
+	if v.validateUsers {
+		var old *tenantv1alpha1.Team
+		if req.Operation == admissionv1beta1.Update {
+			old = &tenantv1alpha1.Team{}
+			if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+				return admission.Errored(http.StatusBadRequest, err)
+			}
+		}
+		userErrs, err := validateUsers(ctx, v.client, team, old)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		errs = append(errs, userErrs...)
+	}
+	if len(errs) > 0 {
+		log.Info("Rejecting team", "team", team.Name, "errors", errs.ToAggregate().Error())
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+	return admission.Allowed("")
+}
+
+// handleDelete rejects deleting a team that still has child teams or, with the Block deletion policy,
+// namespaces. The finalizer of the team controller would otherwise leave the team terminating until they are gone.
+func (v *teamValidator) handleDelete(ctx context.Context, req admission.Request) admission.Response {
+	team := &tenantv1alpha1.Team{}
+	if len(req.OldObject.Raw) > 0 {
+		if err := v.decoder.DecodeRaw(req.OldObject, team); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	} else if err := v.client.Get(ctx, types.NamespacedName{Name: req.Name}, team); err != nil {
+		// the kube-apiserver only sends the old object of deletions since Kubernetes 1.15
+		if errors.IsNotFound(err) {
+			return admission.Allowed("")
+		}
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	reasons, err := deletionBlockers(ctx, v.client, team)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if len(reasons) > 0 {
+		log.Info("Rejecting team deletion", "team", team.Name, "reasons", reasons)
+		return admission.Denied(fmt.Sprintf("team %s can not be deleted: %s", team.Name, strings.Join(reasons, "; ")))
+	}
+	return admission.Allowed("")
+}
+
+// deletionBlockers returns why the team controller would block the deletion of team
+func deletionBlockers(ctx context.Context, c client.Reader, team *tenantv1alpha1.Team) ([]string, error) {
+	var reasons []string
+
+	teams := &tenantv1alpha1.TeamList{}
+	if err := c.List(ctx, teams); err != nil {
+		return nil, err
+	}
+	var children []string
+	for _, child := range teams.Items {
+		if child.Spec.Parent == team.Name && child.Name != team.Name {
+			children = append(children, child.Name)
+		}
+	}
+	if len(children) > 0 {
+		sort.Strings(children)
+		reasons = append(reasons, fmt.Sprintf("the team still has child teams: %s", strings.Join(children, ", ")))
+	}
+
+	if team.GetDeletionPolicy() == tenantv1alpha1.BlockDeletionPolicy {
+		namespaces := &corev1.NamespaceList{}
+		if err := c.List(ctx, namespaces, client.MatchingLabelsSelector{Selector: teamutil.Selector(team.Name)}); err != nil {
+			return nil, err
+		}
+		if len(namespaces.Items) > 0 {
+			names := make([]string, 0, len(namespaces.Items))
+			for _, namespace := range namespaces.Items {
+				names = append(names, namespace.Name)
+			}
+			sort.Strings(names)
+			reasons = append(reasons, fmt.Sprintf("deletion policy is Block and the team still has namespaces: %s", strings.Join(names, ", ")))
+		}
+	}
+	return reasons, nil
+}
+
+func validateTeam(team *tenantv1alpha1.Team, requireManager bool) field.ErrorList {
+	var errs field.ErrorList
+
+	namePath := field.NewPath("metadata", "name")
+	if strings.Contains(team.Name, ":") {
+		errs = append(errs, field.Invalid(namePath, team.Name, "must not contain ':'"))
+	}
+	if maxLength := maxTeamNameLength(); len(team.Name) > maxLength {
+		errs = append(errs, field.TooLong(namePath, team.Name, maxLength))
+	}
+	for _, msg := range path.IsValidPathSegmentName(team.Name) {
+		errs = append(errs, field.Invalid(namePath, team.Name, msg))
+	}
+
+	specPath := field.NewPath("spec")
+	if team.Spec.Parent != "" {
+		for _, msg := range path.IsValidPathSegmentName(team.Spec.Parent) {
+			errs = append(errs, field.Invalid(specPath.Child("parent"), team.Spec.Parent, msg))
+		}
+	}
+	if team.Spec.Manager == "" {
+		if requireManager {
+			errs = append(errs, field.Required(specPath.Child("manager"), "a team manager is required"))
+		}
+	} else {
+		errs = append(errs, validateUserName(specPath.Child("manager"), team.Spec.Manager)...)
+	}
+
+	seen := make(map[rbac.Subject]*field.Path)
+	for _, members := range []struct {
+		name     string
+		subjects []rbac.Subject
+	}{
+		{"admins", team.Spec.Admins},
+		{"regulars", team.Spec.Regulars},
+		{"viewers", team.Spec.Viewers},
+	} {
+		for i, subject := range members.subjects {
+			subjectPath := specPath.Child(members.name).Index(i)
+			errs = append(errs, validateSubject(subjectPath, subject)...)
+			key := rbac.Subject{Kind: subject.Kind, Name: subject.Name, Namespace: subject.Namespace}
+			if first, ok := seen[key]; ok {
+				errs = append(errs, field.Duplicate(subjectPath, fmt.Sprintf("%s %s, already a member at %s", subject.Kind, subject.Name, first)))
+				continue
+			}
+			seen[key] = subjectPath
+		}
+	}
+
+	if team.Spec.Quota != nil {
+		errs = append(errs, validateTeamQuota(specPath.Child("quota"), team.Spec.Quota)...)
+	}
+
+	return errs
+}
+
+// validateTeamQuota rejects resources the team quota can not count and negative limits
+func validateTeamQuota(fldPath *field.Path, quota *tenantv1alpha1.TeamQuota) field.ErrorList {
+	var errs field.ErrorList
+
+	supported := make([]string, 0, len(quotautil.Resources))
+	for _, name := range quotautil.Resources {
+		supported = append(supported, string(name))
+	}
+	for name, quantity := range quota.Hard {
+		hardPath := fldPath.Child("hard").Key(string(name))
+		if !sliceutil.HasString(supported, string(name)) {
+			errs = append(errs, field.NotSupported(hardPath, name, supported))
+		} else if quantity.Sign() < 0 {
+			errs = append(errs, field.Invalid(hardPath, quantity.String(), "must be greater than or equal to 0"))
+		}
+	}
+	return errs
+}
+
+// validateParent rejects a parent that makes the team its own ancestor. A missing parent is accepted,
+// the team inherits from it once it is created.
+func validateParent(c client.Client, team *tenantv1alpha1.Team) (field.ErrorList, error) {
+	parentPath := field.NewPath("spec", "parent")
+	if team.Spec.Parent == "" {
+		return nil, nil
+	}
+	if team.Spec.Parent == team.Name {
+		return field.ErrorList{field.Invalid(parentPath, team.Spec.Parent, "a team can not be its own parent")}, nil
+	}
+	_, err := teamutil.Ancestors(c, team)
+	switch {
+	case err == nil || errors.IsNotFound(err):
+		return nil, nil
+	case teamutil.IsCycle(err):
+		return field.ErrorList{field.Invalid(parentPath, team.Spec.Parent, err.Error())}, nil
+	}
+	return nil, err
+}
+
+// validateUsers rejects a manager and User members that are not the kubenebula:user:<name> username of
+// an existing User. The users of old, the team before an
+// update, are accepted, so a team stays editable after one of its users is deleted.
+func validateUsers(ctx context.Context, c client.Reader, team, old *tenantv1alpha1.Team) (field.ErrorList, error) {
+	known := make(map[string]bool)
+	if old != nil {
+		known[old.Spec.Manager] = true
+		for _, members := range [][]rbac.Subject{old.Spec.Admins, old.Spec.Regulars, old.Spec.Viewers} {
+			for _, subject := range members {
+				if subject.Kind == rbac.UserKind {
+					known[subject.Name] = true
+				}
+			}
+		}
+	}
+	validate := func(fldPath *field.Path, username string) (*field.Error, error) {
+		if known[username] {
+			return nil, nil
+		}
+		name, ok := users.Name(username)
+		if !ok {
+			return field.Invalid(fldPath, username, fmt.Sprintf("must be a built-in user named %s<name>", constants.BuiltinUserPrefix)), nil
+		}
+		err := c.Get(ctx, types.NamespacedName{Name: name}, &tenantv1alpha1.User{})
+		if errors.IsNotFound(err) {
+			return field.NotFound(fldPath, username), nil
+		}
+		return nil, err
+	}
+
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	if team.Spec.Manager != "" {
+		fieldErr, err := validate(specPath.Child("manager"), team.Spec.Manager)
+		if err != nil {
+			return nil, err
+		}
+		if fieldErr != nil {
+			errs = append(errs, fieldErr)
+		}
+	}
+	for _, members := range []struct {
+		name     string
+		subjects []rbac.Subject
+	}{
+		{"admins", team.Spec.Admins},
+		{"regulars", team.Spec.Regulars},
+		{"viewers", team.Spec.Viewers},
+	} {
+		for i, subject := range members.subjects {
+			if subject.Kind != rbac.UserKind {
+				continue
+			}
+			fieldErr, err := validate(specPath.Child(members.name).Index(i).Child("name"), subject.Name)
+			if err != nil {
+				return nil, err
+			}
+			if fieldErr != nil {
+				errs = append(errs, fieldErr)
+			}
+		}
+	}
+	return errs, nil
+}
+
+// maxTeamNameLength is the longest team name for which every team:<name>:<role> name is valid
+func maxTeamNameLength() int {
+	maxLength := maxResourceNameLength
+	for _, suffix := range teamRoleSuffixes {
+		if length := maxResourceNameLength - len(fmt.Sprintf("team::%s", suffix)); length < maxLength {
+			maxLength = length
+		}
+	}
+	return maxLength
+}
+
+func validateSubject(fldPath *field.Path, subject rbac.Subject) field.ErrorList {
+	var errs field.ErrorList
+
+	switch subject.Kind {
+	case rbac.UserKind, rbac.GroupKind:
+		if subject.APIGroup != "" && subject.APIGroup != rbac.GroupName {
+			errs = append(errs, field.NotSupported(fldPath.Child("apiGroup"), subject.APIGroup, []string{rbac.GroupName}))
+		}
+		if subject.Namespace != "" {
+			errs = append(errs, field.Forbidden(fldPath.Child("namespace"), "only service accounts have a namespace"))
+		}
+		errs = append(errs, validateUserName(fldPath.Child("name"), subject.Name)...)
+	case rbac.ServiceAccountKind:
+		if subject.APIGroup != "" {
+			errs = append(errs, field.NotSupported(fldPath.Child("apiGroup"), subject.APIGroup, []string{""}))
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(subject.Name) {
+			errs = append(errs, field.Invalid(fldPath.Child("name"), subject.Name, msg))
+		}
+		if subject.Namespace == "" {
+			errs = append(errs, field.Required(fldPath.Child("namespace"), "service accounts require a namespace"))
+		} else {
+			for _, msg := range validation.IsDNS1123Label(subject.Namespace) {
+				errs = append(errs, field.Invalid(fldPath.Child("namespace"), subject.Namespace, msg))
+			}
+		}
+	default:
+		errs = append(errs, field.NotSupported(fldPath.Child("kind"), subject.Kind, []string{rbac.UserKind, rbac.GroupKind, rbac.ServiceAccountKind}))
+	}
+
+	return errs
+}
+
+// validateUserName rejects empty user and group names and names with whitespace or control characters
+func validateUserName(fldPath *field.Path, name string) field.ErrorList {
+	if name == "" {
+		return field.ErrorList{field.Required(fldPath, "")}
+	}
+	for _, r := range name {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return field.ErrorList{field.Invalid(fldPath, name, "must not contain whitespace or control characters")}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,367 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	tenantv1alpha1 "kubenebula.io/kubenebula/api/tenant/v1alpha1"
+	"kubenebula.io/kubenebula/constants"
+	"kubenebula.io/kubenebula/users"
+	"kubenebula.io/kubenebula/utils/teamutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestValidateTeam(t *testing.T) {
+	tests := []struct {
+		name           string
+		team           tenantv1alpha1.Team
+		requireManager bool
+		errors         int
+	}{
+		{
+			name: "valid",
+			team: tenantv1alpha1.Team{
+				ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+				Spec: tenantv1alpha1.TeamSpec{
+					Manager:  "alice",
+					Admins:   []rbac.Subject{{Kind: rbac.GroupKind, Name: "nebula-admins"}},
+					Regulars: []rbac.Subject{{APIGroup: rbac.GroupName, Kind: rbac.UserKind, Name: "bob"}},
+					Viewers:  []rbac.Subject{{Kind: rbac.ServiceAccountKind, Name: "dashboard", Namespace: "nebula-test"}},
+				},
+			},
+		},
+		{
+			name:           "missing manager",
+			team:           tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}},
+			requireManager: true,
+			errors:         1,
+		},
+		{
+			name:   "name too long",
+			team:   tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("a", 241)}},
+			errors: 1,
+		},
+		{
+			name: "malformed subjects",
+			team: tenantv1alpha1.Team{
+				ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+				Spec: tenantv1alpha1.TeamSpec{
+					Manager: "alice smith",
+					Admins:  []rbac.Subject{{Kind: "Robot", Name: "r2d2"}},
+					Viewers: []rbac.Subject{{Kind: rbac.ServiceAccountKind, Name: "Dashboard"}},
+				},
+			},
+			errors: 4,
+		},
+		{
+			name: "duplicate members",
+			team: tenantv1alpha1.Team{
+				ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+				Spec: tenantv1alpha1.TeamSpec{
+					Admins:  []rbac.Subject{{Kind: rbac.UserKind, Name: "bob"}},
+					Viewers: []rbac.Subject{{APIGroup: rbac.GroupName, Kind: rbac.UserKind, Name: "bob"}},
+				},
+			},
+			errors: 1,
+		},
+		{
+			name: "invalid quota",
+			team: tenantv1alpha1.Team{
+				ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+				Spec: tenantv1alpha1.TeamSpec{
+					Quota: &tenantv1alpha1.TeamQuota{Hard: corev1.ResourceList{
+						tenantv1alpha1.ResourceNamespaces: resource.MustParse("5"),
+						corev1.ResourceRequestsCPU:        resource.MustParse("-1"),
+						"count/deployments.apps":          resource.MustParse("10"),
+					}},
+				},
+			},
+			errors: 2,
+		},
+	}
+	for _, test := range tests {
+		if errs := validateTeam(&test.team, test.requireManager); len(errs) != test.errors {
+			t.Errorf("%s: validateTeam() = %v, expected %d errors", test.name, errs, test.errors)
+		}
+	}
+}
+
+func TestDefaultTeam(t *testing.T) {
+	team := &tenantv1alpha1.Team{
+		Spec: tenantv1alpha1.TeamSpec{
+			Admins:  []rbac.Subject{{Kind: rbac.GroupKind, Name: "nebula-admins"}},
+			Viewers: []rbac.Subject{{Kind: rbac.ServiceAccountKind, Name: "dashboard", Namespace: "nebula-test"}},
+		},
+	}
+	defaultTeamCreator(team, "alice")
+	defaultTeamManager(team, false)
+	defaultTeam(team)
+
+	if team.Annotations[constants.CreatorAnnotationKey] != "alice" || team.Spec.Manager != "alice" {
+		t.Errorf("creator not defaulted: %v, manager %q", team.Annotations, team.Spec.Manager)
+	}
+	if team.Spec.DeletionPolicy != tenantv1alpha1.OrphanDeletionPolicy {
+		t.Errorf("deletion policy not defaulted: %q", team.Spec.DeletionPolicy)
+	}
+	if team.Spec.Admins[0].APIGroup != rbac.GroupName {
+		t.Errorf("group API group not defaulted: %+v", team.Spec.Admins[0])
+	}
+	if team.Spec.Viewers[0].APIGroup != "" {
+		t.Errorf("service account API group defaulted: %+v", team.Spec.Viewers[0])
+	}
+	if team.Spec.Regulars == nil || len(team.Spec.Regulars) != 0 {
+		t.Errorf("role list not defaulted: %#v", team.Spec.Regulars)
+	}
+}
+
+func TestTeamDefaulter(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &teamDefaulter{decoder: decoder}
+	newTeam := func(creator, manager string) runtime.RawExtension {
+		team := &tenantv1alpha1.Team{
+			TypeMeta:   metav1.TypeMeta{APIVersion: tenantv1alpha1.GroupVersion.String(), Kind: "Team"},
+			ObjectMeta: metav1.ObjectMeta{Name: "nebula"},
+			Spec:       tenantv1alpha1.TeamSpec{Manager: manager},
+		}
+		if creator != "" {
+			team.Annotations = map[string]string{constants.CreatorAnnotationKey: creator}
+		}
+		data, _ := json.Marshal(team)
+		return runtime.RawExtension{Raw: data}
+	}
+
+	tests := []struct {
+		name    string
+		request admissionv1beta1.AdmissionRequest
+		creator string
+		manager string
+	}{
+		{"create", admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Create, Object: newTeam("mallory", "carol")}, "alice", "carol"},
+		{"create without manager", admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Create, Object: newTeam("", "")}, "alice", "alice"},
+		{"update keeps the creator", admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Update, Object: newTeam("mallory", "carol"), OldObject: newTeam("alice", "carol")}, "alice", "carol"},
+		{"update without creator", admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Update, Object: newTeam("mallory", "carol"), OldObject: newTeam("", "carol")}, "", "carol"},
+		{"update clears the manager", admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Update, Object: newTeam("alice", ""), OldObject: newTeam("alice", "carol")}, "alice", ""},
+	}
+	for _, test := range tests {
+		test.request.UserInfo.Username = "alice"
+		response := d.Handle(context.TODO(), admission.Request{AdmissionRequest: test.request})
+		if !response.Allowed {
+			t.Fatalf("%s: %v", test.name, response.Result)
+		}
+		original := &tenantv1alpha1.Team{}
+		if err := json.Unmarshal(test.request.Object.Raw, original); err != nil {
+			t.Fatal(err)
+		}
+		creator, manager := original.Annotations[constants.CreatorAnnotationKey], original.Spec.Manager
+		for _, patch := range response.Patches {
+			if patch.Path == "/spec/manager" {
+				manager, _ = patch.Value.(string)
+			}
+			if patch.Path == "/metadata/annotations/kubenebula.io~1creator" {
+				creator, _ = patch.Value.(string)
+				if patch.Operation == "remove" {
+					creator = ""
+				}
+			}
+			if patch.Path == "/metadata/annotations" {
+				annotations, _ := patch.Value.(map[string]interface{})
+				creator, _ = annotations[constants.CreatorAnnotationKey].(string)
+			}
+		}
+		if creator != test.creator {
+			t.Errorf("%s: creator = %q, expected %q: %v", test.name, creator, test.creator, response.Patches)
+		}
+		if manager != test.manager {
+			t.Errorf("%s: manager = %q, expected %q: %v", test.name, manager, test.manager, response.Patches)
+		}
+	}
+}
+
+func TestValidateParent(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	team := func(name, parent string) *tenantv1alpha1.Team {
+		return &tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: tenantv1alpha1.TeamSpec{Parent: parent}}
+	}
+	c := fake.NewFakeClientWithScheme(scheme, team("company", ""), team("department", "company"), team("nebula", "department"))
+
+	tests := []struct {
+		name   string
+		team   *tenantv1alpha1.Team
+		errors int
+	}{
+		{"no parent", team("company", ""), 0},
+		{"existing parent", team("comet", "department"), 0},
+		{"missing parent", team("comet", "galaxy"), 0},
+		{"own parent", team("comet", "comet"), 1},
+		{"cycle", team("company", "nebula"), 1},
+	}
+	for _, test := range tests {
+		errs, err := validateParent(c, test.team)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(errs) != test.errors {
+			t.Errorf("%s: validateParent() = %v, expected %d errors", test.name, errs, test.errors)
+		}
+	}
+}
+
+func TestValidateUsers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	c := fake.NewFakeClientWithScheme(scheme,
+		&tenantv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "alice"}},
+		&tenantv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "bob"}},
+	)
+	team := func(manager string, admins ...rbac.Subject) *tenantv1alpha1.Team {
+		return &tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}, Spec: tenantv1alpha1.TeamSpec{Manager: manager, Admins: admins}}
+	}
+	user := func(name string) rbac.Subject { return rbac.Subject{Kind: rbac.UserKind, Name: users.Username(name)} }
+	alice := users.Username("alice")
+
+	tests := []struct {
+		name   string
+		team   *tenantv1alpha1.Team
+		old    *tenantv1alpha1.Team
+		errors int
+	}{
+		{"known users", team(alice, user("bob")), nil, 0},
+		{"no manager", team(""), nil, 0},
+		{"unknown manager", team(users.Username("eve")), nil, 1},
+		{"unknown member", team(alice, user("eve"), user("mallory")), nil, 2},
+		{"external users", team("alice", rbac.Subject{Kind: rbac.UserKind, Name: "bob"}), nil, 2},
+		{"groups and service accounts", team(alice, rbac.Subject{Kind: rbac.GroupKind, Name: "developers"},
+			rbac.Subject{Kind: rbac.ServiceAccountKind, Name: "ci", Namespace: "default"}), nil, 0},
+		{"existing members", team("eve", user("mallory")), team("eve", user("mallory")), 0},
+		{"new member", team("eve", user("mallory"), user("trent")), team("eve", user("mallory")), 1},
+	}
+	for _, test := range tests {
+		errs, err := validateUsers(context.TODO(), c, test.team, test.old)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(errs) != test.errors {
+			t.Errorf("%s: validateUsers() = %v, expected %d errors", test.name, errs, test.errors)
+		}
+	}
+}
+
+func TestDefaultTeamManagerWithValidatedUsers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	c := fake.NewFakeClientWithScheme(scheme, &tenantv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "alice"}})
+
+	tests := []struct {
+		name          string
+		creator       string
+		validateUsers bool
+		manager       string
+	}{
+		{"built-in creator", users.Username("alice"), true, users.Username("alice")},
+		{"external creator", "kubernetes-admin", true, ""},
+		{"external creator without validation", "kubernetes-admin", false, "kubernetes-admin"},
+	}
+	for _, test := range tests {
+		team := &tenantv1alpha1.Team{ObjectMeta: metav1.ObjectMeta{Name: "nebula"}}
+		defaultTeamCreator(team, test.creator)
+		defaultTeamManager(team, test.validateUsers)
+		defaultTeam(team)
+		if team.Spec.Manager != test.manager {
+			t.Errorf("%s: manager = %q, expected %q", test.name, team.Spec.Manager, test.manager)
+		}
+		if !test.validateUsers {
+			continue
+		}
+		if errs, err := validateUsers(context.TODO(), c, team, nil); err != nil || len(errs) != 0 {
+			t.Errorf("%s: validateUsers() = %v, %v, expected the defaulted team to be valid", test.name, errs, err)
+		}
+	}
+}
+
+func TestValidateTeamDeletion(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tenantv1alpha1.AddToScheme(scheme)
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTeam := func(name string, policy tenantv1alpha1.TeamDeletionPolicy) *tenantv1alpha1.Team {
+		return &tenantv1alpha1.Team{
+			TypeMeta:   metav1.TypeMeta{APIVersion: tenantv1alpha1.GroupVersion.String(), Kind: "Team"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       tenantv1alpha1.TeamSpec{DeletionPolicy: policy},
+		}
+	}
+	blocked := newTeam("nebula", tenantv1alpha1.BlockDeletionPolicy)
+	orphan := newTeam("comet", tenantv1alpha1.OrphanDeletionPolicy)
+	empty := newTeam("empty", tenantv1alpha1.BlockDeletionPolicy)
+	parent := newTeam("department", tenantv1alpha1.OrphanDeletionPolicy)
+	child := newTeam("child", tenantv1alpha1.OrphanDeletionPolicy)
+	child.Spec.Parent = parent.Name
+	v := &teamValidator{
+		client: fake.NewFakeClientWithScheme(scheme, blocked, orphan, empty, parent, child,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "nebula-prod", Labels: teamutil.Labels("nebula")}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "comet-prod", Labels: teamutil.Labels("comet")}},
+		),
+		decoder: decoder,
+	}
+
+	tests := []struct {
+		name    string
+		team    *tenantv1alpha1.Team
+		old     bool
+		allowed bool
+	}{
+		{"block with namespaces", blocked, true, false},
+		{"block without old object", blocked, false, false},
+		{"orphan with namespaces", orphan, true, true},
+		{"block without namespaces", empty, true, true},
+		{"parent", parent, true, false},
+		{"child", child, true, true},
+		{"missing team", newTeam("missing", tenantv1alpha1.BlockDeletionPolicy), false, true},
+	}
+	for _, test := range tests {
+		request := admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Delete, Name: test.team.Name}
+		if test.old {
+			data, _ := json.Marshal(test.team)
+			request.OldObject = runtime.RawExtension{Raw: data}
+		}
+		response := v.Handle(context.TODO(), admission.Request{AdmissionRequest: request})
+		if response.Allowed != test.allowed {
+			t.Errorf("%s: allowed = %v, expected %v: %v", test.name, response.Allowed, test.allowed, response.Result)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2019 The KubeNebula authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhooks contains the admission webhooks served by the manager's webhook server.
+package webhooks
+
+import (
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+var log = logf.Log.WithName("webhooks")
+
+// Options configures the admission webhooks
+type Options struct {
+	// RequireTeamManager rejects teams without spec.manager
+	RequireTeamManager bool
+	// ValidateUsers rejects team managers and User members that are not built-in users
+	ValidateUsers bool
+}
+
+// Add registers the admission webhooks with the webhook server of the Manager.
+func Add(mgr manager.Manager, options Options) error {
+	server := mgr.GetWebhookServer()
+	server.Register(mutateTeamPath, &webhook.Admission{Handler: &teamDefaulter{validateUsers: options.ValidateUsers}})
+	server.Register(validateTeamPath, &webhook.Admission{Handler: &teamValidator{
+		client:         mgr.GetClient(),
+		requireManager: options.RequireTeamManager,
+		validateUsers:  options.ValidateUsers,
+	}})
+	server.Register(mutateNamespaceClaimPath, &webhook.Admission{Handler: &namespaceClaimDefaulter{}})
+	server.Register(validateNamespaceClaimPath, &webhook.Admission{Handler: &namespaceClaimValidator{}})
+	server.Register(validatePodPath, &webhook.Admission{Handler: &podQuotaValidator{client: mgr.GetClient()}})
+	server.Register(validateNamespacePath, &webhook.Admission{Handler: &namespaceQuotaValidator{client: mgr.GetClient()}})
+	return nil
+}